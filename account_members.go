@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAccountAccessDenied      = errors.New("insufficient permissions on account")
+	ErrDelegatePayLimitExceeded = errors.New("amount exceeds delegate payment limit")
+)
+
+// permissionRank упорядочивает права на счёт: каждое следующее включает предыдущие
+var permissionRank = map[string]int{
+	AccountPermissionView: 1,
+	AccountPermissionPay:  2,
+	AccountPermissionFull: 3,
+}
+
+// AuthorizeAccount возвращает счёт, если пользователь вправе выполнить на нём действие уровня permission.
+// Платёж доверенного лица с правом pay дополнительно проверяется по его лимиту на операцию.
+// Пользователю без доступа счёт не раскрывается: возвращается ErrAccountNotFound.
+func AuthorizeAccount(accountID, userID, permission string, amount decimal.Decimal) (Account, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	acc, ok := storage.accounts[accountID]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	m, ok := accountAccess(acc, userID)
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	if permissionRank[m.Permission] < permissionRank[permission] {
+		return Account{}, ErrAccountAccessDenied
+	}
+	if m.Permission == AccountPermissionPay && m.PaymentLimit.IsPositive() && amount.GreaterThan(m.PaymentLimit) {
+		return Account{}, ErrDelegatePayLimitExceeded
+	}
+	return acc, nil
+}
+
+// respondAccountAccessError отвечает на отказ AuthorizeAccount; notFound — текст ответа, если счёт недоступен
+func respondAccountAccessError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, ErrAccountAccessDenied):
+		respondError(w, http.StatusForbidden, "Insufficient permissions on account")
+	case errors.Is(err, ErrDelegatePayLimitExceeded):
+		respondError(w, http.StatusForbidden, "Amount exceeds delegate payment limit")
+	default:
+		respondError(w, http.StatusNotFound, notFound)
+	}
+}
+
+// findUserByIdentifier ищет пользователя по логину, email или телефону
+func findUserByIdentifier(identifier string) (User, bool) {
+	identifier = strings.TrimSpace(identifier)
+	if user, ok := GetUserByUsername(identifier); ok {
+		return user, true
+	}
+	if user, ok := GetUserByEmail(strings.ToLower(identifier)); ok {
+		return user, true
+	}
+	if phone, ok := NormalizePhone(identifier); ok {
+		return GetUserByPhone(phone)
+	}
+	return User{}, false
+}
+
+func respondMembershipError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrMembershipNotFound), errors.Is(err, ErrAccountNotFound):
+		respondError(w, http.StatusNotFound, "Membership not found")
+	case errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrInvalidMembershipState):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ownAccount возвращает счёт, если пользователь — его владелец (для счёта организации — подписант):
+// только он управляет участниками
+func ownAccount(w http.ResponseWriter, r *http.Request) (Account, string, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return Account{}, "", false
+	}
+	account, ok := IsAccountHolder(mux.Vars(r)["accountId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Account not found")
+		return Account{}, "", false
+	}
+	return account, userID, true
+}
+
+// InviteAccountMemberHandler приглашает пользователя совладельцем или доверенным лицом счёта
+func InviteAccountMemberHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req InviteAccountMemberRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	account, userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+	if account.Status == AccountStatusClosed {
+		respondError(w, http.StatusConflict, "Account is closed")
+		return
+	}
+	invitee, ok := findUserByIdentifier(req.User)
+	if !ok {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	now := time.Now()
+	membership := AccountMembership{
+		ID:           GenerateID(),
+		AccountID:    account.ID,
+		UserID:       invitee.ID,
+		Permission:   req.Permission,
+		PaymentLimit: req.PaymentLimit,
+		Status:       MembershipStatusInvited,
+		InvitedBy:    userID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := AddAccountMembership(membership); err != nil {
+		respondMembershipError(w, err)
+		return
+	}
+
+	log.Printf("User %s invited to account %s with %s permission", invitee.ID, account.ID, req.Permission)
+	respondJSON(w, http.StatusCreated, membership)
+}
+
+func GetAccountMembersHandler(w http.ResponseWriter, r *http.Request) {
+	account, _, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, GetAccountMemberships(account.ID))
+}
+
+// RevokeAccountMemberHandler отзывает доступ или приглашение. Владелец может отозвать любого
+// участника, участник — отказаться от своего доступа.
+func RevokeAccountMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	membership, ok := GetAccountMembership(vars["membershipId"])
+	if !ok || membership.AccountID != vars["accountId"] {
+		respondError(w, http.StatusNotFound, "Membership not found")
+		return
+	}
+	if _, holder := IsAccountHolder(membership.AccountID, userID); !holder && membership.UserID != userID {
+		respondError(w, http.StatusNotFound, "Membership not found")
+		return
+	}
+
+	// Вместе с доступом отменяются поручения участника со счёта; исполнение поручений при этом не идёт
+	standingOrdersMu.Lock()
+	membership, err := SetMembershipStatus(membership.ID, MembershipStatusRevoked, time.Now())
+	standingOrdersMu.Unlock()
+	if err != nil {
+		respondMembershipError(w, err)
+		return
+	}
+
+	log.Printf("Access of user %s to account %s revoked by %s", membership.UserID, membership.AccountID, userID)
+	respondJSON(w, http.StatusOK, membership)
+}
+
+// GetAccountInvitationsHandler возвращает приглашения к счетам, ожидающие ответа пользователя
+func GetAccountInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetUserMemberships(userID, MembershipStatusInvited))
+}
+
+func AcceptAccountInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	respondToInvitation(w, r, MembershipStatusActive)
+}
+
+func DeclineAccountInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	respondToInvitation(w, r, MembershipStatusDeclined)
+}
+
+// respondToInvitation принимает или отклоняет приглашение; ответить может только приглашённый
+func respondToInvitation(w http.ResponseWriter, r *http.Request, status string) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	membership, ok := GetAccountMembership(mux.Vars(r)["membershipId"])
+	if !ok || membership.UserID != userID {
+		respondError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	membership, err := SetMembershipStatus(membership.ID, status, time.Now())
+	if err != nil {
+		respondMembershipError(w, err)
+		return
+	}
+
+	log.Printf("Invitation %s to account %s: %s", membership.ID, membership.AccountID, membership.Status)
+	respondJSON(w, http.StatusOK, membership)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func respondAccountStatusError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		respondError(w, http.StatusNotFound, "Account not found")
+	case errors.Is(err, ErrAccountClosed):
+		respondError(w, http.StatusConflict, "Account is already closed")
+	case errors.Is(err, ErrInvalidAccountStatus):
+		respondError(w, http.StatusConflict, "Status change not allowed in current account state")
+	case errors.Is(err, ErrAccountNotEmpty), errors.Is(err, ErrAccountHasHolds), errors.Is(err, ErrAccountHasPendingPayments):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondTransferError(w, err)
+	}
+}
+
+// CloseAccountHandler закрывает счёт клиента; ненулевой остаток переводится на указанный счёт клиента.
+// Закрыть счёт может только владелец (для счёта организации — подписант), совладельцы и доверенные лица — нет.
+func CloseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CloseAccountRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	accountID := mux.Vars(r)["accountId"]
+	account, ok := IsAccountHolder(accountID, userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if req.SweepToAccountID != "" {
+		target, err := AuthorizeAccount(req.SweepToAccountID, userID, AccountPermissionFull, decimal.Zero)
+		if err != nil {
+			respondAccountAccessError(w, err, fmt.Sprintf("Sweep account %s not found", req.SweepToAccountID))
+			return
+		}
+		// Остаток организации нельзя вывести на счёт подписанта в обход второй подписи,
+		// остаток личного счёта — на счёт совладельца или доверителя
+		sameHolder := target.OrganizationID == account.OrganizationID
+		if account.OrganizationID == "" {
+			sameHolder = sameHolder && target.UserID == account.UserID
+		}
+		if !sameHolder {
+			respondError(w, http.StatusConflict, "Sweep account must belong to the same account holder")
+			return
+		}
+	}
+
+	account, sweep, err := CloseAccount(accountID, req.SweepToAccountID, time.Now())
+	if err != nil {
+		respondAccountStatusError(w, err)
+		return
+	}
+	unlinkSBPAccount(account.ID)
+
+	response := map[string]interface{}{"account": account}
+	if sweep != nil {
+		response["sweep_transaction_id"] = sweep.ID
+		log.Printf("Account %s closed, %s swept to %s", account.ID, sweep.Amount.String(), sweep.ToAccountID)
+	} else {
+		log.Printf("Account %s closed", account.ID)
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// SetAccountStatusHandler замораживает счёт, накладывает арест на сумму или возвращает счёт
+// в активное состояние, в том числе переоткрывает закрытый (для операторов)
+func SetAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SetAccountStatusRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	account, err := SetAccountStatus(mux.Vars(r)["accountId"], req.Status, req.ArrestedAmount, req.Reason)
+	if err != nil {
+		respondAccountStatusError(w, err)
+		return
+	}
+
+	log.Printf("Account %s status set to %s by operator %s: %s", account.ID, account.Status, operatorID, req.Reason)
+	respondJSON(w, http.StatusOK, account)
+}
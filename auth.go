@@ -2,27 +2,44 @@ package main
 
 import (
 	"github.com/joho/godotenv"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey []byte
+var (
+	jwtKey         []byte
+	actionTokenKey []byte // ключ подписи одноразовых токенов, выводится из JWT_SECRET_KEY
+)
 
-func init() {
-    err := godotenv.Load()
-    if err != nil {
-        log.Fatal("Ошибка загрузки .env файла")
+// InitAuth загружает .env и ключ подписи токенов. Вызывается из main до чтения остальных переменных окружения,
+// чтобы тесты пакета не зависели от .env.
+func InitAuth() error {
+    if err := godotenv.Load(); err != nil {
+        return errors.New("ошибка загрузки .env файла")
     }
     key := os.Getenv("JWT_SECRET_KEY")
     if len(key) == 0 {
-        log.Fatal("Переменная окружения JWT_SECRET_KEY не установлена")
+        return errors.New("переменная окружения JWT_SECRET_KEY не установлена")
     }
     jwtKey = []byte(key)
+    actionTokenKey = deriveKey(jwtKey, "action-token")
+    return nil
+}
+
+// deriveKey выводит из секрета отдельный ключ для указанного назначения
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
 }
 
 type Claims struct {
@@ -71,4 +88,64 @@ func ValidateJWT(tokenStr string) (*Claims, error) {
 	}
 
 	return claims, nil
-}
\ No newline at end of file
+}
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// ActionTokenClaims описывает содержимое одноразового токена для писем
+type ActionTokenClaims struct {
+	ID        string `json:"jti"`
+	UserID    string `json:"uid"`
+	Purpose   string `json:"purpose"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// GenerateActionToken создает подписанный одноразовый токен с ограниченным сроком действия
+func GenerateActionToken(userID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := ActionTokenClaims{
+		ID:        GenerateID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + GenerateHMAC(purpose+"."+encoded, actionTokenKey), nil
+}
+
+// ParseActionToken проверяет подпись, назначение и срок действия токена
+func ParseActionToken(token, purpose string) (*ActionTokenClaims, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, errors.New("malformed token")
+	}
+	expected := GenerateHMAC(purpose+"."+encoded, actionTokenKey)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	var claims ActionTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed token")
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	return &claims, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Срок, после которого неиспользованная авторизация снимается автоматически
+const authorizationHoldTTL = 7 * 24 * time.Hour
+
+func respondAuthorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAuthorizationNotFound):
+		respondError(w, http.StatusNotFound, "Authorization not found")
+	case errors.Is(err, ErrAuthorizationClosed):
+		respondError(w, http.StatusConflict, "Authorization is no longer open")
+	case errors.Is(err, ErrCaptureExceedsAuthorization):
+		respondError(w, http.StatusBadRequest, "Capture amount exceeds remaining authorized amount")
+	default:
+		respondPaymentError(w, err)
+	}
+}
+
+// authorizeCard проверяет ограничения карты и ставит холд на сумму авторизации
+func authorizeCard(card Card, use CardUse, description string, merchant Merchant) (CardAuthorization, error) {
+	now := time.Now()
+	auth := CardAuthorization{
+		ID:             GenerateID(),
+		CardID:         card.ID,
+		AccountID:      card.AccountID,
+		MerchantID:     merchant.ID,
+		TokenID:        use.TokenID,
+		Amount:         use.Amount,
+		CapturedAmount: decimal.Zero,
+		Status:         AuthorizationStatusAuthorized,
+		Description:    description,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(authorizationHoldTTL),
+	}
+
+	if err := PlaceAuthorizationHold(auth, cardGuard(card.ID, use, now)); err != nil {
+		return CardAuthorization{}, accountDecline(err)
+	}
+	return auth, nil
+}
+
+func AuthorizeCardHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req MerchantPaymentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	var card Card
+	var token CardToken
+	var err error
+	if req.Token != "" {
+		card, token, err = resolveCardToken(req.Token, merchant.ID)
+	} else {
+		card, err = verifyCardForPayment(req.CardNumber, req.CVV)
+	}
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	use := newCardUse(req.Amount, merchant.MCC, req.Channel, req.Country, merchant.ID)
+	use.TokenID = token.ID
+	auth, err := authorizeCard(card, use, req.Description, merchant)
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	log.Printf("Authorization %s for %s placed on account %s by merchant %s", auth.ID, auth.Amount.String(), auth.AccountID, merchant.ID)
+	respondJSON(w, http.StatusCreated, auth)
+}
+
+func GetAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	auth, ok := GetAuthorization(mux.Vars(r)["authId"])
+	if !ok || auth.MerchantID != merchant.ID {
+		respondError(w, http.StatusNotFound, "Authorization not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, auth)
+}
+
+// CaptureAuthorizationHandler выполняет финальное списание: сумма по умолчанию равна остатку холда,
+// неиспользованная часть холда снимается
+func CaptureAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	captureAuthorization(w, r, true)
+}
+
+// PartialCaptureHandler списывает часть суммы, оставляя авторизацию открытой для следующих списаний
+func PartialCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	captureAuthorization(w, r, false)
+}
+
+func captureAuthorization(w http.ResponseWriter, r *http.Request, final bool) {
+	defer r.Body.Close()
+	var req CaptureRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	authID := mux.Vars(r)["authId"]
+	auth, ok := GetAuthorization(authID)
+	if !ok || auth.MerchantID != merchant.ID {
+		respondError(w, http.StatusNotFound, "Authorization not found")
+		return
+	}
+
+	amount := req.Amount
+	if amount.IsZero() {
+		if !final {
+			respondValidationError(w, ValidationErrors{{Field: "amount", Message: "is required for partial capture"}})
+			return
+		}
+		amount = auth.HeldAmount()
+	}
+
+	tx := Transaction{
+		ID:              GenerateID(),
+		Timestamp:       time.Now(),
+		TransactionType: "payment",
+		Description:     fmt.Sprintf("Payment to %s", merchant.Name),
+	}
+	if auth.Description != "" {
+		tx.Description = fmt.Sprintf("%s: %s", tx.Description, auth.Description)
+	}
+
+	auth, err := CaptureAuthorization(authID, merchant.ID, amount, final, tx)
+	if err != nil {
+		respondAuthorizationError(w, err)
+		return
+	}
+
+	log.Printf("Captured %s on authorization %s (status %s)", amount.String(), auth.ID, auth.Status)
+	respondJSON(w, http.StatusOK, auth)
+}
+
+func VoidAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	auth, err := VoidAuthorization(mux.Vars(r)["authId"], merchant.ID)
+	if err != nil {
+		respondAuthorizationError(w, err)
+		return
+	}
+
+	log.Printf("Authorization %s voided by merchant %s", auth.ID, merchant.ID)
+	respondJSON(w, http.StatusOK, auth)
+}
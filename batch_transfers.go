@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Максимальный размер загружаемой ведомости
+const maxBatchUploadSize = 5 << 20
+
+// Колонки CSV-ведомости; заголовок обязателен, порядок колонок произвольный
+var batchCSVColumns = map[string]bool{
+	"to":            true,
+	"to_type":       true,
+	"to_account_id": true,
+	"amount":        true,
+	"description":   true,
+	"reference":     true,
+}
+
+// parseBatchTransferRequest читает пакет из JSON-тела, CSV-тела (параметры from_account_id
+// и mode — в строке запроса) или из файла в multipart/form-data (поле file)
+func parseBatchTransferRequest(w http.ResponseWriter, r *http.Request) (BatchTransferRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchUploadSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxBatchUploadSize); err != nil {
+			return BatchTransferRequest{}, errors.New("invalid multipart form")
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return BatchTransferRequest{}, errors.New("file is required")
+		}
+		defer file.Close()
+
+		var lines []BatchTransferLine
+		if strings.HasSuffix(strings.ToLower(header.Filename), ".json") {
+			if err := json.NewDecoder(file).Decode(&lines); err != nil {
+				return BatchTransferRequest{}, errors.New("invalid JSON file")
+			}
+		} else if lines, err = parseBatchCSV(file); err != nil {
+			return BatchTransferRequest{}, err
+		}
+		return BatchTransferRequest{
+			FromAccountID: r.FormValue("from_account_id"),
+			Mode:          r.FormValue("mode"),
+			Transfers:     lines,
+		}, nil
+	case "text/csv":
+		lines, err := parseBatchCSV(r.Body)
+		if err != nil {
+			return BatchTransferRequest{}, err
+		}
+		return BatchTransferRequest{
+			FromAccountID: r.URL.Query().Get("from_account_id"),
+			Mode:          r.URL.Query().Get("mode"),
+			Transfers:     lines,
+		}, nil
+	default:
+		var req BatchTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return BatchTransferRequest{}, errors.New("invalid JSON payload")
+		}
+		return req, nil
+	}
+}
+
+// parseBatchCSV разбирает CSV-ведомость. Разделитель — запятая или точка с запятой
+// (выгрузка из Excel); во втором случае в суммах допускается десятичная запятая.
+func parseBatchCSV(r io.Reader) ([]BatchTransferLine, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		br.Discard(3)
+	}
+	firstLine, _ := br.Peek(br.Buffered())
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	reader := csv.NewReader(br)
+	reader.TrimLeadingSpace = true
+	semicolon := bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(","))
+	if semicolon {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV file is empty or malformed")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !batchCSVColumns[name] {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, errors.New("CSV column amount is required")
+	}
+	_, hasTo := columns["to"]
+	_, hasAccount := columns["to_account_id"]
+	if !hasTo && !hasAccount {
+		return nil, errors.New("CSV column to or to_account_id is required")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	lines := make([]BatchTransferLine, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed CSV: %v", err)
+		}
+		row, _ := reader.FieldPos(0)
+
+		rawAmount := field(record, "amount")
+		if semicolon {
+			rawAmount = strings.ReplaceAll(rawAmount, ",", ".")
+		}
+		amount, err := decimal.NewFromString(strings.ReplaceAll(rawAmount, " ", ""))
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: invalid amount %q", row, field(record, "amount"))
+		}
+		lines = append(lines, BatchTransferLine{
+			ToAccountID: field(record, "to_account_id"),
+			To:          field(record, "to"),
+			ToType:      field(record, "to_type"),
+			Amount:      amount,
+			Description: field(record, "description"),
+			Reference:   field(record, "reference"),
+		})
+	}
+	return lines, nil
+}
+
+// BatchTransferHandler исполняет пакет переводов с одного счёта (например, зарплатную ведомость).
+// Все строки проверяются до исполнения: если хотя бы одна некорректна, пакет отклоняется целиком.
+func BatchTransferHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	req, err := parseBatchTransferRequest(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid batch: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		respondValidationError(w, errs)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = BatchModeAtomic
+	}
+
+	// Лимит доверенного лица действует на каждую строку пакета, порог второй подписи — на весь пакет
+	largest, total := decimal.Zero, decimal.Zero
+	for _, line := range req.Transfers {
+		largest = decimal.Max(largest, line.Amount)
+		total = total.Add(line.Amount)
+	}
+	fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, largest)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return
+	}
+	if RequiresDualApproval(fromAccount, total) {
+		respondError(w, http.StatusConflict, "Batch total exceeds the organization's dual approval threshold")
+		return
+	}
+
+	report := BatchTransferReport{
+		BatchID:           GenerateID(),
+		FromAccountID:     fromAccount.ID,
+		Mode:              req.Mode,
+		TotalLines:        len(req.Transfers),
+		TotalAmount:       decimal.Zero,
+		TransferredAmount: decimal.Zero,
+		Lines:             make([]BatchTransferLineResult, len(req.Transfers)),
+	}
+
+	// Проверяем все строки и находим получателей до списания
+	transfers := make([]BatchTransfer, len(req.Transfers))
+	for i, line := range req.Transfers {
+		result := BatchTransferLineResult{
+			Line:      i + 1,
+			Reference: line.Reference,
+			Recipient: line.To,
+			Amount:    line.Amount,
+			Status:    BatchLineNotExecuted,
+		}
+		if result.Recipient == "" {
+			result.Recipient = line.ToAccountID
+		}
+		report.TotalAmount = report.TotalAmount.Add(line.Amount)
+
+		if errs := line.Validate(); len(errs) > 0 {
+			result.Status, result.Error = BatchLineInvalid, errs.Error()
+		} else if toAccountID, err := transferDestination(line.ToAccountID, line.To, line.ToType); err != nil {
+			_, message := transferErrorResponse(err)
+			result.Status, result.Error = BatchLineInvalid, message
+		} else if toAccountID == fromAccount.ID {
+			result.Status, result.Error = BatchLineInvalid, "Source and destination accounts must differ"
+		} else {
+			description := line.Description
+			if description == "" {
+				description = fmt.Sprintf("Batch transfer %s", report.BatchID)
+			}
+			transfers[i] = BatchTransfer{ToAccountID: toAccountID, Amount: line.Amount, Description: description}
+		}
+		if result.Status == BatchLineInvalid {
+			report.Failed++
+		}
+		report.Lines[i] = result
+	}
+	if report.Failed > 0 {
+		report.Status = BatchStatusRejected
+		report.Error = "Batch contains invalid lines, nothing was executed"
+		respondJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	results, err := ExecuteTransferBatch(fromAccount.ID, transfers, req.Mode == BatchModeAtomic)
+	if err != nil {
+		status, message := transferErrorResponse(err)
+		report.Status, report.Error, report.Failed = BatchStatusFailed, message, report.TotalLines
+		log.Printf("Batch %s from account %s rejected: %v", report.BatchID, fromAccount.ID, err)
+		respondJSON(w, status, report)
+		return
+	}
+
+	for i, res := range results {
+		line := &report.Lines[i]
+		if res.Err != nil {
+			_, message := transferErrorResponse(res.Err)
+			line.Status, line.Error = BatchLineFailed, message
+			report.Failed++
+			continue
+		}
+		line.Status, line.TransactionID = BatchLineSucceeded, res.Transaction.ID
+		report.Succeeded++
+		report.TransferredAmount = report.TransferredAmount.Add(res.Transaction.Amount)
+	}
+	switch {
+	case report.Failed == 0:
+		report.Status = BatchStatusCompleted
+	case report.Succeeded == 0:
+		report.Status = BatchStatusFailed
+	default:
+		report.Status = BatchStatusPartial
+	}
+
+	log.Printf("Batch %s from account %s: %d of %d transfers succeeded, %s transferred",
+		report.BatchID, fromAccount.ID, report.Succeeded, report.TotalLines, report.TransferredAmount.String())
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseBatchCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []BatchTransferLine
+	}{
+		{
+			name:  "comma separated",
+			input: "to,amount,reference\n+79161234567,45000.00,E-001\n40817810000000000001,52000.50,E-002\n",
+			want: []BatchTransferLine{
+				{To: "+79161234567", Amount: decimal.RequireFromString("45000"), Reference: "E-001"},
+				{To: "40817810000000000001", Amount: decimal.RequireFromString("52000.5"), Reference: "E-002"},
+			},
+		},
+		{
+			name:  "semicolon with decimal comma, BOM and spaces in amount",
+			input: "\xEF\xBB\xBFTo;Amount;Description\n+79161234567;45 000,25;Зарплата за май\n",
+			want: []BatchTransferLine{
+				{To: "+79161234567", Amount: decimal.RequireFromString("45000.25"), Description: "Зарплата за май"},
+			},
+		},
+		{
+			name:  "account IDs, explicit type and CRLF",
+			input: "to_account_id,to,to_type,amount\r\nacc-1,,,10\r\n,4000001234567899,card_number,20\r\n",
+			want: []BatchTransferLine{
+				{ToAccountID: "acc-1", Amount: decimal.RequireFromString("10")},
+				{To: "4000001234567899", ToType: "card_number", Amount: decimal.RequireFromString("20")},
+			},
+		},
+		{
+			name:  "quoted field with comma",
+			input: "to,amount,description\n+79161234567,100,\"Премия, май\"\n",
+			want: []BatchTransferLine{
+				{To: "+79161234567", Amount: decimal.RequireFromString("100"), Description: "Премия, май"},
+			},
+		},
+		{
+			name:  "header only",
+			input: "to,amount\n",
+			want:  []BatchTransferLine{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBatchCSV(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseBatchCSV: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if !g.Amount.Equal(w.Amount) {
+					t.Errorf("line %d: amount = %s, want %s", i, g.Amount, w.Amount)
+				}
+				g.Amount, w.Amount = decimal.Zero, decimal.Zero
+				if g != w {
+					t.Errorf("line %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBatchCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty file", "", "empty or malformed"},
+		{"unknown column", "to,amount,iban\n+79161234567,10,x\n", `unknown CSV column "iban"`},
+		{"missing amount column", "to,description\n+79161234567,x\n", "column amount is required"},
+		{"missing recipient column", "amount,description\n10,x\n", "to or to_account_id is required"},
+		{"invalid amount", "to,amount\n+79161234567,10\n+79161234568,ten\n", `CSV line 3: invalid amount "ten"`},
+		{"decimal comma with comma separator", "to,amount\n+79161234567,\"10,5\"\n", "invalid amount"},
+		{"wrong number of fields", "to,amount\n+79161234567,10,extra\n", "malformed CSV"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBatchCSV(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseBatchCSV error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func respondBillError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBillerNotFound):
+		respondError(w, http.StatusNotFound, "Biller not found")
+	case errors.Is(err, ErrBillerInactive):
+		respondError(w, http.StatusConflict, "Biller is not accepting payments")
+	case errors.Is(err, ErrBillTemplateNotFound):
+		respondError(w, http.StatusNotFound, "Bill template not found")
+	default:
+		respondTransferError(w, err)
+	}
+}
+
+// billerFromRequest собирает описание поставщика из запроса оператора
+func billerFromRequest(req BillerRequest) Biller {
+	fields := make([]BillerField, len(req.Fields))
+	for i, f := range req.Fields {
+		f.Label = strings.TrimSpace(f.Label)
+		fields[i] = f
+	}
+	return Biller{
+		Name:                strings.TrimSpace(req.Name),
+		Category:            req.Category,
+		INN:                 req.INN,
+		SettlementAccountID: req.SettlementAccountID,
+		Fields:              fields,
+		MinAmount:           req.MinAmount,
+		MaxAmount:           req.MaxAmount,
+		Active:              req.Active == nil || *req.Active,
+	}
+}
+
+// CreateBillerHandler добавляет поставщика услуг в каталог. Каталог ведёт оператор:
+// поставщик получает платежи клиентов на свой расчётный счёт в банке.
+func CreateBillerHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req BillerRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+	now := time.Now()
+	biller := billerFromRequest(req)
+	biller.ID, biller.CreatedAt, biller.UpdatedAt = GenerateID(), now, now
+	if err := SaveBiller(biller); err != nil {
+		respondTransferError(w, err)
+		return
+	}
+
+	log.Printf("Biller %s (%s) added to catalog by operator %s", biller.ID, biller.Name, operatorID)
+	respondJSON(w, http.StatusCreated, biller)
+}
+
+// UpdateBillerHandler заменяет описание поставщика; сохранённые шаблоны клиентов проверяются по новой схеме при оплате
+func UpdateBillerHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req BillerRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+	existing, ok := GetBiller(mux.Vars(r)["billerId"])
+	if !ok {
+		respondError(w, http.StatusNotFound, "Biller not found")
+		return
+	}
+	biller := billerFromRequest(req)
+	biller.ID, biller.CreatedAt, biller.UpdatedAt = existing.ID, existing.CreatedAt, time.Now()
+	if err := SaveBiller(biller); err != nil {
+		respondTransferError(w, err)
+		return
+	}
+
+	log.Printf("Biller %s updated by operator %s (active: %t)", biller.ID, operatorID, biller.Active)
+	respondJSON(w, http.StatusOK, biller)
+}
+
+// GetBillersHandler возвращает каталог поставщиков, принимающих платежи; ?category= фильтрует по категории
+func GetBillersHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, GetBillers(r.URL.Query().Get("category"), true))
+}
+
+// GetAllBillersHandler возвращает оператору весь каталог, включая отключённых поставщиков
+func GetAllBillersHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, GetBillers(r.URL.Query().Get("category"), false))
+}
+
+func GetBillerHandler(w http.ResponseWriter, r *http.Request) {
+	biller, ok := GetBiller(mux.Vars(r)["billerId"])
+	if !ok {
+		respondError(w, http.StatusNotFound, "Biller not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, biller)
+}
+
+// buildBillReceipt формирует квитанцию об оплате с реквизитами в порядке полей поставщика
+func buildBillReceipt(p BillPayment) BillReceipt {
+	biller, _ := GetBiller(p.BillerID)
+	acc, _ := GetAccount(p.FromAccountID)
+	receipt := BillReceipt{
+		ReceiptNumber: p.ReceiptNumber,
+		PaidAt:        p.CreatedAt,
+		BankName:      bankRequisites.Name,
+		BankBIK:       bankRequisites.BIK,
+		PayerName:     payerName(acc),
+		PayerAccount:  maskAccountNumber(acc.Number),
+		BillerName:    biller.Name,
+		BillerINN:     biller.INN,
+		Details:       make([]ReceiptDetail, 0, len(p.Fields)),
+		Amount:        p.Amount,
+		TransactionID: p.TransactionID,
+	}
+	for _, f := range biller.Fields {
+		if value, ok := p.Fields[f.Key]; ok {
+			receipt.Details = append(receipt.Details, ReceiptDetail{Label: f.Label, Value: value})
+		}
+	}
+	return receipt
+}
+
+// payBill проверяет реквизиты и сумму по схеме поставщика и проводит платёж; при ошибке сам отправляет ответ
+func payBill(w http.ResponseWriter, userID, billerID, fromAccountID string, fields map[string]string, amount decimal.Decimal, templateID string) {
+	biller, ok := GetBiller(billerID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Biller not found")
+		return
+	}
+	if !biller.Active {
+		respondBillError(w, ErrBillerInactive)
+		return
+	}
+	var errs ValidationErrors
+	normalized := validateBillFields(&errs, biller, fields, false)
+	validateBillAmount(&errs, "amount", biller, amount)
+	if len(errs) > 0 {
+		respondValidationError(w, errs)
+		return
+	}
+
+	fromAccount, err := AuthorizeAccount(fromAccountID, userID, AccountPermissionPay, amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", fromAccountID))
+		return
+	}
+	if RequiresDualApproval(fromAccount, amount) {
+		respondError(w, http.StatusConflict, "Amount exceeds the organization's dual approval threshold")
+		return
+	}
+
+	payment, err := PayBill(BillPayment{
+		ID:            GenerateID(),
+		UserID:        userID,
+		FromAccountID: fromAccount.ID,
+		BillerID:      biller.ID,
+		Fields:        normalized,
+		Amount:        amount,
+		TemplateID:    templateID,
+	}, time.Now())
+	if err != nil {
+		respondBillError(w, err)
+		return
+	}
+
+	log.Printf("Bill payment %s of %s from %s to biller %s, receipt %s", payment.ID, amount.String(), fromAccount.ID, biller.ID, payment.ReceiptNumber)
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"payment": payment,
+		"receipt": buildBillReceipt(payment),
+	})
+}
+
+// CreateBillPaymentHandler оплачивает услуги поставщика из каталога и возвращает квитанцию
+func CreateBillPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req BillPaymentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	payBill(w, userID, req.BillerID, req.FromAccountID, req.Fields, req.Amount, "")
+}
+
+func GetBillPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetUserBillPayments(userID))
+}
+
+// ownedBillPayment возвращает оплату, проведённую текущим пользователем, или отвечает 404
+func ownedBillPayment(w http.ResponseWriter, r *http.Request) (BillPayment, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return BillPayment{}, false
+	}
+	payment, ok := GetBillPayment(mux.Vars(r)["paymentId"])
+	if !ok || payment.UserID != userID {
+		respondError(w, http.StatusNotFound, "Bill payment not found")
+		return BillPayment{}, false
+	}
+	return payment, true
+}
+
+func GetBillPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	if payment, ok := ownedBillPayment(w, r); ok {
+		respondJSON(w, http.StatusOK, payment)
+	}
+}
+
+func GetBillReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	if payment, ok := ownedBillPayment(w, r); ok {
+		respondJSON(w, http.StatusOK, buildBillReceipt(payment))
+	}
+}
+
+// applyBillTemplate проверяет шаблон по схеме поставщика и заполняет его из запроса; при ошибке сам отправляет ответ
+func applyBillTemplate(w http.ResponseWriter, userID string, template *BillTemplate, req BillTemplateRequest) bool {
+	biller, ok := GetBiller(req.BillerID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Biller not found")
+		return false
+	}
+	var errs ValidationErrors
+	fields := validateBillFields(&errs, biller, req.Fields, true)
+	if !req.Amount.IsZero() {
+		validateBillAmount(&errs, "amount", biller, req.Amount)
+	}
+	if len(errs) > 0 {
+		respondValidationError(w, errs)
+		return false
+	}
+	if _, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, req.Amount); err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return false
+	}
+
+	template.Name = strings.TrimSpace(req.Name)
+	template.BillerID = biller.ID
+	template.FromAccountID = req.FromAccountID
+	template.Fields = fields
+	template.Amount = req.Amount
+	return true
+}
+
+// CreateBillTemplateHandler сохраняет шаблон для повторной оплаты услуг
+func CreateBillTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req BillTemplateRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	if len(GetUserBillTemplates(userID)) >= maxBillTemplateCount {
+		respondError(w, http.StatusConflict, fmt.Sprintf("At most %d bill templates are allowed", maxBillTemplateCount))
+		return
+	}
+
+	now := time.Now()
+	template := BillTemplate{ID: GenerateID(), UserID: userID, CreatedAt: now, UpdatedAt: now}
+	if !applyBillTemplate(w, userID, &template, req) {
+		return
+	}
+
+	SaveBillTemplate(template)
+	log.Printf("Bill template %s for biller %s created by user %s", template.ID, template.BillerID, userID)
+	respondJSON(w, http.StatusCreated, template)
+}
+
+func GetBillTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetUserBillTemplates(userID))
+}
+
+// ownedBillTemplate возвращает шаблон текущего пользователя или отвечает 404
+func ownedBillTemplate(w http.ResponseWriter, r *http.Request) (BillTemplate, string, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return BillTemplate{}, "", false
+	}
+	template, ok := GetBillTemplate(mux.Vars(r)["templateId"])
+	if !ok || template.UserID != userID {
+		respondBillError(w, ErrBillTemplateNotFound)
+		return BillTemplate{}, "", false
+	}
+	return template, userID, true
+}
+
+func GetBillTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if template, _, ok := ownedBillTemplate(w, r); ok {
+		respondJSON(w, http.StatusOK, template)
+	}
+}
+
+func UpdateBillTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req BillTemplateRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	template, userID, ok := ownedBillTemplate(w, r)
+	if !ok || !applyBillTemplate(w, userID, &template, req) {
+		return
+	}
+
+	template.UpdatedAt = time.Now()
+	SaveBillTemplate(template)
+	respondJSON(w, http.StatusOK, template)
+}
+
+func DeleteBillTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, userID, ok := ownedBillTemplate(w, r)
+	if !ok {
+		return
+	}
+	DeleteBillTemplate(template.ID)
+	log.Printf("Bill template %s deleted by user %s", template.ID, userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PayBillTemplateHandler оплачивает услуги по шаблону. Реквизиты из запроса дополняют сохранённые
+// (например, период и показания счётчиков), счёт и сумма из запроса заменяют сохранённые.
+func PayBillTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req PayBillTemplateRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	template, userID, ok := ownedBillTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	fields := make(map[string]string, len(template.Fields)+len(req.Fields))
+	for key, value := range template.Fields {
+		fields[key] = value
+	}
+	for key, value := range req.Fields {
+		fields[key] = value
+	}
+	fromAccountID := template.FromAccountID
+	if req.FromAccountID != "" {
+		fromAccountID = req.FromAccountID
+	}
+	amount := template.Amount
+	if !req.Amount.IsZero() {
+		amount = req.Amount
+	}
+	if amount.IsZero() {
+		respondValidationError(w, ValidationErrors{{Field: "amount", Message: "is required when the template has no amount"}})
+		return
+	}
+	payBill(w, userID, template.BillerID, fromAccountID, fields, amount, template.ID)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Страна банка-эмитента: операции в других странах считаются зарубежными
+const homeCountry = "RU"
+
+// Коды отказа по ограничениям карты
+const (
+	DeclinePerTransactionLimit = "per_transaction_limit_exceeded"
+	DeclineDailyLimit          = "daily_limit_exceeded"
+	DeclineMonthlyLimit        = "monthly_limit_exceeded"
+	DeclineMCCNotAllowed       = "mcc_not_allowed"
+	DeclineMCCBlocked          = "mcc_blocked"
+	DeclineOnlineDisabled      = "online_disabled"
+	DeclineContactlessDisabled = "contactless_disabled"
+	DeclineForeignDisabled     = "foreign_disabled"
+)
+
+// CardUse описывает параметры карточной операции, по которым проверяются ограничения карты
+type CardUse struct {
+	Amount     decimal.Decimal
+	MCC        string
+	Channel    string
+	Country    string
+	MerchantID string
+	TokenID    string // токен, которым оплачивают вместо реквизитов карты
+	Operation  string // тип операции для лимитов клиента; по умолчанию card_payment
+}
+
+func DefaultCardControls() CardControls {
+	return CardControls{
+		OnlineEnabled:      true,
+		ContactlessEnabled: true,
+		ForeignEnabled:     true,
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cardSpending возвращает сумму оплат по карте за период (день или месяц) за вычетом возвратов,
+// включая удерживаемые авторизации. Вызывается под storage.mu.
+func cardSpending(cardID, period string) decimal.Decimal {
+	return limitUsage(cardLimitSubject(cardID), LimitOpCardPayment, "", period)
+}
+
+// checkCardControls применяет к операции ограничения карты. Вызывается под storage.mu.
+func checkCardControls(card Card, use CardUse, now time.Time) error {
+	c := card.Controls
+
+	switch use.Channel {
+	case CardChannelOnline:
+		if !c.OnlineEnabled {
+			return declinePayment(http.StatusForbidden, DeclineOnlineDisabled, "Online payments are disabled for this card")
+		}
+	case CardChannelContactless:
+		if !c.ContactlessEnabled {
+			return declinePayment(http.StatusForbidden, DeclineContactlessDisabled, "Contactless payments are disabled for this card")
+		}
+	}
+	if use.Country != homeCountry && !c.ForeignEnabled {
+		return declinePayment(http.StatusForbidden, DeclineForeignDisabled, "Foreign payments are disabled for this card")
+	}
+
+	// Без кода категории нельзя проверить ограничения по MCC, поэтому такая операция отклоняется
+	if use.MCC == "" && (len(c.AllowedMCCs) > 0 || len(c.BlockedMCCs) > 0) {
+		return declinePayment(http.StatusForbidden, DeclineMCCNotAllowed, "Merchant category is required for this card")
+	}
+	if len(c.AllowedMCCs) > 0 && !containsString(c.AllowedMCCs, use.MCC) {
+		return declinePayment(http.StatusForbidden, DeclineMCCNotAllowed, fmt.Sprintf("Merchant category %s is not allowed for this card", use.MCC))
+	}
+	if containsString(c.BlockedMCCs, use.MCC) {
+		return declinePayment(http.StatusForbidden, DeclineMCCBlocked, fmt.Sprintf("Merchant category %s is blocked for this card", use.MCC))
+	}
+
+	if c.PerTransactionLimit.IsPositive() && use.Amount.GreaterThan(c.PerTransactionLimit) {
+		return declinePayment(http.StatusForbidden, DeclinePerTransactionLimit,
+			fmt.Sprintf("Amount exceeds per-transaction limit of %s", c.PerTransactionLimit.String()))
+	}
+	if c.DailyLimit.IsPositive() {
+		if cardSpending(card.ID, now.Format(limitDayLayout)).Add(use.Amount).GreaterThan(c.DailyLimit) {
+			return declinePayment(http.StatusForbidden, DeclineDailyLimit,
+				fmt.Sprintf("Amount exceeds daily limit of %s", c.DailyLimit.String()))
+		}
+	}
+	if c.MonthlyLimit.IsPositive() {
+		if cardSpending(card.ID, now.Format(limitMonthLayout)).Add(use.Amount).GreaterThan(c.MonthlyLimit) {
+			return declinePayment(http.StatusForbidden, DeclineMonthlyLimit,
+				fmt.Sprintf("Amount exceeds monthly limit of %s", c.MonthlyLimit.String()))
+		}
+	}
+	return nil
+}
+
+// ownedCard возвращает карту, если пользователь владеет её счётом или имеет на него полный доступ
+func ownedCard(cardID, userID string) (Card, bool) {
+	card, ok := GetCardByID(cardID)
+	if !ok {
+		return Card{}, false
+	}
+	if _, err := AuthorizeAccount(card.AccountID, userID, AccountPermissionFull, decimal.Zero); err != nil {
+		return Card{}, false
+	}
+	return card, true
+}
+
+func GetCardControlsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, card.Controls)
+}
+
+// UpdateCardControlsHandler меняет только переданные в запросе ограничения карты, остальные сохраняются
+func UpdateCardControlsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req UpdateCardControlsRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+
+	controls, err := SetCardControls(card.ID, req)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update card controls: %v", err))
+		return
+	}
+
+	log.Printf("Card controls updated for card %s", card.ID)
+	respondJSON(w, http.StatusOK, controls)
+}
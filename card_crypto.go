@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// cardEnvelope — конвертное шифрование номеров карт. Если не настроено, номера шифруются PGP через keyManager.
+var cardEnvelope *EnvelopeCipher
+
+// cardReencrypting не даёт запустить две перешифровки карт одновременно
+var cardReencrypting atomic.Bool
+
+// InitCardEnvelope включает конвертное шифрование: CARD_MASTER_KEY_PATH — файлы мастер-ключей через запятую
+// (первый активный), либо CARD_KMS=local — локальный заменитель KMS с ключами в памяти
+func InitCardEnvelope() error {
+	var provider MasterKeyProvider
+	switch {
+	case os.Getenv("CARD_MASTER_KEY_PATH") != "":
+		var paths []string
+		for _, path := range strings.Split(os.Getenv("CARD_MASTER_KEY_PATH"), ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		fileProvider, err := NewFileMasterKeyProvider(paths)
+		if err != nil {
+			return err
+		}
+		provider = fileProvider
+	case os.Getenv("CARD_KMS") == "local":
+		kms, err := NewLocalKMS()
+		if err != nil {
+			return err
+		}
+		log.Printf("Warning: using local in-memory KMS, card numbers will not be readable after restart")
+		provider = kms
+	default:
+		return nil
+	}
+
+	cardEnvelope = NewEnvelopeCipher(provider)
+	return nil
+}
+
+// cardNumberAAD привязывает шифртекст номера к карте: перенос шифртекста в другую карту не расшифруется
+func cardNumberAAD(cardID string) string {
+	return "card:" + cardID + ":number"
+}
+
+// currentCardKeyID — версия ключа, которой должны быть зашифрованы номера всех карт
+func currentCardKeyID() (string, error) {
+	if cardEnvelope != nil {
+		return cardEnvelope.ActiveKeyID(), nil
+	}
+	km, err := activeKeyManager()
+	if err != nil {
+		return "", err
+	}
+	return km.ActiveKeyID(), nil
+}
+
+// encryptCardNumber шифрует номер карты текущим способом и запоминает версию ключа в карте
+func encryptCardNumber(card *Card, pan string) error {
+	if card.ID == "" {
+		return errors.New("card ID is required to encrypt card number")
+	}
+
+	var number, keyID string
+	var err error
+	if cardEnvelope != nil {
+		number, keyID, err = cardEnvelope.Encrypt(pan, cardNumberAAD(card.ID))
+	} else {
+		km, kmErr := activeKeyManager()
+		if kmErr != nil {
+			return kmErr
+		}
+		number, keyID, err = km.Encrypt(pan)
+	}
+	if err != nil {
+		return err
+	}
+	card.Number = number
+	card.KeyID = keyID
+	return nil
+}
+
+// decryptCardNumber расшифровывает номер карты; старые PGP-шифртексты расшифровываются через keyManager
+func decryptCardNumber(card Card) (string, error) {
+	if isEnvelopeCiphertext(card.Number) {
+		if cardEnvelope == nil {
+			return "", errors.New("envelope encryption is not configured")
+		}
+		return cardEnvelope.Decrypt(card.Number, cardNumberAAD(card.ID))
+	}
+	km, err := activeKeyManager()
+	if err != nil {
+		return "", err
+	}
+	return km.Decrypt(card.Number, card.KeyID)
+}
+
+// ReencryptCards перешифровывает номера карт, зашифрованные не текущей версией ключа:
+// после ротации PGP-ключа или мастер-ключа и при переходе с PGP на конвертное шифрование.
+// Карта, изменённая во время перешифровки, пропускается и будет обработана при следующем запуске.
+func ReencryptCards() (int, error) {
+	activeKeyID, err := currentCardKeyID()
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted, failed := 0, 0
+	for _, card := range GetCardsNotEncryptedWith(activeKeyID) {
+		pan, err := decryptCardNumber(card)
+		if err != nil {
+			log.Printf("Failed to decrypt card %s for re-encryption: %v", card.ID, err)
+			failed++
+			continue
+		}
+		updated := card
+		if err := encryptCardNumber(&updated, pan); err != nil {
+			log.Printf("Failed to re-encrypt card %s: %v", card.ID, err)
+			failed++
+			continue
+		}
+		if ReplaceCardNumber(card.ID, card.Number, updated.Number, updated.KeyID) {
+			reencrypted++
+		}
+	}
+	if failed > 0 {
+		return reencrypted, fmt.Errorf("%d cards could not be re-encrypted", failed)
+	}
+	return reencrypted, nil
+}
+
+// StartCardReencryption запускает перешифровку карт в фоне, если она ещё не идёт
+func StartCardReencryption() {
+	if !cardReencrypting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer cardReencrypting.Store(false)
+		n, err := ReencryptCards()
+		if n > 0 {
+			keyID, _ := currentCardKeyID()
+			log.Printf("Re-encrypted %d cards with key %s", n, keyID)
+		}
+		if err != nil {
+			log.Printf("Card re-encryption incomplete: %v", err)
+		}
+	}()
+}
+
+func respondKeyStatus(w http.ResponseWriter) {
+	status := map[string]interface{}{
+		"cards_by_key_id":      CountCardsByKeyID(),
+		"reencryption_running": cardReencrypting.Load(),
+		"encryption":           "pgp",
+	}
+	if activeKeyID, err := currentCardKeyID(); err == nil {
+		status["active_key_id"] = activeKeyID
+	}
+	if cardEnvelope != nil {
+		status["encryption"] = "envelope"
+	}
+	if km, err := activeKeyManager(); err == nil {
+		status["pgp_key_ids"] = km.KeyIDs()
+	}
+	respondJSON(w, http.StatusOK, status)
+}
+
+// GetKeyStatusHandler показывает активную версию ключа и распределение карт по версиям:
+// выведенный ключ можно удалять, когда им не зашифровано ни одной карты
+func GetKeyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	respondKeyStatus(w)
+}
+
+// ReloadKeysHandler перечитывает PGP-ключи без перезапуска сервиса и запускает перешифровку карт
+func ReloadKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if km, err := activeKeyManager(); err == nil {
+		if err := km.Reload(); err != nil {
+			respondError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Failed to reload keys: %v", err))
+			return
+		}
+		log.Printf("PGP keys reloaded by operator, active key %s", km.ActiveKeyID())
+	}
+	StartCardReencryption()
+	respondKeyStatus(w)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownHMACVersion = errors.New("card HMAC key version is not configured")
+	ErrCardMACMismatch    = errors.New("card HMAC does not match card data")
+)
+
+// Минимальная длина секретов целостности карт
+const minIntegrityKeyLength = 32
+
+// cardHMACKeys — версии ключей HMAC карт; активной версией подписываются все изменения карт
+var cardHMACKeys = struct {
+	active string
+	keys   map[string][]byte
+}{keys: map[string][]byte{}}
+
+// panFingerprintKey — ключ отпечатков PAN. Не ротируется: отпечатки служат индексом поиска карты по номеру.
+var panFingerprintKey []byte
+
+// InitCardHMACKeys загружает ключи целостности карт из окружения:
+// CARD_HMAC_KEYS — версии ключей в виде "версия:секрет" через запятую,
+// CARD_HMAC_ACTIVE_VERSION — версия для подписи (обязательна, если версий несколько),
+// CARD_PAN_FINGERPRINT_KEY — ключ отпечатков PAN
+func InitCardHMACKeys() error {
+	keys := make(map[string][]byte)
+	var versions []string
+	for _, entry := range strings.Split(os.Getenv("CARD_HMAC_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, secret, ok := strings.Cut(entry, ":")
+		if !ok || version == "" {
+			return fmt.Errorf("CARD_HMAC_KEYS entry must look like version:secret")
+		}
+		if len(secret) < minIntegrityKeyLength {
+			return fmt.Errorf("card HMAC key %s must be at least %d characters", version, minIntegrityKeyLength)
+		}
+		if _, exists := keys[version]; exists {
+			return fmt.Errorf("card HMAC key version %s is duplicated", version)
+		}
+		keys[version] = []byte(secret)
+		versions = append(versions, version)
+	}
+	if len(keys) == 0 {
+		return errors.New("CARD_HMAC_KEYS not set")
+	}
+
+	active := os.Getenv("CARD_HMAC_ACTIVE_VERSION")
+	if active == "" {
+		if len(versions) > 1 {
+			return errors.New("CARD_HMAC_ACTIVE_VERSION must be set when several card HMAC keys are configured")
+		}
+		active = versions[0]
+	}
+	if _, ok := keys[active]; !ok {
+		return fmt.Errorf("CARD_HMAC_ACTIVE_VERSION %s is not listed in CARD_HMAC_KEYS", active)
+	}
+
+	fingerprintKey := []byte(os.Getenv("CARD_PAN_FINGERPRINT_KEY"))
+	if len(fingerprintKey) < minIntegrityKeyLength {
+		return fmt.Errorf("CARD_PAN_FINGERPRINT_KEY must be at least %d characters", minIntegrityKeyLength)
+	}
+	for version, key := range keys {
+		if bytes.Equal(key, fingerprintKey) {
+			return fmt.Errorf("CARD_PAN_FINGERPRINT_KEY must differ from card HMAC key %s", version)
+		}
+	}
+
+	cardHMACKeys.active = active
+	cardHMACKeys.keys = keys
+	panFingerprintKey = fingerprintKey
+	return nil
+}
+
+// isCardIntegrityKey сообщает, совпадает ли ключ с одним из ключей целостности карт
+func isCardIntegrityKey(key []byte) bool {
+	if bytes.Equal(key, panFingerprintKey) {
+		return true
+	}
+	for _, k := range cardHMACKeys.keys {
+		if bytes.Equal(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// cardMACData сериализует все поля карты, влияющие на безопасность: подмена любого из них обнаруживается
+func cardMACData(card Card) string {
+	controls, _ := json.Marshal(card.Controls)
+	fields := []string{
+		card.ID,
+		card.AccountID,
+		card.Number,
+		card.KeyID,
+		card.CVV,
+		fmt.Sprintf("%02d%04d", card.ExpiryMonth, card.ExpiryYear),
+		card.Type,
+		card.Status,
+		card.LockedMerchantID,
+		card.PANFingerprint,
+		card.PINHash,
+		fmt.Sprintf("%d:%t", card.PINAttempts, card.PINBlocked),
+		string(controls),
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		// Префикс длины исключает неоднозначность при склейке полей
+		fmt.Fprintf(&b, "%d:%s;", len(f), f)
+	}
+	return b.String()
+}
+
+// signCard подписывает карту активной версией ключа. Вызывается при каждом изменении карты.
+func signCard(card *Card) {
+	card.HMACVersion = cardHMACKeys.active
+	card.HMAC = GenerateHMAC(cardMACData(*card), cardHMACKeys.keys[card.HMACVersion])
+}
+
+// verifyCardHMAC проверяет подпись карты ключом той версии, которой она подписана
+func verifyCardHMAC(card Card) error {
+	key, ok := cardHMACKeys.keys[card.HMACVersion]
+	if !ok {
+		return ErrUnknownHMACVersion
+	}
+	expected := GenerateHMAC(cardMACData(card), key)
+	if !hmac.Equal([]byte(expected), []byte(card.HMAC)) {
+		return ErrCardMACMismatch
+	}
+	return nil
+}
+
+// CardIntegrityIssue — карта, не прошедшая проверку целостности
+type CardIntegrityIssue struct {
+	CardID      string `json:"card_id"`
+	AccountID   string `json:"account_id"`
+	HMACVersion string `json:"hmac_version"`
+	Reason      string `json:"reason"`
+}
+
+type CardIntegrityReport struct {
+	ScannedAt     time.Time            `json:"scanned_at"`
+	Scanned       int                  `json:"scanned"`
+	ActiveVersion string               `json:"active_version"`
+	ByVersion     map[string]int       `json:"by_version"`
+	Resigned      int                  `json:"resigned,omitempty"`
+	Tampered      []CardIntegrityIssue `json:"tampered"`
+}
+
+// RotateCardHMACs переподписывает активной версией ключа карты, подписанные другими версиями.
+// Карты с неверной подписью не переподписываются, чтобы не узаконить подмену, и попадают в отчёт.
+func RotateCardHMACs(now time.Time) CardIntegrityReport {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	return scanCards(now, true)
+}
+
+// ScanCardIntegrity проверяет подписи всех карт без изменений
+func ScanCardIntegrity(now time.Time) CardIntegrityReport {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	return scanCards(now, false)
+}
+
+// scanCards вызывается под storage.mu (на запись, если resign)
+func scanCards(now time.Time, resign bool) CardIntegrityReport {
+	report := CardIntegrityReport{
+		ScannedAt:     now,
+		ActiveVersion: cardHMACKeys.active,
+		ByVersion:     make(map[string]int),
+		Tampered:      make([]CardIntegrityIssue, 0),
+	}
+	for _, card := range storage.cards {
+		report.Scanned++
+		if err := verifyCardHMAC(card); err != nil {
+			report.ByVersion[card.HMACVersion]++
+			report.Tampered = append(report.Tampered, CardIntegrityIssue{
+				CardID:      card.ID,
+				AccountID:   card.AccountID,
+				HMACVersion: card.HMACVersion,
+				Reason:      err.Error(),
+			})
+			continue
+		}
+		if resign && card.HMACVersion != cardHMACKeys.active {
+			signCard(&card)
+			storage.cards[card.ID] = card
+			report.Resigned++
+		}
+		report.ByVersion[card.HMACVersion]++
+	}
+	sort.Slice(report.Tampered, func(i, j int) bool {
+		return report.Tampered[i].CardID < report.Tampered[j].CardID
+	})
+	return report
+}
+
+// logCardIntegrityReport пишет в лог результат ротации или проверки
+func logCardIntegrityReport(report CardIntegrityReport) {
+	if report.Resigned > 0 {
+		log.Printf("Re-signed %d cards with HMAC key version %s", report.Resigned, report.ActiveVersion)
+	}
+	for _, issue := range report.Tampered {
+		log.Printf("Card integrity violation: card %s (account %s): %s", issue.CardID, issue.AccountID, issue.Reason)
+	}
+}
+
+// CardIntegrityScanHandler проверяет подписи всех карт и возвращает список подменённых записей
+func CardIntegrityScanHandler(w http.ResponseWriter, r *http.Request) {
+	report := ScanCardIntegrity(time.Now())
+	logCardIntegrityReport(report)
+	respondJSON(w, http.StatusOK, report)
+}
+
+// RotateCardHMACsHandler переподписывает карты активной версией ключа
+func RotateCardHMACsHandler(w http.ResponseWriter, r *http.Request) {
+	report := RotateCardHMACs(time.Now())
+	logCardIntegrityReport(report)
+	respondJSON(w, http.StatusOK, report)
+}
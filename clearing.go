@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Пространство имён электронных документов; формат пакета повторяет PacketEPD/ED101 УФЭБС
+const edNamespace = "urn:cbr-ru:ed:v2.0"
+
+// Реквизиты банка по умолчанию; переопределяются переменными BANK_BIK, BANK_CORR_ACCOUNT и BANK_NAME
+const (
+	defaultBankBIK         = "044525999"
+	defaultBankCorrAccount = "30101810845250000999"
+	defaultBankName        = "Банк"
+)
+
+// BankRequisites — реквизиты банка-отправителя в платёжных поручениях
+type BankRequisites struct {
+	BIK         string
+	CorrAccount string
+	Name        string
+}
+
+// ClearingStatus — ответ клиринга по одному документу пакета
+type ClearingStatus struct {
+	EDNo     int
+	Accepted bool
+	Reason   string // причина отказа
+}
+
+// ClearingGateway — платёжная система, через которую идут платежи в другие банки
+type ClearingGateway interface {
+	// Submit передаёт пакет ED101 и возвращает статусы по документам пакета
+	Submit(packet []byte) ([]ClearingStatus, error)
+	// FetchIncoming возвращает поступления в пользу клиентов банка, полученные с прошлого вызова
+	FetchIncoming() ([]IncomingPayment, error)
+}
+
+var (
+	bankRequisites  BankRequisites
+	clearingGateway ClearingGateway
+	clearingMu      sync.Mutex // отправка пакетов и приём поступлений выполняются по одному
+)
+
+// InitClearing загружает реквизиты банка и подключает клиринг. Настоящего подключения к платёжной
+// системе нет, поэтому используется локальный симулятор.
+func InitClearing() error {
+	bankRequisites = BankRequisites{
+		BIK:         envOrDefault("BANK_BIK", defaultBankBIK),
+		CorrAccount: envOrDefault("BANK_CORR_ACCOUNT", defaultBankCorrAccount),
+		Name:        envOrDefault("BANK_NAME", defaultBankName),
+	}
+	var errs ValidationErrors
+	validateBIK(&errs, "BANK_BIK", bankRequisites.BIK)
+	validateDigits(&errs, "BANK_CORR_ACCOUNT", bankRequisites.CorrAccount, 20, 20)
+	if len(errs) > 0 {
+		return errs
+	}
+	clearingGateway = NewClearingSimulator(bankRequisites.BIK)
+	return nil
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+type edPacket struct {
+	XMLName    xml.Name `xml:"urn:cbr-ru:ed:v2.0 PacketEPD"`
+	EDNo       int      `xml:"EDNo,attr"`
+	EDDate     string   `xml:"EDDate,attr"`
+	EDAuthor   string   `xml:"EDAuthor,attr"`
+	EDQuantity int      `xml:"EDQuantity,attr"`
+	Sum        int64    `xml:"Sum,attr"` // в копейках
+	SystemCode string   `xml:"SystemCode,attr"`
+	Documents  []ed101  `xml:"ED101"`
+}
+
+// ed101 — платёжное поручение
+type ed101 struct {
+	EDNo      int      `xml:"EDNo,attr"`
+	EDDate    string   `xml:"EDDate,attr"`
+	EDAuthor  string   `xml:"EDAuthor,attr"`
+	TransKind string   `xml:"TransKind,attr"` // 01 — платёжное поручение
+	Priority  string   `xml:"Priority,attr"`  // очерёдность платежа
+	Sum       int64    `xml:"Sum,attr"`
+	AccDoc    edAccDoc `xml:"AccDoc"`
+	Payer     edParty  `xml:"Payer"`
+	Payee     edParty  `xml:"Payee"`
+	Purpose   string   `xml:"Purpose"`
+}
+
+type edAccDoc struct {
+	AccDocNo   string `xml:"AccDocNo,attr"`
+	AccDocDate string `xml:"AccDocDate,attr"`
+}
+
+type edParty struct {
+	INN         string `xml:"INN,attr,omitempty"`
+	KPP         string `xml:"KPP,attr,omitempty"`
+	PersonalAcc string `xml:"PersonalAcc,attr"`
+	Name        string `xml:"Name"`
+	Bank        edBank `xml:"Bank"`
+}
+
+type edBank struct {
+	BIC        string `xml:"BIC,attr"`
+	CorrespAcc string `xml:"CorrespAcc,attr,omitempty"`
+}
+
+// payerParty возвращает реквизиты плательщика: для счёта организации — её наименование, ИНН и КПП.
+// Номер счёта с ключом, не сходящимся с БИК банка, получатель отвергнет, поэтому такой документ не формируется.
+func payerParty(acc Account) (edParty, error) {
+	if !accountKeyValid(bankRequisites.BIK, acc.Number) {
+		return edParty{}, fmt.Errorf("%w: %s", ErrInvalidAccountKey, acc.Number)
+	}
+	party := edParty{
+		PersonalAcc: acc.Number,
+		Bank:        edBank{BIC: bankRequisites.BIK, CorrespAcc: bankRequisites.CorrAccount},
+	}
+	if org, ok := GetOrganization(acc.OrganizationID); ok {
+		party.Name, party.INN, party.KPP = org.Name, org.INN, org.KPP
+		return party, nil
+	}
+	party.Name = payerName(acc)
+	return party, nil
+}
+
+// payerName возвращает имя владельца счёта для платёжных документов
+func payerName(acc Account) string {
+	if org, ok := GetOrganization(acc.OrganizationID); ok {
+		return org.Name
+	}
+	user, _ := GetUser(acc.UserID)
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	return user.Username
+}
+
+// BuildClearingPacket формирует файл пакета в формате PacketEPD с документами ED101
+func BuildClearingPacket(batch ClearingBatch, payments []ExternalPayment) ([]byte, error) {
+	date := batch.CreatedAt.Format("2006-01-02")
+	packet := edPacket{
+		EDNo:       batch.EDNo,
+		EDDate:     date,
+		EDAuthor:   bankRequisites.BIK,
+		EDQuantity: len(payments),
+		Sum:        batch.TotalAmount.Shift(2).IntPart(),
+		SystemCode: "01",
+		Documents:  make([]ed101, 0, len(payments)),
+	}
+	for _, p := range payments {
+		acc, ok := GetAccount(p.FromAccountID)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, p.FromAccountID)
+		}
+		payer, err := payerParty(acc)
+		if err != nil {
+			return nil, err
+		}
+		packet.Documents = append(packet.Documents, ed101{
+			EDNo:      p.EDNo,
+			EDDate:    date,
+			EDAuthor:  bankRequisites.BIK,
+			TransKind: "01",
+			Priority:  "5",
+			Sum:       p.Amount.Shift(2).IntPart(),
+			AccDoc:    edAccDoc{AccDocNo: fmt.Sprintf("%d", p.EDNo), AccDocDate: p.CreatedAt.Format("2006-01-02")},
+			Payer:     payer,
+			Payee: edParty{
+				INN:         p.PayeeINN,
+				KPP:         p.PayeeKPP,
+				PersonalAcc: p.PayeeAccount,
+				Name:        p.PayeeName,
+				Bank:        edBank{BIC: p.PayeeBIK},
+			},
+			Purpose: p.Purpose,
+		})
+	}
+
+	data, err := xml.MarshalIndent(packet, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// SubmitClearingBatch отправляет пакет в клиринг и проводит полученные статусы.
+// При ошибке отправки пакет остаётся сформированным и может быть отправлен повторно.
+func SubmitClearingBatch(batchID string, now time.Time) (ClearingBatch, error) {
+	clearingMu.Lock()
+	defer clearingMu.Unlock()
+
+	batch, ok := GetClearingBatch(batchID)
+	if !ok {
+		return ClearingBatch{}, ErrClearingBatchNotFound
+	}
+	if batch.Status == ClearingBatchProcessed {
+		return ClearingBatch{}, ErrBatchAlreadyProcessed
+	}
+	packet, err := BuildClearingPacket(batch, GetBatchPayments(batch))
+	if err != nil {
+		return ClearingBatch{}, err
+	}
+	statuses, err := clearingGateway.Submit(packet)
+	if err != nil {
+		RecordClearingError(batch.ID, err)
+		return ClearingBatch{}, fmt.Errorf("clearing submission failed: %w", err)
+	}
+	return ApplyClearingStatuses(batch.ID, statuses, now)
+}
+
+// FetchIncomingPayments получает поступления из клиринга и зачисляет их на счета клиентов
+func FetchIncomingPayments(now time.Time) ([]IncomingPayment, error) {
+	clearingMu.Lock()
+	defer clearingMu.Unlock()
+
+	incoming, err := clearingGateway.FetchIncoming()
+	if err != nil {
+		return nil, err
+	}
+	applied := make([]IncomingPayment, 0, len(incoming))
+	for _, in := range incoming {
+		in = ApplyIncomingPayment(in, now)
+		if in.Status == IncomingPaymentReturned {
+			log.Printf("Incoming payment %d from %s to unknown or closed account %s marked for return", in.EDNo, in.PayerBIK, in.PayeeAccount)
+		}
+		applied = append(applied, in)
+	}
+	return applied, nil
+}
+
+// RunClearingCycle — клиринговый рейс: поручения из очереди собираются в пакет, все неотправленные
+// пакеты отправляются, затем принимаются поступления. Возвращает число отправленных поручений и поступлений.
+func RunClearingCycle(now time.Time) (sent, received int) {
+	if _, err := FormClearingBatch(now); err != nil && !errors.Is(err, ErrNothingToClear) {
+		log.Printf("Failed to form clearing batch: %v", err)
+	}
+	for _, batch := range GetClearingBatches() {
+		if batch.Status != ClearingBatchFormed {
+			continue
+		}
+		if _, err := SubmitClearingBatch(batch.ID, now); err != nil {
+			log.Printf("Clearing batch %s: %v", batch.ID, err)
+			continue
+		}
+		sent += batch.Count
+	}
+	incoming, err := FetchIncomingPayments(now)
+	if err != nil {
+		log.Printf("Failed to fetch incoming payments: %v", err)
+	}
+	return sent, len(incoming)
+}
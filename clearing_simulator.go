@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"sync"
+)
+
+// Справочник участников расчётов симулятора: БИК -> наименование банка
+var simulatorParticipants = map[string]string{
+	"044525225": "ПАО Сбербанк",
+	"044525593": "АО «Альфа-Банк»",
+	"044525974": "АО «ТБанк»",
+	"044525187": "Банк ВТБ (ПАО)",
+	"044525823": "Банк ГПБ (АО)",
+}
+
+// ClearingSimulator — локальная замена платёжной системы. Поручения в банки из справочника
+// исполняются, остальные отвергаются; поступления задаются вручную через EnqueueIncoming.
+type ClearingSimulator struct {
+	mu       sync.Mutex
+	ownBIK   string
+	incoming []IncomingPayment
+	lastEDNo int
+}
+
+func NewClearingSimulator(ownBIK string) *ClearingSimulator {
+	return &ClearingSimulator{ownBIK: ownBIK}
+}
+
+// Submit разбирает пакет так же, как платёжная система: пакет с неверными контрольными суммами
+// не принимается целиком, по каждому документу возвращается свой статус
+func (s *ClearingSimulator) Submit(packet []byte) ([]ClearingStatus, error) {
+	var p edPacket
+	if err := xml.Unmarshal(packet, &p); err != nil {
+		return nil, errors.New("malformed packet")
+	}
+	if p.EDAuthor != s.ownBIK {
+		return nil, errors.New("packet author does not match participant BIK")
+	}
+	var sum int64
+	for _, doc := range p.Documents {
+		sum += doc.Sum
+	}
+	if p.EDQuantity != len(p.Documents) || p.Sum != sum {
+		return nil, errors.New("packet control totals do not match its documents")
+	}
+
+	statuses := make([]ClearingStatus, 0, len(p.Documents))
+	for _, doc := range p.Documents {
+		status := ClearingStatus{EDNo: doc.EDNo, Accepted: true}
+		switch bik, account := doc.Payee.Bank.BIC, doc.Payee.PersonalAcc; {
+		case bik == s.ownBIK:
+			status.Accepted, status.Reason = false, "payee bank is the sender"
+		case simulatorParticipants[bik] == "":
+			status.Accepted, status.Reason = false, "BIK not found in participant directory"
+		case len(account) != 20 || !accountKeyValid(bik, account):
+			status.Accepted, status.Reason = false, "invalid payee account control key"
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// EnqueueIncoming добавляет поступление, которое будет передано банку при следующем FetchIncoming
+func (s *ClearingSimulator) EnqueueIncoming(in IncomingPayment) IncomingPayment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEDNo++
+	in.EDNo = s.lastEDNo
+	s.incoming = append(s.incoming, in)
+	return in
+}
+
+func (s *ClearingSimulator) FetchIncoming() ([]IncomingPayment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incoming := s.incoming
+	s.incoming = nil
+	return incoming, nil
+}
@@ -0,0 +1,112 @@
+// Проверка целостности карт: запрашивает у работающего сервиса проверку HMAC всех карт (или ротацию подписей)
+// и печатает отчёт. Код выхода 1 — найдены подменённые карты, 2 — ошибка запуска или запроса.
+//
+//	BANK_OPERATOR_TOKEN=<JWT оператора> go run ./cmd/card-integrity-scan -url http://localhost:8080
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// report повторяет ответ GET /api/ops/integrity/cards
+type report struct {
+	ScannedAt     time.Time      `json:"scanned_at"`
+	Scanned       int            `json:"scanned"`
+	ActiveVersion string         `json:"active_version"`
+	ByVersion     map[string]int `json:"by_version"`
+	Resigned      int            `json:"resigned,omitempty"`
+	Tampered      []struct {
+		CardID      string `json:"card_id"`
+		AccountID   string `json:"account_id"`
+		HMACVersion string `json:"hmac_version"`
+		Reason      string `json:"reason"`
+	} `json:"tampered"`
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "bank API base URL")
+	rotate := flag.Bool("rotate", false, "re-sign cards with the active HMAC key version instead of a read-only scan")
+	asJSON := flag.Bool("json", false, "print the raw JSON report")
+	flag.Parse()
+
+	token := os.Getenv("BANK_OPERATOR_TOKEN")
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "BANK_OPERATOR_TOKEN must contain an operator JWT")
+		os.Exit(2)
+	}
+
+	rep, err := fetchReport(strings.TrimRight(*baseURL, "/"), token, *rotate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "integrity scan: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		printReport(rep)
+	}
+	if len(rep.Tampered) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fetchReport(baseURL, token string, rotate bool) (report, error) {
+	method, path := http.MethodGet, "/api/ops/integrity/cards"
+	if rotate {
+		method, path = http.MethodPost, "/api/ops/integrity/cards/rotate"
+	}
+	req, err := http.NewRequest(method, baseURL+path, nil)
+	if err != nil {
+		return report{}, err
+	}
+	req.Header.Set("Authorization", token)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return report{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return report{}, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	var rep report
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		return report{}, fmt.Errorf("decode report: %w", err)
+	}
+	return rep, nil
+}
+
+func printReport(rep report) {
+	fmt.Printf("Scanned %d cards at %s, active key version %s\n", rep.Scanned, rep.ScannedAt.Format(time.RFC3339), rep.ActiveVersion)
+	versions := make([]string, 0, len(rep.ByVersion))
+	for v := range rep.ByVersion {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	for _, v := range versions {
+		fmt.Printf("   version %-8s %d cards\n", v, rep.ByVersion[v])
+	}
+	if rep.Resigned > 0 {
+		fmt.Printf("Re-signed %d cards\n", rep.Resigned)
+	}
+	if len(rep.Tampered) == 0 {
+		fmt.Println("No integrity violations found")
+		return
+	}
+	fmt.Printf("%d cards failed the integrity check:\n", len(rep.Tampered))
+	for _, issue := range rep.Tampered {
+		fmt.Printf("   card %s (account %s, key version %s): %s\n", issue.CardID, issue.AccountID, issue.HMACVersion, issue.Reason)
+	}
+}
@@ -0,0 +1,150 @@
+// Тестовый клиент ISO 8583: отправляет сообщения из JSON-файлов в шлюз банка и печатает ответы.
+//
+//	go run ./cmd/iso8583-client -addr localhost:8583 -pan 4000001234567899 -expiry 3012 -cvv 123 \
+//		-acceptor MRC123456789012 -mac-key <terminal_mac_key> testdata/iso8583/*.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"Banks/iso8583"
+)
+
+// fixture описывает одно сообщение: MTI, поля и (опционально) PIN в открытом виде для поля 52
+type fixture struct {
+	MTI    string            `json:"mti"`
+	Fields map[string]string `json:"fields"`
+	PIN    string            `json:"pin,omitempty"`
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8583", "gateway address")
+	pan := flag.String("pan", "", "card number substituted into field 2 when the fixture leaves it empty")
+	expiry := flag.String("expiry", "", "card expiry (YYMM) substituted into field 14 when the fixture leaves it empty")
+	cvv := flag.String("cvv", "", "CVV2 substituted into field 48 when the fixture leaves it empty")
+	acceptor := flag.String("acceptor", "", "card acceptor ID substituted into field 42 when the fixture leaves it empty")
+	macKey := flag.String("mac-key", "", "terminal MAC key of the merchant issued at merchant creation")
+	flag.Parse()
+
+	if flag.NArg() == 0 || *macKey == "" {
+		fmt.Fprintln(os.Stderr, "usage: iso8583-client [-addr host:port] [-pan PAN] [-expiry YYMM] [-cvv CVV] [-acceptor ID] -mac-key KEY fixture.json...")
+		os.Exit(2)
+	}
+
+	conn, err := net.DialTimeout("tcp", *addr, 5*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	for _, path := range flag.Args() {
+		req, err := loadFixture(path, substitutions{2: *pan, 14: *expiry, 42: *acceptor, 48: *cvv}, []byte(*macKey))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+		resp, err := exchange(conn, req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("== %s\n", path)
+		printMessage(">>", req)
+		printMessage("<<", resp)
+	}
+}
+
+// substitutions — значения из флагов для полей, оставленных в фикстуре пустыми
+type substitutions map[int]string
+
+func loadFixture(path string, subst substitutions, macKey []byte) (*iso8583.Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	msg := iso8583.NewMessage(f.MTI)
+	for k, v := range f.Fields {
+		var n int
+		if _, err := fmt.Sscanf(k, "%d", &n); err != nil {
+			return nil, fmt.Errorf("invalid field number %q", k)
+		}
+		msg.Set(n, v)
+	}
+
+	for n, value := range subst {
+		if v, ok := msg.Get(n); ok && v == "" {
+			if value == "" {
+				delete(msg.Fields, n)
+			} else {
+				msg.Set(n, value)
+			}
+		}
+	}
+
+	// Служебные поля заполняются автоматически: дата/время передачи, STAN, локальное время
+	now := time.Now()
+	if _, ok := msg.Get(7); !ok {
+		msg.Set(7, now.UTC().Format("0102150405"))
+	}
+	if _, ok := msg.Get(11); !ok {
+		msg.Set(11, fmt.Sprintf("%06d", rand.Intn(1000000)))
+	}
+	if _, ok := msg.Get(12); !ok {
+		msg.Set(12, now.Format("150405"))
+	}
+	if _, ok := msg.Get(13); !ok {
+		msg.Set(13, now.Format("0102"))
+	}
+
+	if f.PIN != "" {
+		cardNumber, _ := msg.Get(2)
+		block, err := iso8583.EncodePINBlock(f.PIN, cardNumber)
+		if err != nil {
+			return nil, err
+		}
+		msg.Set(52, block)
+	}
+	if err := iso8583.SignMAC(msg, macKey); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func exchange(conn net.Conn, req *iso8583.Message) (*iso8583.Message, error) {
+	out, err := iso8583.Pack(req)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := iso8583.WriteFrame(conn, out); err != nil {
+		return nil, err
+	}
+	frame, err := iso8583.ReadFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	return iso8583.Unpack(frame)
+}
+
+func printMessage(prefix string, m *iso8583.Message) {
+	fmt.Printf("%s MTI %s\n", prefix, m.MTI)
+	for _, n := range m.FieldNumbers() {
+		v, _ := m.Get(n)
+		if n == 52 || n == 64 || n == 128 {
+			v = fmt.Sprintf("%X", v)
+		}
+		fmt.Printf("   %3d %-28s %s\n", n, iso8583.Spec[n].Name, v)
+	}
+}
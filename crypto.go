@@ -2,11 +2,14 @@ package main
 
 import (
 	"bytes"    
+    "errors"
+    "fmt"
     "io"
     "os"
     "strings"
 	
     "golang.org/x/crypto/openpgp"
+    "golang.org/x/crypto/openpgp/packet"
 )
 
 // LoadPublicKey загружает публичный PGP-ключ из файла
@@ -20,15 +23,44 @@ func LoadPublicKey(path string) (openpgp.EntityList, error) {
     return openpgp.ReadArmoredKeyRing(keyFile)
 }
 
-// LoadPrivateKey загружает приватный PGP-ключ из файла
-func LoadPrivateKey(path string) (openpgp.EntityList, error) {
+// LoadPrivateKey загружает приватный PGP-ключ из файла и, если ключ защищён, расшифровывает его парольной фразой
+func LoadPrivateKey(path string, passphrase []byte) (openpgp.EntityList, error) {
     keyFile, err := os.Open(path)
     if err != nil {
         return nil, err
     }
     defer keyFile.Close()
 
-    return openpgp.ReadArmoredKeyRing(keyFile)
+    entities, err := openpgp.ReadArmoredKeyRing(keyFile)
+    if err != nil {
+        return nil, err
+    }
+    for _, entity := range entities {
+        if err := decryptEntity(entity, passphrase); err != nil {
+            return nil, fmt.Errorf("key %s: %w", entity.PrimaryKey.KeyIdString(), err)
+        }
+    }
+    return entities, nil
+}
+
+// decryptEntity расшифровывает основной ключ и подключи сущности
+func decryptEntity(entity *openpgp.Entity, passphrase []byte) error {
+    keys := []*packet.PrivateKey{entity.PrivateKey}
+    for _, subkey := range entity.Subkeys {
+        keys = append(keys, subkey.PrivateKey)
+    }
+    for _, key := range keys {
+        if key == nil || !key.Encrypted {
+            continue
+        }
+        if len(passphrase) == 0 {
+            return errors.New("private key is encrypted but no passphrase is configured")
+        }
+        if err := key.Decrypt(passphrase); err != nil {
+            return fmt.Errorf("failed to decrypt private key: %w", err)
+        }
+    }
+    return nil
 }
 
 // EncryptWithPGP шифрует текст публичным ключом PGP
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Префикс шифртекста конвертного шифрования: env1:<ID мастер-ключа>:<обёрнутый ключ данных>:<шифртекст>
+const envelopePrefix = "env1:"
+
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// MasterKeyProvider оборачивает и разворачивает ключи данных мастер-ключом.
+// Мастер-ключ не покидает провайдера: так же устроены внешние KMS, которые можно подключить вместо локальных реализаций.
+type MasterKeyProvider interface {
+	ActiveKeyID() string
+	WrapKey(dataKey, aad []byte) (keyID string, wrapped []byte, err error)
+	UnwrapKey(keyID string, wrapped, aad []byte) ([]byte, error)
+}
+
+// masterKeyring — набор мастер-ключей AES-256 с одним активным; общая часть файлового провайдера и локального KMS
+type masterKeyring struct {
+	mu          sync.RWMutex
+	activeKeyID string
+	keys        map[string][]byte
+}
+
+// masterKeyID — идентификатор мастер-ключа, не раскрывающий сам ключ
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "mk-" + hex.EncodeToString(sum[:6])
+}
+
+func (k *masterKeyring) add(key []byte, active bool) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := masterKeyID(key)
+	k.keys[id] = key
+	if active {
+		k.activeKeyID = id
+	}
+	return id
+}
+
+func (k *masterKeyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeKeyID
+}
+
+func (k *masterKeyring) WrapKey(dataKey, aad []byte) (string, []byte, error) {
+	k.mu.RLock()
+	id, key := k.activeKeyID, k.keys[k.activeKeyID]
+	k.mu.RUnlock()
+
+	wrapped, err := sealAESGCM(key, dataKey, aad)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, wrapped, nil
+}
+
+func (k *masterKeyring) UnwrapKey(keyID string, wrapped, aad []byte) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[keyID]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownMasterKey, keyID)
+	}
+	return openAESGCM(key, wrapped, aad)
+}
+
+// FileMasterKeyProvider читает мастер-ключи из файлов. Первый файл — активный ключ,
+// остальные — выведенные ключи, нужные для разворачивания ключей данных старых записей.
+type FileMasterKeyProvider struct {
+	masterKeyring
+}
+
+func NewFileMasterKeyProvider(paths []string) (*FileMasterKeyProvider, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no master key files configured")
+	}
+	p := &FileMasterKeyProvider{masterKeyring{keys: make(map[string][]byte)}}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key %s: %w", path, err)
+		}
+		key, err := parseMasterKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", path, err)
+		}
+		p.add(key, i == 0)
+	}
+	return p, nil
+}
+
+// parseMasterKey принимает 32-байтовый ключ в hex или base64
+func parseMasterKey(s string) ([]byte, error) {
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("master key must be 32 bytes encoded as hex or base64")
+}
+
+// LocalKMS — заменитель внешнего KMS для разработки: мастер-ключи генерируются и живут только в памяти процесса,
+// поэтому данные, зашифрованные через него, не переживают перезапуск
+type LocalKMS struct {
+	masterKeyring
+}
+
+func NewLocalKMS() (*LocalKMS, error) {
+	kms := &LocalKMS{masterKeyring{keys: make(map[string][]byte)}}
+	if _, err := kms.Rotate(); err != nil {
+		return nil, err
+	}
+	return kms, nil
+}
+
+// Rotate создаёт новый активный мастер-ключ; прежние остаются доступны для разворачивания
+func (kms *LocalKMS) Rotate() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return kms.add(key, true), nil
+}
+
+func sealAESGCM(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func openAESGCM(key, sealed, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// EnvelopeCipher шифрует каждую запись собственным ключом данных AES-256-GCM,
+// обёрнутым мастер-ключом провайдера. Ассоциированные данные (ID карты) привязывают шифртекст к записи.
+type EnvelopeCipher struct {
+	provider MasterKeyProvider
+}
+
+func NewEnvelopeCipher(provider MasterKeyProvider) *EnvelopeCipher {
+	return &EnvelopeCipher{provider: provider}
+}
+
+func (c *EnvelopeCipher) ActiveKeyID() string {
+	return c.provider.ActiveKeyID()
+}
+
+// Encrypt возвращает шифртекст и ID мастер-ключа, которым обёрнут ключ данных
+func (c *EnvelopeCipher) Encrypt(plaintext, aad string) (string, string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	sealed, err := sealAESGCM(dataKey, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", "", err
+	}
+	keyID, wrapped, err := c.provider.WrapKey(dataKey, []byte(aad))
+	if err != nil {
+		return "", "", err
+	}
+	enc := base64.RawStdEncoding
+	return envelopePrefix + keyID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(sealed), keyID, nil
+}
+
+func (c *EnvelopeCipher) Decrypt(ciphertext, aad string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(ciphertext, envelopePrefix), ":")
+	if !strings.HasPrefix(ciphertext, envelopePrefix) || len(parts) != 3 {
+		return "", errors.New("malformed envelope ciphertext")
+	}
+	enc := base64.RawStdEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed wrapped data key: %w", err)
+	}
+	sealed, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed envelope ciphertext: %w", err)
+	}
+
+	dataKey, err := c.provider.UnwrapKey(parts[0], wrapped, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openAESGCM(dataKey, sealed, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func isEnvelopeCiphertext(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, envelopePrefix)
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"crypto/hmac"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -37,13 +37,7 @@ func respondError(w http.ResponseWriter, code int, message string) {
 func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req RegisterRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
-        return
-    }
-
-    if req.Username == "" || req.Email == "" || req.Password == "" {
-        respondError(w, http.StatusBadRequest, "Username, email, and password are required")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
@@ -53,11 +47,19 @@ func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // Телефон уже проверен при валидации; храним его в формате E.164 для поиска получателя перевода
+    phone, _ := NormalizePhone(req.Phone)
+
     user := User{
         ID:           GenerateID(),
         Username:     req.Username,
         Email:        req.Email,
+        Phone:        phone,
+        FirstName:    strings.TrimSpace(req.FirstName),
+        LastName:     strings.TrimSpace(req.LastName),
         PasswordHash: hashedPassword,
+        Role:         RoleCustomer,
+        Tier:         TierStandard,
         CreatedAt:    time.Now(),
     }
 
@@ -66,9 +68,17 @@ func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    verifyToken, err := GenerateActionToken(user.ID, TokenPurposeVerifyEmail, emailVerificationTTL)
+    if err != nil {
+        log.Printf("Failed to generate verification token for %s: %v", user.Email, err)
+    }
+
     go func() {
         subject := "Welcome to Simple Bank!"
         body := fmt.Sprintf("Hello %s,\n\nThank you for registering at Simple Bank.", user.Username)
+        if verifyToken != "" {
+            body += fmt.Sprintf("\n\nPlease confirm your email address: %s/verify-email?token=%s", appBaseURL(), verifyToken)
+        }
         err := SendEmailNotification(user.Email, subject, body)
         if err != nil {
             log.Printf("Failed to send registration email to %s: %v", user.Email, err)
@@ -80,11 +90,62 @@ func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
     respondJSON(w, http.StatusCreated, user)
 }
 
+// InitOperator создаёт учётную запись первого оператора из OPERATOR_USERNAME, OPERATOR_EMAIL и
+// OPERATOR_PASSWORD_HASH (bcrypt). Вызывается до запуска сервера, поэтому имя нельзя занять регистрацией.
+// Остальные операторы назначаются через PUT /api/ops/users/{userId}/role.
+func InitOperator() error {
+    username := strings.TrimSpace(os.Getenv("OPERATOR_USERNAME"))
+    if username == "" {
+        return nil
+    }
+    email := strings.TrimSpace(os.Getenv("OPERATOR_EMAIL"))
+    passwordHash := os.Getenv("OPERATOR_PASSWORD_HASH")
+    if email == "" || passwordHash == "" {
+        return fmt.Errorf("OPERATOR_EMAIL and OPERATOR_PASSWORD_HASH are required with OPERATOR_USERNAME")
+    }
+    if _, err := bcrypt.Cost([]byte(passwordHash)); err != nil {
+        return fmt.Errorf("OPERATOR_PASSWORD_HASH is not a bcrypt hash: %w", err)
+    }
+    return AddUser(User{
+        ID:            GenerateID(),
+        Username:      username,
+        Email:         email,
+        PasswordHash:  passwordHash,
+        EmailVerified: true,
+        Role:          RoleOperator,
+        Tier:          TierStandard,
+        CreatedAt:     time.Now(),
+    })
+}
+
+// SetUserRoleHandler назначает или снимает роль оператора (для операторов)
+func SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+    defer r.Body.Close()
+    var req SetUserRoleRequest
+    if !decodeAndValidate(w, r, &req) {
+        return
+    }
+
+    userID := mux.Vars(r)["userId"]
+    if operatorID, _ := r.Context().Value(userContextKey).(string); userID == operatorID && req.Role != RoleOperator {
+        respondError(w, http.StatusConflict, "Operators cannot revoke their own role")
+        return
+    }
+
+    user, err := SetUserRole(userID, req.Role)
+    if err != nil {
+        respondError(w, http.StatusNotFound, "User not found")
+        return
+    }
+    log.Printf("User %s role set to %s", user.ID, user.Role)
+    user.PasswordHash = ""
+    respondJSON(w, http.StatusOK, user)
+}
+
 func LoginUserHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req LoginRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
@@ -113,29 +174,179 @@ func LoginUserHandler(w http.ResponseWriter, r *http.Request) {
     })
 }
 
+const (
+    emailVerificationTTL = 48 * time.Hour
+    passwordResetTTL     = time.Hour
+)
+
+func appBaseURL() string {
+    if base := os.Getenv("APP_BASE_URL"); base != "" {
+        return strings.TrimRight(base, "/")
+    }
+    return "http://localhost:8080"
+}
+
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+    defer r.Body.Close()
+    var req ForgotPasswordRequest
+    if !decodeAndValidate(w, r, &req) {
+        return
+    }
+
+    // Ответ одинаков для известных и неизвестных адресов, чтобы не раскрывать зарегистрированные email
+    response := map[string]string{"message": "If the email is registered, a password reset link has been sent"}
+
+    user, ok := GetUserByEmail(req.Email)
+    if !ok {
+        respondJSON(w, http.StatusOK, response)
+        return
+    }
+
+    token, err := GenerateActionToken(user.ID, TokenPurposePasswordReset, passwordResetTTL)
+    if err != nil {
+        respondError(w, http.StatusInternalServerError, "Failed to generate reset token")
+        return
+    }
+
+    go func() {
+        subject := "Simple Bank password reset"
+        body := fmt.Sprintf("Hello %s,\n\nUse this token to reset your password within %v:\n%s\n\nIf you did not request a reset, ignore this email.",
+            user.Username, passwordResetTTL, token)
+        if err := SendEmailNotification(user.Email, subject, body); err != nil {
+            log.Printf("Failed to send password reset email to %s: %v", user.Email, err)
+        }
+    }()
+
+    log.Printf("Password reset requested for user %s", user.ID)
+    respondJSON(w, http.StatusOK, response)
+}
+
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+    defer r.Body.Close()
+    var req ResetPasswordRequest
+    if !decodeAndValidate(w, r, &req) {
+        return
+    }
+
+    claims, err := ParseActionToken(req.Token, TokenPurposePasswordReset)
+    if err != nil {
+        respondError(w, http.StatusBadRequest, "Invalid or expired token")
+        return
+    }
+
+    // Токены, выданные до последней смены пароля, недействительны
+    user, ok := GetUser(claims.UserID)
+    if !ok || (!user.PasswordChangedAt.IsZero() && claims.IssuedAt <= user.PasswordChangedAt.Unix()) {
+        respondError(w, http.StatusBadRequest, "Invalid or expired token")
+        return
+    }
+
+    hashedPassword, err := HashPassword(req.NewPassword)
+    if err != nil {
+        respondError(w, http.StatusInternalServerError, "Failed to hash password")
+        return
+    }
+
+    if !ConsumeActionToken(claims.ID, time.Unix(claims.ExpiresAt, 0)) {
+        respondError(w, http.StatusBadRequest, "Token has already been used")
+        return
+    }
+
+    if err := UpdateUserPassword(claims.UserID, hashedPassword); err != nil {
+        respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to reset password: %v", err))
+        return
+    }
+
+    log.Printf("Password reset for user %s", claims.UserID)
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+    token := r.URL.Query().Get("token")
+    if token == "" {
+        respondError(w, http.StatusBadRequest, "Token is required")
+        return
+    }
+
+    claims, err := ParseActionToken(token, TokenPurposeVerifyEmail)
+    if err != nil {
+        respondError(w, http.StatusBadRequest, "Invalid or expired token")
+        return
+    }
+
+    if !ConsumeActionToken(claims.ID, time.Unix(claims.ExpiresAt, 0)) {
+        respondError(w, http.StatusBadRequest, "Token has already been used")
+        return
+    }
+
+    if err := MarkEmailVerified(claims.UserID); err != nil {
+        respondError(w, http.StatusBadRequest, "Invalid or expired token")
+        return
+    }
+
+    log.Printf("Email verified for user %s", claims.UserID)
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Email verified"})
+}
+
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+    userID, ok := r.Context().Value(userContextKey).(string)
+    if !ok {
+        respondError(w, http.StatusUnauthorized, "User not found in context")
+        return
+    }
+
+    user, ok := GetUser(userID)
+    if !ok {
+        respondError(w, http.StatusNotFound, "User not found")
+        return
+    }
+    if user.EmailVerified {
+        respondError(w, http.StatusConflict, "Email already verified")
+        return
+    }
+
+    token, err := GenerateActionToken(user.ID, TokenPurposeVerifyEmail, emailVerificationTTL)
+    if err != nil {
+        respondError(w, http.StatusInternalServerError, "Failed to generate verification token")
+        return
+    }
+
+    go func() {
+        subject := "Confirm your Simple Bank email"
+        body := fmt.Sprintf("Hello %s,\n\nPlease confirm your email address: %s/verify-email?token=%s", user.Username, appBaseURL(), token)
+        if err := SendEmailNotification(user.Email, subject, body); err != nil {
+            log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+        }
+    }()
+
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
 func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req CreateAccountRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
-    userID, ok := r.Context().Value("user").(string) 
+    userID, ok := r.Context().Value(userContextKey).(string)
     if !ok {
         respondError(w, http.StatusUnauthorized, "User  not found in context")
         return
     }
 
     account := Account{
-        ID:        GenerateID(),
-        UserID:    userID, 
-        Number:    GenerateAccountNumber(),
-        Balance:   decimal.Zero,
-        CreatedAt: time.Now(),
+        ID:               GenerateID(),
+        UserID:           userID, 
+        Number:           GenerateAccountNumber(BalanceAccountPersonal),
+        Balance:          decimal.Zero,
+        AvailableBalance: decimal.Zero,
+        Status:           AccountStatusActive,
+        CreatedAt:        time.Now(),
     }
 
-    if err := AddAccount(account); err != nil {
+    account, err := AddAccount(account)
+    if err != nil {
         respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create account: %v", err))
         return
     }
@@ -145,7 +356,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetUserAccountsHandler(w http.ResponseWriter, r *http.Request) {
-    userID, ok := r.Context().Value("user").(string) 
+    userID, ok := r.Context().Value(userContextKey).(string)
     if !ok {
         respondError(w, http.StatusUnauthorized, "User  not found in context")
         return
@@ -159,8 +370,7 @@ func GetUserAccountsHandler(w http.ResponseWriter, r *http.Request) {
 func GenerateCardHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req GenerateCardRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
@@ -170,43 +380,60 @@ func GenerateCardHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    account, ok := GetAccount(req.AccountID)
-    if !ok || account.UserID != userID {
-        respondError(w, http.StatusBadRequest, "Account not found or access denied")
+    account, err := AuthorizeAccount(req.AccountID, userID, AccountPermissionFull, decimal.Zero)
+    if err != nil {
+        respondAccountAccessError(w, err, "Account not found or access denied")
+        return
+    }
+    if account.Status == AccountStatusClosed {
+        respondError(w, http.StatusConflict, "Account is closed")
         return
     }
 
-    month, year := GenerateExpiryDate()
-    cvv := GenerateCVV()
-
-    card := Card{
-        ID:          GenerateID(),
-        AccountID:   req.AccountID,
-        ExpiryMonth: month,
-        ExpiryYear:  year,
-        CreatedAt:   time.Now(),
+    if req.MerchantID != "" {
+        if _, ok := GetMerchant(req.MerchantID); !ok {
+            respondError(w, http.StatusBadRequest, "Merchant not found")
+            return
+        }
     }
 
-    // Загрузка публичного ключа PGP 
-    pubKeyPath := os.Getenv("PGP_PUBLIC_KEY_PATH")
-    if pubKeyPath == "" {
-        respondError(w, http.StatusInternalServerError, "PGP_PUBLIC_KEY_PATH not set")
-        return
+    cardType := req.Type
+    if cardType == "" {
+        cardType = CardTypePhysical
     }
-    pubKey, err := LoadPublicKey(pubKeyPath)
-    if err != nil {
-        respondError(w, http.StatusInternalServerError, "Failed to load PGP public key")
-        return
+
+    // Виртуальные и одноразовые карты выпускаются с коротким сроком действия (по умолчанию 1 месяц)
+    month, year := GenerateExpiryDate()
+    if cardType != CardTypePhysical {
+        expiryMonths := req.ExpiryMonths
+        if expiryMonths == 0 {
+            expiryMonths = 1
+        }
+        month, year = GenerateExpiryDateIn(expiryMonths)
     }
+    cvv := GenerateCVV()
 
-    encryptedNumber, err := EncryptWithPGP(GenerateValidCardNumber(), pubKey)
-    if err != nil {
+    card := Card{
+        ID:               GenerateID(),
+        AccountID:        req.AccountID,
+        ExpiryMonth:      month,
+        ExpiryYear:       year,
+        CreatedAt:        time.Now(),
+        Controls:         DefaultCardControls(),
+        Type:             cardType,
+        Status:           CardStatusActive,
+        LockedMerchantID: req.MerchantID,
+    }
+
+    pan := GenerateValidCardNumber()
+    card.PANFingerprint = computePANFingerprint(pan)
+
+    // Номер шифруется активной версией PGP-ключа из менеджера ключей
+    if err := encryptCardNumber(&card, pan); err != nil {
         respondError(w, http.StatusInternalServerError, "Error encrypting card number")
         return
     }
 
-    card.Number = encryptedNumber
-
     // Хешируем CVV через bcrypt
     cvvHash, err := bcrypt.GenerateFromPassword([]byte(cvv), bcrypt.DefaultCost)
     if err != nil {
@@ -224,25 +451,20 @@ func GenerateCardHandler(w http.ResponseWriter, r *http.Request) {
     
     respondJSON(w, http.StatusCreated, map[string]interface{}{
         "card_id":     card.ID,
-        "card_number": DecryptPGPForResponse(card.Number), // функция ниже
+        "card_number": DecryptPGPForResponse(card), // функция ниже
         "expiry_month": card.ExpiryMonth,
         "expiry_year":  card.ExpiryYear,
         "cvv":         cvv,
+        "type":        card.Type,
+        "status":      card.Status,
+        "locked_merchant_id": card.LockedMerchantID,
         "created_at":  card.CreatedAt,
     })
 }
 
 // Вспомогательная функция для дешифровки номера карты в ответе 
-func DecryptPGPForResponse(encrypted string) string {
-    privKeyPath := os.Getenv("PGP_PRIVATE_KEY_PATH")
-    if privKeyPath == "" {
-        return "**** **** **** ****"
-    }
-    privKey, err := LoadPrivateKey(privKeyPath)
-    if err != nil {
-        return "**** **** **** ****"
-    }
-    decrypted, err := DecryptWithPGP(encrypted, privKey)
+func DecryptPGPForResponse(card Card) string {
+    decrypted, err := decryptCardNumber(card)
     if err != nil {
         return "**** **** **** ****"
     }
@@ -259,36 +481,26 @@ func GetAccountCardsHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    account, ok := GetAccount(accountID)
-    if !ok || account.UserID != userID {
-        respondError(w, http.StatusNotFound, "Account not found or access denied")
+    if _, err := AuthorizeAccount(accountID, userID, AccountPermissionView, decimal.Zero); err != nil {
+        respondAccountAccessError(w, err, "Account not found or access denied")
         return
     }
 
     cards := GetAccountCards(accountID)
 
-    privKeyPath := os.Getenv("PGP_PRIVATE_KEY_PATH")
-    if privKeyPath == "" {
-        respondError(w, http.StatusInternalServerError, "PGP_PRIVATE_KEY_PATH not set")
-        return
-    }
-    privKey, err := LoadPrivateKey(privKeyPath)
-    if err != nil {
-        respondError(w, http.StatusInternalServerError, "Failed to load PGP private key")
-        return
-    }
-
     type cardResponse struct {
         ID          string `json:"id"`
         NumberMasked string `json:"number_masked"`
         ExpiryMonth int    `json:"expiry_month"`
         ExpiryYear  int    `json:"expiry_year"`
+        Type        string `json:"type"`
+        Status      string `json:"status"`
         CreatedAt   time.Time `json:"created_at"`
     }
 
     respCards := make([]cardResponse, 0, len(cards))
     for _, c := range cards {
-        decryptedNumber, err := DecryptWithPGP(c.Number, privKey)
+        decryptedNumber, err := decryptCardNumber(c)
         if err != nil {
             respondError(w, http.StatusInternalServerError, "Error decrypting card number")
             return
@@ -299,6 +511,8 @@ func GetAccountCardsHandler(w http.ResponseWriter, r *http.Request) {
             NumberMasked: masked,
             ExpiryMonth:  c.ExpiryMonth,
             ExpiryYear:   c.ExpiryYear,
+            Type:         c.Type,
+            Status:       c.Status,
             CreatedAt:    c.CreatedAt,
         })
     }
@@ -306,6 +520,31 @@ func GetAccountCardsHandler(w http.ResponseWriter, r *http.Request) {
     respondJSON(w, http.StatusOK, respCards)
 }
 
+func CloseCardHandler(w http.ResponseWriter, r *http.Request) {
+    userID, ok := r.Context().Value(userContextKey).(string)
+    if !ok {
+        respondError(w, http.StatusUnauthorized, "User not found in context")
+        return
+    }
+
+    card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+    if !ok {
+        respondError(w, http.StatusNotFound, "Card not found or access denied")
+        return
+    }
+    if err := CloseCard(card.ID); err != nil {
+        if errors.Is(err, ErrCardAlreadyClosed) {
+            respondError(w, http.StatusConflict, "Card is already closed")
+            return
+        }
+        respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to close card: %v", err))
+        return
+    }
+
+    log.Printf("Card %s closed by user %s", card.ID, userID)
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Card closed"})
+}
+
 func maskCardNumber(number string) string {
     if len(number) < 4 {
         return "****"
@@ -317,65 +556,57 @@ func maskCardNumber(number string) string {
 func PayWithCardHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req PaymentRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
-    if req.Amount.LessThanOrEqual(decimal.Zero) {
-        respondError(w, http.StatusBadRequest, "Payment amount must be positive")
-        return
+    var tx Transaction
+    var err error
+    if req.Token != "" {
+        tx, err = payWithCardToken(r, req)
+    } else {
+        var card Card
+        card, err = verifyCardForPayment(req.CardNumber, req.CVV)
+        if err == nil {
+            use := newCardUse(req.Amount, req.MCC, req.Channel, req.Country, "")
+            tx, err = chargeCard(card, use, fmt.Sprintf("Payment to %s", req.Merchant), nil)
+        }
     }
-    
-    card, ok := GetCardByID(req.CardNumber) 
-    if !ok {
-        respondError(w, http.StatusNotFound, "Card not found")
+    if err != nil {
+        respondPaymentError(w, err)
         return
     }
 
-    // Проверяем CVV
-    if err := bcrypt.CompareHashAndPassword([]byte(card.CVV), []byte(req.CVV)); err != nil {
-    respondError(w, http.StatusUnauthorized, "Invalid CVV")
-    return
-    }
-
-    now := time.Now()
-    expiry := time.Date(card.ExpiryYear, time.Month(card.ExpiryMonth), 1, 23, 59, 59, 0, time.UTC).AddDate(0, 1, -1)
-    if now.After(expiry) {
-        respondError(w, http.StatusBadRequest, "Card expired")
-        return
-    }
+    log.Printf("Payment of %s processed from account %s", req.Amount.String(), tx.FromAccountID)
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Payment successful"})
+}
 
-    account, ok := GetAccount(card.AccountID)
+// payWithCardToken оплачивает мерчанту сохранённой у него картой клиента: токен должен быть
+// выпущен этому мерчанту, а карта — принадлежать текущему пользователю
+func payWithCardToken(r *http.Request, req PaymentRequest) (Transaction, error) {
+    userID, ok := r.Context().Value(userContextKey).(string)
     if !ok {
-        respondError(w, http.StatusInternalServerError, "Associated account not found")
-        return
+        return Transaction{}, declinePayment(http.StatusUnauthorized, DeclineTokenNotFound, "User not found in context")
     }
-
-    if account.Balance.LessThan(req.Amount) {
-        respondError(w, http.StatusPaymentRequired, "Insufficient funds")
-        return
+    merchant, ok := GetMerchant(req.MerchantID)
+    if !ok {
+        return Transaction{}, declinePayment(http.StatusNotFound, DeclineTokenNotFound, "Merchant not found")
     }
 
-    err := UpdateAccountBalance(account.ID, req.Amount.Neg())
+    card, token, err := resolveCardToken(req.Token, merchant.ID)
     if err != nil {
-        respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process payment: %v", err))
-        return
+        return Transaction{}, err
     }
-
-    tx := Transaction{
-        ID:              GenerateID(),
-        FromAccountID:   account.ID,
-        ToAccountID:     "",
-        Amount:          req.Amount,
-        Timestamp:       time.Now(),
-        TransactionType: "payment",
-        Description:     fmt.Sprintf("Payment to %s", req.Merchant),
+    if _, err := AuthorizeAccount(card.AccountID, userID, AccountPermissionPay, req.Amount); err != nil {
+        if errors.Is(err, ErrAccountNotFound) {
+            return Transaction{}, declinePayment(http.StatusNotFound, DeclineTokenNotFound, "Card token not found")
+        }
+        return Transaction{}, declinePayment(http.StatusForbidden, DeclineAccountBlocked, err.Error())
     }
-    AddTransaction(tx)
 
-    log.Printf("Payment of %s processed from account %s", req.Amount.String(), account.ID)
-    respondJSON(w, http.StatusOK, map[string]string{"message": "Payment successful"})
+    use := newCardUse(req.Amount, merchant.MCC, req.Channel, req.Country, merchant.ID)
+    use.TokenID = token.ID
+    return chargeCard(card, use, fmt.Sprintf("Payment to %s", merchant.Name), &merchant)
 }
 
 func GetCardByID(cardID string) (Card, bool) {
@@ -386,8 +617,7 @@ func GetCardByID(cardID string) (Card, bool) {
         return Card{}, false
     }
     // Проверяем HMAC
-    expectedHMAC := computeCardHMAC(card)
-    if !hmac.Equal([]byte(expectedHMAC), []byte(card.HMAC)) {
+    if verifyCardHMAC(card) != nil {
         return Card{}, false 
     }
     return card, true
@@ -396,71 +626,65 @@ func GetCardByID(cardID string) (Card, bool) {
 func TransferHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req TransferRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
-    if req.FromAccountID == req.ToAccountID {
-        respondError(w, http.StatusBadRequest, "Cannot transfer to the same account")
-        return
-    }
-    if req.Amount.LessThanOrEqual(decimal.Zero) {
-        respondError(w, http.StatusBadRequest, "Transfer amount must be positive")
+    userID, ok := r.Context().Value(userContextKey).(string)
+    if !ok {
+        respondError(w, http.StatusUnauthorized, "User not found in context")
         return
     }
 
-    storage.mu.Lock()
-    defer storage.mu.Unlock()
-
-    fromAccount, okFrom := storage.accounts[req.FromAccountID]
-    toAccount, okTo := storage.accounts[req.ToAccountID]
-
-    if !okFrom {
-        respondError(w, http.StatusNotFound, fmt.Sprintf("Source account %s not found", req.FromAccountID))
-        return
-    }
-    if !okTo {
-        respondError(w, http.StatusNotFound, fmt.Sprintf("Destination account %s not found", req.ToAccountID))
+    fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, req.Amount)
+    if err != nil {
+        respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
         return
     }
 
-    if fromAccount.Balance.LessThan(req.Amount) {
-        respondError(w, http.StatusPaymentRequired, "Insufficient funds in source account")
+    // Получатель задаётся внутренним ID счёта либо номером счёта, карты или телефоном
+    toAccountID, err := transferDestination(req.ToAccountID, req.To, req.ToType)
+    if err != nil {
+        respondTransferError(w, err)
+        return
+    }
+
+    // Платёж организации выше порога ждёт второй подписи
+    if RequiresDualApproval(fromAccount, req.Amount) {
+        now := time.Now()
+        approval, err := CreatePaymentApproval(PaymentApproval{
+            ID:             GenerateID(),
+            OrganizationID: fromAccount.OrganizationID,
+            FromAccountID:  fromAccount.ID,
+            ToAccountID:    toAccountID,
+            Amount:         req.Amount,
+            InitiatedBy:    userID,
+            CreatedAt:      now,
+            UpdatedAt:      now,
+        })
+        if err != nil {
+            respondOrganizationError(w, err)
+            return
+        }
+        log.Printf("Transfer of %s from %s awaits approval %s", req.Amount.String(), fromAccount.ID, approval.ID)
+        respondJSON(w, http.StatusAccepted, approval)
         return
     }
 
-    fromAccount.Balance = fromAccount.Balance.Sub(req.Amount)
-    toAccount.Balance = toAccount.Balance.Add(req.Amount)
-
-    storage.accounts[req.FromAccountID] = fromAccount
-    storage.accounts[req.ToAccountID] = toAccount
-
-    tx := Transaction{
-        ID:              GenerateID(),
-        FromAccountID:   req.FromAccountID,
-        ToAccountID:     req.ToAccountID,
-        Amount:          req.Amount,
-        Timestamp:       time.Now(),
-        TransactionType: "transfer",
-        Description:     fmt.Sprintf("Transfer from %s to %s", fromAccount.Number, toAccount.Number),
+    tx, err := ExecuteTransfer(fromAccount.ID, toAccountID, req.Amount, "")
+    if err != nil {
+        respondTransferError(w, err)
+        return
     }
-    storage.transactions = append(storage.transactions, tx)
 
-    log.Printf("Transfer of %s from %s to %s successful", req.Amount.String(), req.FromAccountID, req.ToAccountID)
-    respondJSON(w, http.StatusOK, map[string]string{"message": "Transfer successful"})
+    log.Printf("Transfer of %s from %s to %s successful", req.Amount.String(), tx.FromAccountID, tx.ToAccountID)
+    respondJSON(w, http.StatusOK, map[string]string{"message": "Transfer successful", "transaction_id": tx.ID})
 }
 
 func DepositHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req DepositRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
-        return
-    }
-
-    if req.Amount.LessThanOrEqual(decimal.Zero) {
-        respondError(w, http.StatusBadRequest, "Deposit amount must be positive")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
@@ -468,6 +692,8 @@ func DepositHandler(w http.ResponseWriter, r *http.Request) {
     if err != nil {
         if strings.Contains(err.Error(), "not found") {
             respondError(w, http.StatusNotFound, err.Error())
+        } else if err == ErrAccountClosed {
+            respondError(w, http.StatusConflict, "Account is closed")
         } else {
             respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process deposit: %v", err))
         }
@@ -493,13 +719,7 @@ func DepositHandler(w http.ResponseWriter, r *http.Request) {
 func ApplyLoanHandler(w http.ResponseWriter, r *http.Request) {
     defer r.Body.Close()
     var req ApplyLoanRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        respondError(w, http.StatusBadRequest, "Invalid request payload")
-        return
-    }
-
-    if req.Amount.LessThanOrEqual(decimal.Zero) || req.TermMonths <= 0 {
-        respondError(w, http.StatusBadRequest, "Loan amount and term must be positive")
+    if !decodeAndValidate(w, r, &req) {
         return
     }
 
@@ -511,7 +731,7 @@ func ApplyLoanHandler(w http.ResponseWriter, r *http.Request) {
 
     storage.mu.RLock()
     _, userExists := storage.users[userID] // Измените здесь на userID
-    _, accountExists := storage.accounts[req.AccountID]
+    account, accountExists := storage.accounts[req.AccountID]
     storage.mu.RUnlock()
 
     if !userExists {
@@ -522,6 +742,10 @@ func ApplyLoanHandler(w http.ResponseWriter, r *http.Request) {
         respondError(w, http.StatusNotFound, fmt.Sprintf("Account %s not found", req.AccountID))
         return
     }
+    if account.Status == AccountStatusClosed {
+        respondError(w, http.StatusConflict, "Account is closed")
+        return
+    }
 
     currentDate := time.Now().Format("2006-01-02")
 
@@ -595,8 +819,13 @@ func GetTransactionsHandler(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     accountID := vars["accountId"]
 
-    if _, ok := GetAccount(accountID); !ok {
-        respondError(w, http.StatusNotFound, fmt.Sprintf("Account %s not found", accountID))
+    userID, ok := r.Context().Value(userContextKey).(string)
+    if !ok {
+        respondError(w, http.StatusUnauthorized, "User not found in context")
+        return
+    }
+    if _, err := AuthorizeAccount(accountID, userID, AccountPermissionView, decimal.Zero); err != nil {
+        respondAccountAccessError(w, err, fmt.Sprintf("Account %s not found", accountID))
         return
     }
 
@@ -611,8 +840,16 @@ func GetTransactionsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetFinancialSummaryHandler(w http.ResponseWriter, r *http.Request) {
-    vars := mux.Vars(r)
-    userID := vars["userId"]
+    userID, ok := r.Context().Value(userContextKey).(string)
+    if !ok {
+        respondError(w, http.StatusUnauthorized, "User  not found in context")
+        return
+    }
+    // Сводка доступна только самому клиенту; чужой ID не выдаёт существование пользователя
+    if mux.Vars(r)["userId"] != userID {
+        respondError(w, http.StatusNotFound, "User not found")
+        return
+    }
 
     accounts := GetUserAccounts(userID)
     loans := GetUserLoans(userID)
@@ -651,12 +888,14 @@ func GetFinancialForecastHandler(w http.ResponseWriter, r *http.Request) {
     }
 
     accounts := GetUserAccounts(userID)
+    accessible := make(map[string]bool, len(accounts))
+    for _, acc := range accounts {
+        accessible[acc.ID] = true
+    }
     transactions := []Transaction{}
     storage.mu.RLock()
     for _, tx := range storage.transactions {
-        if tx.FromAccountID != "" && storage.accounts[tx.FromAccountID].UserID == userID {
-            transactions = append(transactions, tx)
-        } else if tx.ToAccountID != "" && storage.accounts[tx.ToAccountID].UserID == userID {
+        if accessible[tx.FromAccountID] || accessible[tx.ToAccountID] {
             transactions = append(transactions, tx)
         }
     }
@@ -676,10 +915,10 @@ func GetFinancialForecastHandler(w http.ResponseWriter, r *http.Request) {
         if tx.Timestamp.Before(oneMonthAgo) {
             continue
         }
-        if tx.ToAccountID != "" && storage.accounts[tx.ToAccountID].UserID == userID {
+        if accessible[tx.ToAccountID] {
             totalIncome = totalIncome.Add(tx.Amount)
         }
-        if tx.FromAccountID != "" && storage.accounts[tx.FromAccountID].UserID == userID {
+        if accessible[tx.FromAccountID] {
             totalExpenses = totalExpenses.Add(tx.Amount)
         }
     }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func respondClearingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrExternalPaymentNotFound):
+		respondError(w, http.StatusNotFound, "External payment not found")
+	case errors.Is(err, ErrClearingBatchNotFound):
+		respondError(w, http.StatusNotFound, "Clearing batch not found")
+	case errors.Is(err, ErrInvalidExternalPaymentState), errors.Is(err, ErrNothingToClear), errors.Is(err, ErrBatchAlreadyProcessed):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondTransferError(w, err)
+	}
+}
+
+// CreateExternalPaymentHandler принимает платёжное поручение на счёт в другом банке.
+// Средства списываются сразу, поручение уходит в ближайшем клиринговом пакете.
+func CreateExternalPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateExternalPaymentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, req.Amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return
+	}
+	if req.BIK == bankRequisites.BIK {
+		respondError(w, http.StatusUnprocessableEntity, "Payee account is in this bank; use an internal transfer")
+		return
+	}
+
+	now := time.Now()
+	payment := ExternalPayment{
+		ID:            GenerateID(),
+		FromAccountID: fromAccount.ID,
+		Amount:        req.Amount,
+		PayeeBIK:      req.BIK,
+		PayeeAccount:  req.Account,
+		PayeeName:     strings.TrimSpace(req.Name),
+		PayeeINN:      req.INN,
+		PayeeKPP:      req.KPP,
+		Purpose:       strings.TrimSpace(req.Purpose),
+		CreatedBy:     userID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// Платёж организации выше порога ждёт второй подписи, средства до неё не списываются
+	if RequiresDualApproval(fromAccount, req.Amount) {
+		payment.Status = ExternalPaymentAwaitingApproval
+		if payment, err = AddExternalPayment(payment, now); err != nil {
+			respondTransferError(w, err)
+			return
+		}
+		approval, err := CreatePaymentApproval(PaymentApproval{
+			ID:                GenerateID(),
+			OrganizationID:    fromAccount.OrganizationID,
+			FromAccountID:     fromAccount.ID,
+			ExternalPaymentID: payment.ID,
+			Amount:            req.Amount,
+			Description:       payment.Purpose,
+			InitiatedBy:       userID,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		})
+		if err != nil {
+			respondOrganizationError(w, err)
+			return
+		}
+		log.Printf("External payment %s from %s awaits approval %s", payment.ID, fromAccount.ID, approval.ID)
+		respondJSON(w, http.StatusAccepted, approval)
+		return
+	}
+
+	payment, err = AddExternalPayment(payment, now)
+	if err != nil {
+		respondTransferError(w, err)
+		return
+	}
+
+	log.Printf("External payment %s of %s from %s to %s/%s queued for clearing",
+		payment.ID, payment.Amount.String(), fromAccount.ID, payment.PayeeBIK, payment.PayeeAccount)
+	respondJSON(w, http.StatusCreated, payment)
+}
+
+func GetAccountExternalPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	accountID := mux.Vars(r)["accountId"]
+	if _, err := AuthorizeAccount(accountID, userID, AccountPermissionView, decimal.Zero); err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Account %s not found", accountID))
+		return
+	}
+	respondJSON(w, http.StatusOK, GetAccountExternalPayments(accountID))
+}
+
+// accessibleExternalPayment возвращает поручение из пути запроса, если у пользователя есть право permission на счёт плательщика
+func accessibleExternalPayment(w http.ResponseWriter, r *http.Request, permission string) (ExternalPayment, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return ExternalPayment{}, false
+	}
+	payment, ok := GetExternalPayment(mux.Vars(r)["paymentId"])
+	if !ok {
+		respondError(w, http.StatusNotFound, "External payment not found")
+		return ExternalPayment{}, false
+	}
+	if _, err := AuthorizeAccount(payment.FromAccountID, userID, permission, decimal.Zero); err != nil {
+		respondAccountAccessError(w, err, "External payment not found")
+		return ExternalPayment{}, false
+	}
+	return payment, true
+}
+
+func GetExternalPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	if payment, ok := accessibleExternalPayment(w, r, AccountPermissionView); ok {
+		respondJSON(w, http.StatusOK, payment)
+	}
+}
+
+// CancelExternalPaymentHandler отменяет поручение, пока оно не включено в клиринговый пакет
+func CancelExternalPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	payment, ok := accessibleExternalPayment(w, r, AccountPermissionPay)
+	if !ok {
+		return
+	}
+
+	payment, err := CancelExternalPayment(payment.ID, time.Now())
+	if err != nil {
+		respondClearingError(w, err)
+		return
+	}
+
+	log.Printf("External payment %s cancelled, %s returned to account %s", payment.ID, payment.Amount.String(), payment.FromAccountID)
+	respondJSON(w, http.StatusOK, payment)
+}
+
+// FormClearingBatchHandler собирает все поручения из очереди в клиринговый пакет
+func FormClearingBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batch, err := FormClearingBatch(time.Now())
+	if err != nil {
+		respondClearingError(w, err)
+		return
+	}
+
+	log.Printf("Clearing batch %s formed: %d payments, %s", batch.ID, batch.Count, batch.TotalAmount.String())
+	respondJSON(w, http.StatusCreated, batch)
+}
+
+func GetClearingBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, GetClearingBatches())
+}
+
+// GetClearingBatchFileHandler выгружает пакет в формате PacketEPD/ED101
+func GetClearingBatchFileHandler(w http.ResponseWriter, r *http.Request) {
+	batch, ok := GetClearingBatch(mux.Vars(r)["batchId"])
+	if !ok {
+		respondError(w, http.StatusNotFound, "Clearing batch not found")
+		return
+	}
+	packet, err := BuildClearingPacket(batch, GetBatchPayments(batch))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to build clearing file: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ED101_%d.xml"`, batch.EDNo))
+	w.WriteHeader(http.StatusOK)
+	w.Write(packet)
+}
+
+func SubmitClearingBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batch, err := SubmitClearingBatch(mux.Vars(r)["batchId"], time.Now())
+	if err != nil {
+		if errors.Is(err, ErrClearingBatchNotFound) || errors.Is(err, ErrBatchAlreadyProcessed) {
+			respondClearingError(w, err)
+			return
+		}
+		respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	log.Printf("Clearing batch %s submitted: %d executed, %d rejected", batch.ID, batch.Executed, batch.Rejected)
+	respondJSON(w, http.StatusOK, batch)
+}
+
+// FetchIncomingPaymentsHandler принимает поступления из клиринга и возвращает обработанные
+func FetchIncomingPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	incoming, err := FetchIncomingPayments(time.Now())
+	if err != nil {
+		respondError(w, http.StatusBadGateway, fmt.Sprintf("Failed to fetch incoming payments: %v", err))
+		return
+	}
+	respondJSON(w, http.StatusOK, incoming)
+}
+
+func GetIncomingPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, GetIncomingPayments())
+}
+
+// SimulateIncomingPaymentHandler ставит в симулятор клиринга поступление из другого банка
+func SimulateIncomingPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SimulateIncomingPaymentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	simulator, ok := clearingGateway.(*ClearingSimulator)
+	if !ok {
+		respondError(w, http.StatusConflict, "Clearing simulator is not in use")
+		return
+	}
+	in := simulator.EnqueueIncoming(IncomingPayment{
+		PayerBIK:     req.PayerBIK,
+		PayerAccount: req.PayerAccount,
+		PayerName:    strings.TrimSpace(req.PayerName),
+		PayeeAccount: req.PayeeAccount,
+		Amount:       req.Amount,
+		Purpose:      strings.TrimSpace(req.Purpose),
+	})
+	respondJSON(w, http.StatusAccepted, in)
+}
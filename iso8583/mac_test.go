@@ -0,0 +1,63 @@
+package iso8583
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignVerifyMAC(t *testing.T) {
+	key := []byte("terminal-key")
+	tests := []struct {
+		name     string
+		fields   map[int]string
+		macField int
+	}{
+		{"primary bitmap", map[int]string{2: "4000001234567899", 4: "000000049990", 42: "MRC123456789012"}, 64},
+		{"secondary bitmap", map[int]string{11: "000042", 42: "MRC123456789012", 90: "020000004212345678900000000000000000000000"}, 128},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage(MTIFinancialRequest)
+			for n, v := range tt.fields {
+				m.Set(n, v)
+			}
+			if err := SignMAC(m, key); err != nil {
+				t.Fatalf("SignMAC: %v", err)
+			}
+			if _, ok := m.Get(tt.macField); !ok {
+				t.Fatalf("MAC not set in field %d", tt.macField)
+			}
+
+			// MAC переживает упаковку и разбор
+			packed, err := Pack(m)
+			if err != nil {
+				t.Fatalf("Pack: %v", err)
+			}
+			got, err := Unpack(packed)
+			if err != nil {
+				t.Fatalf("Unpack: %v", err)
+			}
+			if err := VerifyMAC(got, key); err != nil {
+				t.Errorf("VerifyMAC: %v", err)
+			}
+
+			if err := VerifyMAC(got, []byte("other-key")); !errors.Is(err, ErrMACMismatch) {
+				t.Errorf("VerifyMAC with wrong key = %v, want ErrMACMismatch", err)
+			}
+
+			got.Set(4, "000000000001")
+			if err := VerifyMAC(got, key); !errors.Is(err, ErrMACMismatch) {
+				t.Errorf("VerifyMAC of tampered message = %v, want ErrMACMismatch", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMACMissing(t *testing.T) {
+	m := NewMessage(MTIFinancialRequest)
+	m.Set(4, "000000049990")
+	if err := VerifyMAC(m, []byte("terminal-key")); !errors.Is(err, ErrMACMismatch) {
+		t.Errorf("VerifyMAC without MAC = %v, want ErrMACMismatch", err)
+	}
+}
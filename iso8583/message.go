@@ -0,0 +1,199 @@
+package iso8583
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message — сообщение ISO 8583: тип и значения полей (ключ — номер поля)
+type Message struct {
+	MTI    string
+	Fields map[int]string
+}
+
+func NewMessage(mti string) *Message {
+	return &Message{MTI: mti, Fields: make(map[int]string)}
+}
+
+func (m *Message) Set(field int, value string) {
+	m.Fields[field] = value
+}
+
+func (m *Message) Get(field int) (string, bool) {
+	v, ok := m.Fields[field]
+	return v, ok
+}
+
+// FieldNumbers возвращает номера присутствующих полей по возрастанию
+func (m *Message) FieldNumbers() []int {
+	numbers := make([]int, 0, len(m.Fields))
+	for n := range m.Fields {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// encodeField приводит значение к формату поля: дополняет фиксированные поля и добавляет префикс длины
+func encodeField(n int, value string) ([]byte, error) {
+	spec, ok := Spec[n]
+	if !ok {
+		return nil, fmt.Errorf("field %d is not supported", n)
+	}
+	if spec.Encoding == Numeric && strings.Trim(value, "0123456789") != "" {
+		return nil, fmt.Errorf("field %d (%s) must be numeric", n, spec.Name)
+	}
+
+	switch spec.LenType {
+	case Fixed:
+		if len(value) > spec.MaxLen {
+			return nil, fmt.Errorf("field %d (%s) exceeds length %d", n, spec.Name, spec.MaxLen)
+		}
+		switch spec.Encoding {
+		case Numeric:
+			value = strings.Repeat("0", spec.MaxLen-len(value)) + value
+		case Binary:
+			if len(value) != spec.MaxLen {
+				return nil, fmt.Errorf("field %d (%s) must be exactly %d bytes", n, spec.Name, spec.MaxLen)
+			}
+		default:
+			value += strings.Repeat(" ", spec.MaxLen-len(value))
+		}
+		return []byte(value), nil
+	case LLVAR, LLLVAR:
+		if len(value) > spec.MaxLen {
+			return nil, fmt.Errorf("field %d (%s) exceeds length %d", n, spec.Name, spec.MaxLen)
+		}
+		prefix := fmt.Sprintf("%0*d", spec.LenType, len(value))
+		return []byte(prefix + value), nil
+	default:
+		return nil, fmt.Errorf("field %d has unknown length type", n)
+	}
+}
+
+// Pack кодирует сообщение: MTI, двоичный битмап (с вторичным, если есть поля 65-128), затем поля по порядку
+func Pack(m *Message) ([]byte, error) {
+	if len(m.MTI) != 4 || strings.Trim(m.MTI, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid MTI %q", m.MTI)
+	}
+
+	bitmap := make([]byte, 8)
+	numbers := m.FieldNumbers()
+	for _, n := range numbers {
+		if n < 2 || n > 128 {
+			return nil, fmt.Errorf("field %d is out of range", n)
+		}
+		if n > 64 && len(bitmap) == 8 {
+			bitmap = append(bitmap, make([]byte, 8)...)
+			bitmap[0] |= 0x80
+		}
+		bitmap[(n-1)/8] |= 0x80 >> uint((n-1)%8)
+	}
+
+	out := []byte(m.MTI)
+	out = append(out, bitmap...)
+	for _, n := range numbers {
+		encoded, err := encodeField(n, m.Fields[n])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, encoded...)
+	}
+	return out, nil
+}
+
+// Unpack разбирает сообщение, закодированное Pack
+func Unpack(data []byte) (*Message, error) {
+	if len(data) < 12 {
+		return nil, errors.New("message too short")
+	}
+	m := NewMessage(string(data[:4]))
+	if strings.Trim(m.MTI, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid MTI %q", m.MTI)
+	}
+
+	bitmapLen := 8
+	if data[4]&0x80 != 0 {
+		bitmapLen = 16
+	}
+	if len(data) < 4+bitmapLen {
+		return nil, errors.New("message too short for bitmap")
+	}
+	bitmap := data[4 : 4+bitmapLen]
+	pos := 4 + bitmapLen
+
+	for n := 2; n <= bitmapLen*8; n++ {
+		if bitmap[(n-1)/8]&(0x80>>uint((n-1)%8)) == 0 {
+			continue
+		}
+		spec, ok := Spec[n]
+		if !ok {
+			return nil, fmt.Errorf("field %d is not supported", n)
+		}
+
+		length := spec.MaxLen
+		if spec.LenType != Fixed {
+			if len(data) < pos+spec.LenType {
+				return nil, fmt.Errorf("field %d: truncated length prefix", n)
+			}
+			l, err := strconv.Atoi(string(data[pos : pos+spec.LenType]))
+			if err != nil || l > spec.MaxLen {
+				return nil, fmt.Errorf("field %d: invalid length prefix", n)
+			}
+			length = l
+			pos += spec.LenType
+		}
+		if len(data) < pos+length {
+			return nil, fmt.Errorf("field %d: truncated value", n)
+		}
+
+		value := string(data[pos : pos+length])
+		if spec.Encoding == Alpha || spec.Encoding == AlphaSpecial {
+			value = strings.TrimRight(value, " ")
+		}
+		m.Fields[n] = value
+		pos += length
+	}
+
+	if pos != len(data) {
+		return nil, fmt.Errorf("%d unexpected trailing bytes", len(data)-pos)
+	}
+	return m, nil
+}
+
+// Максимальный размер кадра, принимаемого ReadFrame
+const maxFrameSize = 8192
+
+// ReadFrame читает сообщение с двухбайтовым префиксом длины (big-endian)
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(header[:]))
+	if size == 0 || size > maxFrameSize {
+		return nil, fmt.Errorf("invalid frame size %d", size)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// WriteFrame записывает сообщение с двухбайтовым префиксом длины (big-endian)
+func WriteFrame(w io.Writer, data []byte) error {
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("frame too large: %d bytes", len(data))
+	}
+	frame := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	copy(frame[2:], data)
+	_, err := w.Write(frame)
+	return err
+}
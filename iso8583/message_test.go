@@ -0,0 +1,183 @@
+package iso8583
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPackUnpackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		mti    string
+		fields map[int]string
+	}{
+		{
+			name: "authorization with PIN block",
+			mti:  MTIAuthorizationRequest,
+			fields: map[int]string{
+				2:  "4000001234567899",
+				3:  "000000",
+				4:  "000000150000",
+				14: "3012",
+				22: "051",
+				37: "000000000101",
+				41: "TERM0001",
+				42: "MRC123456789012",
+				49: "643",
+				52: "\x04\x12\x34\xfe\xdc\xba\x98\x76",
+			},
+		},
+		{
+			name: "purchase with CVV2 in LLLVAR field",
+			mti:  MTIFinancialRequest,
+			fields: map[int]string{
+				2:  "4000001234567899",
+				4:  "000000049990",
+				42: "MRC123456789012",
+				48: "123",
+			},
+		},
+		{
+			name: "secondary bitmap",
+			mti:  MTIReversalRequest,
+			fields: map[int]string{
+				11: "000042",
+				37: "000000000102",
+				90: "020000004212345678900000000000000000000000",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage(tt.mti)
+			for n, v := range tt.fields {
+				m.Set(n, v)
+			}
+			packed, err := Pack(m)
+			if err != nil {
+				t.Fatalf("Pack: %v", err)
+			}
+			got, err := Unpack(packed)
+			if err != nil {
+				t.Fatalf("Unpack: %v", err)
+			}
+			if got.MTI != tt.mti {
+				t.Errorf("MTI = %q, want %q", got.MTI, tt.mti)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", got.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestPackPadsFixedFields(t *testing.T) {
+	m := NewMessage(MTIFinancialRequest)
+	m.Set(4, "4999")
+	m.Set(41, "T1")
+	packed, err := Pack(m)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if want := []byte("000000004999T1      "); !bytes.HasSuffix(packed, want) {
+		t.Errorf("packed = %q, want suffix %q", packed, want)
+	}
+
+	got, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if v, _ := got.Get(4); v != "000000004999" {
+		t.Errorf("field 4 = %q, want zero-padded amount", v)
+	}
+	if v, _ := got.Get(41); v != "T1" {
+		t.Errorf("field 41 = %q, want trailing spaces trimmed", v)
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		mti   string
+		field int
+		value string
+	}{
+		{"invalid MTI", "01A0", 4, "1"},
+		{"non-numeric numeric field", MTIFinancialRequest, 4, "12.50"},
+		{"fixed field too long", MTIFinancialRequest, 3, "0000000"},
+		{"variable field too long", MTIFinancialRequest, 2, "40000012345678991234"},
+		{"binary field of wrong size", MTIFinancialRequest, 52, "1234"},
+		{"unsupported field", MTIFinancialRequest, 5, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage(tt.mti)
+			m.Set(tt.field, tt.value)
+			if _, err := Pack(m); err == nil {
+				t.Error("Pack succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	valid := NewMessage(MTIFinancialRequest)
+	valid.Set(2, "4000001234567899")
+	valid.Set(4, "000000049990")
+	packed, err := Pack(valid)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte("0200")},
+		{"invalid MTI", append([]byte("02X0"), packed[4:]...)},
+		{"truncated value", packed[:len(packed)-1]},
+		{"trailing bytes", append(append([]byte{}, packed...), '0')},
+		{"invalid length prefix", append(append([]byte{}, packed[:12]...), append([]byte("9X"), packed[14:]...)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unpack(tt.data); err == nil {
+				t.Error("Unpack succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, []byte("0800")); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes()[:2], []byte{0, 4}) {
+		t.Errorf("length prefix = %v, want [0 4]", buf.Bytes()[:2])
+	}
+	frame, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if string(frame) != "0800" {
+		t.Errorf("frame = %q, want %q", frame, "0800")
+	}
+}
+
+func TestResponseMTI(t *testing.T) {
+	tests := map[string]string{
+		MTIAuthorizationRequest: MTIAuthorizationResponse,
+		MTIFinancialRequest:     MTIFinancialResponse,
+		MTIReversalRequest:      MTIReversalResponse,
+	}
+	for req, want := range tests {
+		if got := ResponseMTI(req); got != want {
+			t.Errorf("ResponseMTI(%q) = %q, want %q", req, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// panBlock формирует PAN-блок ISO 9564 формата 0: "0000" и 12 правых цифр PAN без контрольной
+func panBlock(pan string) ([]byte, error) {
+	if len(pan) < 13 {
+		return nil, errors.New("PAN too short for PIN block")
+	}
+	digits := pan[len(pan)-13 : len(pan)-1]
+	return hex.DecodeString("0000" + digits)
+}
+
+// EncodePINBlock формирует открытый (незашифрованный) PIN-блок ISO 9564 формата 0.
+// В реальной сети блок шифруется ключом ZPK; симулятор передаёт его в открытом виде.
+func EncodePINBlock(pin, pan string) (string, error) {
+	if len(pin) < 4 || len(pin) > 12 || strings.Trim(pin, "0123456789") != "" {
+		return "", errors.New("PIN must be 4-12 digits")
+	}
+	pinField, err := hex.DecodeString(fmt.Sprintf("0%X%s%s", len(pin), pin, strings.Repeat("F", 14-len(pin))))
+	if err != nil {
+		return "", err
+	}
+	pb, err := panBlock(pan)
+	if err != nil {
+		return "", err
+	}
+	block := make([]byte, 8)
+	for i := range block {
+		block[i] = pinField[i] ^ pb[i]
+	}
+	return string(block), nil
+}
+
+// DecodePINBlock извлекает PIN из открытого PIN-блока формата 0
+func DecodePINBlock(block, pan string) (string, error) {
+	if len(block) != 8 {
+		return "", errors.New("PIN block must be 8 bytes")
+	}
+	pb, err := panBlock(pan)
+	if err != nil {
+		return "", err
+	}
+	pinField := make([]byte, 8)
+	for i := range pinField {
+		pinField[i] = block[i] ^ pb[i]
+	}
+	h := strings.ToUpper(hex.EncodeToString(pinField))
+	if h[0] != '0' {
+		return "", errors.New("unsupported PIN block format")
+	}
+	length := int(h[1] - '0')
+	if h[1] >= 'A' {
+		length = int(h[1]-'A') + 10
+	}
+	if length < 4 || length > 12 || strings.Trim(h[2:2+length], "0123456789") != "" {
+		return "", errors.New("malformed PIN block")
+	}
+	return h[2 : 2+length], nil
+}
@@ -0,0 +1,69 @@
+package iso8583
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEncodePINBlock(t *testing.T) {
+	tests := []struct {
+		pin, pan string
+		want     string
+	}{
+		{"1234", "4000001234567899", "041234FEDCBA9876"},
+		{"4821", "4000001234567899", "044821FEDCBA9876"},
+		{"123456", "5555000011112222", "06126456FEEEEDDD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pin+"/"+tt.pan, func(t *testing.T) {
+			block, err := EncodePINBlock(tt.pin, tt.pan)
+			if err != nil {
+				t.Fatalf("EncodePINBlock: %v", err)
+			}
+			if got := fmt.Sprintf("%X", block); got != tt.want {
+				t.Errorf("block = %s, want %s", got, tt.want)
+			}
+
+			pin, err := DecodePINBlock(block, tt.pan)
+			if err != nil {
+				t.Fatalf("DecodePINBlock: %v", err)
+			}
+			if pin != tt.pin {
+				t.Errorf("decoded PIN = %q, want %q", pin, tt.pin)
+			}
+		})
+	}
+}
+
+func TestEncodePINBlockErrors(t *testing.T) {
+	tests := []struct {
+		name, pin, pan string
+	}{
+		{"short PIN", "123", "4000001234567899"},
+		{"long PIN", "1234567890123", "4000001234567899"},
+		{"non-digit PIN", "12a4", "4000001234567899"},
+		{"short PAN", "1234", "400000123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EncodePINBlock(tt.pin, tt.pan); err == nil {
+				t.Error("EncodePINBlock succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestDecodePINBlockWrongPAN(t *testing.T) {
+	block, err := EncodePINBlock("4821", "4000001234567899")
+	if err != nil {
+		t.Fatalf("EncodePINBlock: %v", err)
+	}
+	if pin, err := DecodePINBlock(block, "4001234567899999"); err == nil && pin == "4821" {
+		t.Error("PIN block decoded with a different PAN")
+	}
+	if _, err := DecodePINBlock("short", "4000001234567899"); err == nil {
+		t.Error("DecodePINBlock accepted a block of wrong length")
+	}
+}
@@ -0,0 +1,94 @@
+// Package iso8583 реализует упаковку и разбор сообщений ISO 8583 (вариант 1987 года, ASCII-кодировка полей,
+// двоичный битмап), используемых шлюзом карточных авторизаций.
+package iso8583
+
+// Кодировка содержимого поля
+const (
+	Numeric      = "n"   // цифры, фиксированные поля дополняются нулями слева
+	Alpha        = "an"  // буквы и цифры, фиксированные поля дополняются пробелами справа
+	AlphaSpecial = "ans" // печатные символы, фиксированные поля дополняются пробелами справа
+	Binary       = "b"   // сырые байты
+)
+
+// Способ указания длины поля
+const (
+	Fixed  = 0 // фиксированная длина
+	LLVAR  = 2 // длина указана двумя ASCII-цифрами перед значением
+	LLLVAR = 3 // длина указана тремя ASCII-цифрами перед значением
+)
+
+// FieldSpec описывает формат одного поля сообщения
+type FieldSpec struct {
+	Name     string
+	Encoding string
+	LenType  int
+	MaxLen   int // для фиксированных полей — точная длина
+}
+
+// Spec — поддерживаемые шлюзом поля ISO 8583:1987
+var Spec = map[int]FieldSpec{
+	2:   {"Primary account number", Numeric, LLVAR, 19},
+	3:   {"Processing code", Numeric, Fixed, 6},
+	4:   {"Amount, transaction", Numeric, Fixed, 12},
+	7:   {"Transmission date and time", Numeric, Fixed, 10},
+	11:  {"System trace audit number", Numeric, Fixed, 6},
+	12:  {"Time, local transaction", Numeric, Fixed, 6},
+	13:  {"Date, local transaction", Numeric, Fixed, 4},
+	14:  {"Date, expiration", Numeric, Fixed, 4},
+	18:  {"Merchant type", Numeric, Fixed, 4},
+	19:  {"Acquiring institution country code", Numeric, Fixed, 3},
+	22:  {"POS entry mode", Numeric, Fixed, 3},
+	32:  {"Acquiring institution identification code", Numeric, LLVAR, 11},
+	37:  {"Retrieval reference number", Alpha, Fixed, 12},
+	38:  {"Authorization identification response", Alpha, Fixed, 6},
+	39:  {"Response code", Alpha, Fixed, 2},
+	41:  {"Card acceptor terminal identification", AlphaSpecial, Fixed, 8},
+	42:  {"Card acceptor identification code", AlphaSpecial, Fixed, 15},
+	43:  {"Card acceptor name/location", AlphaSpecial, Fixed, 40},
+	48:  {"Additional data - private", AlphaSpecial, LLLVAR, 999},
+	49:  {"Currency code, transaction", Numeric, Fixed, 3},
+	52:  {"PIN data", Binary, Fixed, 8},
+	64:  {"Message authentication code", Binary, Fixed, 8},
+	90:  {"Original data elements", Numeric, Fixed, 42},
+	128: {"Message authentication code", Binary, Fixed, 8},
+}
+
+// Типы сообщений (MTI)
+const (
+	MTIAuthorizationRequest  = "0100"
+	MTIAuthorizationResponse = "0110"
+	MTIFinancialRequest      = "0200"
+	MTIFinancialResponse     = "0210"
+	MTIReversalRequest       = "0400"
+	MTIReversalResponse      = "0410"
+)
+
+// Коды ответа (поле 39)
+const (
+	ResponseApproved           = "00"
+	ResponseInvalidMerchant    = "03"
+	ResponseDoNotHonor         = "05"
+	ResponseInvalidTransaction = "12"
+	ResponseInvalidAmount      = "13"
+	ResponseInvalidCardNumber  = "14"
+	ResponseUnableToLocate     = "25"
+	ResponseFormatError        = "30"
+	ResponseInsufficientFunds  = "51"
+	ResponseExpiredCard        = "54"
+	ResponseIncorrectPIN       = "55"
+	ResponseNotPermittedCard   = "57"
+	ResponseExceedsLimit       = "61"
+	ResponseRestrictedCard     = "62"
+	ResponseSecurityViolation  = "63"
+	ResponsePINTriesExceeded   = "75"
+	ResponseSystemMalfunction  = "96"
+	ResponseCVVFailure         = "N7"
+)
+
+// ResponseMTI возвращает MTI ответа на запрос (0100 -> 0110 и т.д.)
+func ResponseMTI(mti string) string {
+	if len(mti) != 4 {
+		return mti
+	}
+	return mti[:2] + string(mti[2]+1) + mti[3:]
+}
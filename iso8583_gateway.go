@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"Banks/iso8583"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Цифровой код страны банка и валюты по ISO 3166 / ISO 4217
+const (
+	isoHomeCountryCode = "643"
+	isoCurrencyRUB     = "643"
+)
+
+// Тайм-аут неактивного соединения с эквайером
+const isoIdleTimeout = 5 * time.Minute
+
+// isoReference связывает RRN исходной операции эквайера с авторизацией или транзакцией банка
+type isoReference struct {
+	AuthorizationID string
+	TransactionID   string
+	Amount          decimal.Decimal
+	ReversedAmount  decimal.Decimal
+	ReversalSTANs   map[string]bool // обработанные реверсалы, по полю 11
+}
+
+// ISOGateway принимает сообщения ISO 8583 по TCP и проводит их через карточную логику банка
+type ISOGateway struct {
+	mu   sync.Mutex
+	refs map[string]isoReference // key: MerchantID + ":" + RRN
+}
+
+func NewISOGateway() *ISOGateway {
+	return &ISOGateway{refs: make(map[string]isoReference)}
+}
+
+// StartISO8583Gateway запускает TCP-листенер шлюза в фоне
+func StartISO8583Gateway(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	gateway := NewISOGateway()
+	go gateway.Serve(listener)
+	return nil
+}
+
+func (g *ISOGateway) Serve(listener net.Listener) {
+	log.Printf("ISO 8583 gateway listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("ISO 8583 gateway accept error: %v", err)
+			return
+		}
+		go g.handleConn(conn)
+	}
+}
+
+func (g *ISOGateway) handleConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetReadDeadline(time.Now().Add(isoIdleTimeout))
+		frame, err := iso8583.ReadFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("ISO 8583 read error from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		req, err := iso8583.Unpack(frame)
+		if err != nil {
+			log.Printf("ISO 8583 malformed message from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		resp := g.Handle(req)
+		out, err := iso8583.Pack(resp)
+		if err != nil {
+			log.Printf("ISO 8583 failed to pack %s response: %v", resp.MTI, err)
+			return
+		}
+		if err := iso8583.WriteFrame(conn, out); err != nil {
+			log.Printf("ISO 8583 write error to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// Handle обрабатывает запрос и формирует ответ с эхом ключевых полей и кодом ответа в поле 39
+func (g *ISOGateway) Handle(req *iso8583.Message) *iso8583.Message {
+	resp := iso8583.NewMessage(iso8583.ResponseMTI(req.MTI))
+	for _, n := range []int{2, 3, 4, 7, 11, 12, 13, 32, 37, 41, 42, 49} {
+		if v, ok := req.Get(n); ok {
+			resp.Set(n, v)
+		}
+	}
+	if _, ok := resp.Get(37); !ok {
+		resp.Set(37, generateRRN())
+	}
+
+	merchant, code := authenticateAcceptor(req)
+	if code == "" {
+		switch req.MTI {
+		case iso8583.MTIAuthorizationRequest, iso8583.MTIFinancialRequest:
+			code = g.handleCardRequest(req, resp, merchant)
+		case iso8583.MTIReversalRequest:
+			code = g.handleReversal(req, resp, merchant)
+		default:
+			resp.MTI = iso8583.MTIFinancialResponse
+			code = iso8583.ResponseInvalidTransaction
+		}
+	}
+	resp.Set(39, code)
+	// Ответ подписывается ключом ТСП, только если запрос прошёл проверку MAC
+	if merchant.ID != "" {
+		if err := iso8583.SignMAC(resp, []byte(merchant.TerminalMACKey)); err != nil {
+			log.Printf("ISO 8583 failed to sign %s response: %v", resp.MTI, err)
+		}
+	}
+
+	stan, _ := req.Get(11)
+	log.Printf("ISO 8583 %s STAN %s -> %s response %s", req.MTI, stan, resp.MTI, code)
+	return resp
+}
+
+// authenticateAcceptor находит ТСП по полю 42 и проверяет MAC сообщения его ключом.
+// Возвращает код ответа, если сообщение не прошло проверку.
+func authenticateAcceptor(req *iso8583.Message) (Merchant, string) {
+	acceptorID, ok := req.Get(42)
+	if !ok {
+		return Merchant{}, iso8583.ResponseFormatError
+	}
+	merchant, ok := GetMerchantByAcceptorID(acceptorID)
+	if !ok || merchant.TerminalMACKey == "" {
+		return Merchant{}, iso8583.ResponseInvalidMerchant
+	}
+	if err := iso8583.VerifyMAC(req, []byte(merchant.TerminalMACKey)); err != nil {
+		log.Printf("ISO 8583 %s from acceptor %s rejected: %v", req.MTI, acceptorID, err)
+		return Merchant{}, iso8583.ResponseSecurityViolation
+	}
+	return merchant, ""
+}
+
+// parseISOAmount переводит поле 4 (сумма в копейках) в decimal
+func parseISOAmount(field string) (decimal.Decimal, error) {
+	minor, err := decimal.NewFromString(field)
+	if err != nil || !minor.IsPositive() {
+		return decimal.Zero, errors.New("invalid amount")
+	}
+	return minor.Shift(-2), nil
+}
+
+// channelFromPOSEntryMode определяет канал по первым двум цифрам поля 22
+func channelFromPOSEntryMode(mode string) string {
+	switch {
+	case strings.HasPrefix(mode, "07"), strings.HasPrefix(mode, "91"):
+		return CardChannelContactless
+	case strings.HasPrefix(mode, "01"), strings.HasPrefix(mode, "81"):
+		return CardChannelOnline
+	default:
+		return CardChannelChip
+	}
+}
+
+func (g *ISOGateway) handleCardRequest(req, resp *iso8583.Message, merchant Merchant) string {
+	pan, okPAN := req.Get(2)
+	amountField, okAmount := req.Get(4)
+	expiry, okExpiry := req.Get(14)
+	if !okPAN || !okAmount || !okExpiry {
+		return iso8583.ResponseFormatError
+	}
+	// Держатель карты подтверждается PIN-блоком (поле 52) или CVV2 (поле 48); без них операция не проводится
+	pinBlock, okPIN := req.Get(52)
+	cvv, okCVV := req.Get(48)
+	if !okPIN && !okCVV {
+		return iso8583.ResponseFormatError
+	}
+	if currency, ok := req.Get(49); ok && currency != isoCurrencyRUB {
+		return iso8583.ResponseInvalidAmount
+	}
+	amount, err := parseISOAmount(amountField)
+	if err != nil {
+		return iso8583.ResponseInvalidAmount
+	}
+
+	card, ok := GetCardByPAN(pan)
+	if !ok {
+		return iso8583.ResponseInvalidCardNumber
+	}
+	if err := checkCardActive(card); err != nil {
+		return isoResponseCode(err)
+	}
+	if expiry != fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth) {
+		return iso8583.ResponseExpiredCard
+	}
+	if okPIN {
+		pin, err := iso8583.DecodePINBlock(pinBlock, pan)
+		if err != nil {
+			return iso8583.ResponseIncorrectPIN
+		}
+		if err := verifyPIN(card, pin); err != nil {
+			return isoResponseCode(err)
+		}
+	} else if err := bcrypt.CompareHashAndPassword([]byte(card.CVV), []byte(cvv)); err != nil {
+		return iso8583.ResponseCVVFailure
+	}
+
+	posEntryMode, _ := req.Get(22)
+	// Коды стран ISO 8583 цифровые: отличный от 643 код считается зарубежной операцией
+	country := homeCountry
+	if c, ok := req.Get(19); ok && c != isoHomeCountryCode {
+		country = c
+	}
+	use := newCardUse(amount, merchant.MCC, channelFromPOSEntryMode(posEntryMode), country, merchant.ID)
+
+	rrn, _ := resp.Get(37)
+	description := fmt.Sprintf("Payment to %s", merchant.Name)
+
+	ref := isoReference{Amount: amount, ReversedAmount: decimal.Zero}
+	if req.MTI == iso8583.MTIAuthorizationRequest {
+		auth, err := authorizeCard(card, use, description, merchant)
+		if err != nil {
+			return isoResponseCode(err)
+		}
+		ref.AuthorizationID = auth.ID
+	} else {
+		tx, err := chargeCard(card, use, description, &merchant)
+		if err != nil {
+			return isoResponseCode(err)
+		}
+		ref.TransactionID = tx.ID
+	}
+
+	g.mu.Lock()
+	g.refs[merchant.ID+":"+rrn] = ref
+	g.mu.Unlock()
+
+	resp.Set(38, strings.ToUpper(GenerateSecureToken(3)))
+	return iso8583.ResponseApproved
+}
+
+// handleReversal отменяет исходную операцию по RRN: авторизация снимается целиком, оплата возвращается клиенту
+// полностью или на сумму из поля 4. Повтор реверсала с тем же STAN (поле 11) подтверждается без изменений.
+func (g *ISOGateway) handleReversal(req, resp *iso8583.Message, merchant Merchant) string {
+	rrn, okRRN := req.Get(37)
+	stan, okSTAN := req.Get(11)
+	if !okRRN || !okSTAN {
+		return iso8583.ResponseFormatError
+	}
+
+	key := merchant.ID + ":" + rrn
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ref, ok := g.refs[key]
+	if !ok {
+		return iso8583.ResponseUnableToLocate
+	}
+	if ref.ReversalSTANs[stan] {
+		return iso8583.ResponseApproved
+	}
+
+	remaining := ref.Amount.Sub(ref.ReversedAmount)
+	if !remaining.IsPositive() {
+		return iso8583.ResponseInvalidTransaction
+	}
+	amount := remaining
+	if field, ok := req.Get(4); ok && ref.TransactionID != "" {
+		parsed, err := parseISOAmount(field)
+		if err != nil || parsed.GreaterThan(remaining) {
+			return iso8583.ResponseInvalidAmount
+		}
+		amount = parsed
+	}
+
+	if ref.AuthorizationID != "" {
+		if _, err := VoidAuthorization(ref.AuthorizationID, merchant.ID); err != nil {
+			return isoResponseCode(err)
+		}
+	} else {
+		_, err := RefundTransaction(merchant.ID, Transaction{
+			ID:                    GenerateID(),
+			Amount:                amount,
+			Timestamp:             time.Now(),
+			Description:           fmt.Sprintf("Reversal of payment to %s", merchant.Name),
+			OriginalTransactionID: ref.TransactionID,
+		})
+		if err != nil {
+			return isoResponseCode(err)
+		}
+	}
+
+	ref.ReversedAmount = ref.ReversedAmount.Add(amount)
+	if ref.ReversalSTANs == nil {
+		ref.ReversalSTANs = make(map[string]bool)
+	}
+	ref.ReversalSTANs[stan] = true
+	g.refs[key] = ref
+	return iso8583.ResponseApproved
+}
+
+// isoResponseCode переводит ошибки карточной логики в коды ответа поля 39
+func isoResponseCode(err error) string {
+	var pe *PaymentError
+	if errors.As(err, &pe) {
+		switch pe.Code {
+		case DeclineCardNotFound:
+			return iso8583.ResponseInvalidCardNumber
+		case DeclineCardExpired:
+			return iso8583.ResponseExpiredCard
+		case DeclineCardClosed, DeclineCardInUse:
+			return iso8583.ResponseRestrictedCard
+		case DeclineInsufficientFunds:
+			return iso8583.ResponseInsufficientFunds
+		case DeclineIncorrectPIN, DeclinePINNotSet:
+			return iso8583.ResponseIncorrectPIN
+		case DeclinePINBlocked:
+			return iso8583.ResponsePINTriesExceeded
+		case DeclinePerTransactionLimit, DeclineDailyLimit, DeclineMonthlyLimit, DeclineCounterpartyLimit:
+			return iso8583.ResponseExceedsLimit
+		case DeclineMCCNotAllowed, DeclineMCCBlocked, DeclineOnlineDisabled, DeclineContactlessDisabled,
+			DeclineForeignDisabled, DeclineMerchantNotAllowed, DeclineDualApproval:
+			return iso8583.ResponseNotPermittedCard
+		}
+		if pe.Status >= http.StatusInternalServerError {
+			return iso8583.ResponseSystemMalfunction
+		}
+		return iso8583.ResponseDoNotHonor
+	}
+
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		return iso8583.ResponseInsufficientFunds
+	case errors.Is(err, ErrAuthorizationNotFound), errors.Is(err, ErrTransactionNotFound):
+		return iso8583.ResponseUnableToLocate
+	case errors.Is(err, ErrAuthorizationClosed), errors.Is(err, ErrRefundExceedsOriginal):
+		return iso8583.ResponseInvalidTransaction
+	}
+	return iso8583.ResponseSystemMalfunction
+}
+
+// generateRRN генерирует 12-значный номер ссылки для ответов на запросы без поля 37
+func generateRRN() string {
+	n := GenerateSecureToken(6)
+	digits := make([]byte, 12)
+	for i := range digits {
+		digits[i] = '0' + n[i]%10
+	}
+	return string(digits)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+var ErrKeyManagerNotInitialized = errors.New("key manager is not initialized")
+
+// keyManager хранит PGP-ключи шифрования номеров карт; инициализируется при старте сервиса
+var keyManager *KeyManager
+
+// KeyManager загружает PGP-ключи один раз и держит их в памяти.
+// Публичный ключ PGP_PUBLIC_KEY_PATH — активная версия, которой шифруются новые номера карт.
+// PGP_PRIVATE_KEY_PATH — список файлов приватных ключей через запятую: активная и выведенные версии,
+// нужные для расшифровки карт, ещё не перешифрованных после ротации.
+type KeyManager struct {
+	publicKeyPath   string
+	privateKeyPaths []string
+	passphrase      []byte
+
+	mu          sync.RWMutex
+	activeKeyID string
+	publicKey   openpgp.EntityList
+	privateKeys openpgp.EntityList
+	keyIDs      map[string]bool // версии ключей, для которых загружен приватный ключ
+}
+
+// pgpKeyID возвращает идентификатор версии ключа — ID основного ключа сущности
+func pgpKeyID(entity *openpgp.Entity) string {
+	return entity.PrimaryKey.KeyIdString()
+}
+
+func NewKeyManager(publicKeyPath string, privateKeyPaths []string, passphrase []byte) (*KeyManager, error) {
+	km := &KeyManager{
+		publicKeyPath:   publicKeyPath,
+		privateKeyPaths: privateKeyPaths,
+		passphrase:      passphrase,
+	}
+	if err := km.Reload(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// InitKeyManager создаёт менеджер ключей из переменных окружения
+// PGP_PUBLIC_KEY_PATH, PGP_PRIVATE_KEY_PATH и PGP_KEY_PASSPHRASE
+func InitKeyManager() error {
+	publicKeyPath := os.Getenv("PGP_PUBLIC_KEY_PATH")
+	if publicKeyPath == "" {
+		return errors.New("PGP_PUBLIC_KEY_PATH not set")
+	}
+	var privateKeyPaths []string
+	for _, path := range strings.Split(os.Getenv("PGP_PRIVATE_KEY_PATH"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			privateKeyPaths = append(privateKeyPaths, path)
+		}
+	}
+
+	km, err := NewKeyManager(publicKeyPath, privateKeyPaths, []byte(os.Getenv("PGP_KEY_PASSPHRASE")))
+	if err != nil {
+		return err
+	}
+	keyManager = km
+	return nil
+}
+
+// activeKeyManager возвращает менеджер ключей или ошибку, если ключи не были загружены
+func activeKeyManager() (*KeyManager, error) {
+	if keyManager == nil {
+		return nil, ErrKeyManagerNotInitialized
+	}
+	return keyManager, nil
+}
+
+// Reload перечитывает файлы ключей. При ошибке продолжают действовать ранее загруженные ключи.
+func (km *KeyManager) Reload() error {
+	publicKey, err := LoadPublicKey(km.publicKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load PGP public key: %w", err)
+	}
+	if len(publicKey) != 1 {
+		return fmt.Errorf("PGP public key file must contain exactly one key, found %d", len(publicKey))
+	}
+
+	var privateKeys openpgp.EntityList
+	keyIDs := make(map[string]bool)
+	for _, path := range km.privateKeyPaths {
+		entities, err := LoadPrivateKey(path, km.passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to load PGP private key %s: %w", path, err)
+		}
+		for _, entity := range entities {
+			if entity.PrivateKey == nil {
+				return fmt.Errorf("%s contains public key %s without a private part", path, pgpKeyID(entity))
+			}
+			keyIDs[pgpKeyID(entity)] = true
+		}
+		privateKeys = append(privateKeys, entities...)
+	}
+
+	activeKeyID := pgpKeyID(publicKey[0])
+	if !keyIDs[activeKeyID] {
+		log.Printf("Warning: no private key loaded for active PGP key %s, card numbers cannot be decrypted", activeKeyID)
+	}
+
+	km.mu.Lock()
+	previousKeyID := km.activeKeyID
+	km.activeKeyID = activeKeyID
+	km.publicKey = publicKey
+	km.privateKeys = privateKeys
+	km.keyIDs = keyIDs
+	km.mu.Unlock()
+
+	if previousKeyID != "" && previousKeyID != activeKeyID {
+		log.Printf("Active PGP key rotated from %s to %s", previousKeyID, activeKeyID)
+	}
+	return nil
+}
+
+func (km *KeyManager) ActiveKeyID() string {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	return km.activeKeyID
+}
+
+// KeyIDs возвращает отсортированный список версий ключей, доступных для расшифровки
+func (km *KeyManager) KeyIDs() []string {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	ids := make([]string, 0, len(km.keyIDs))
+	for id := range km.keyIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Encrypt шифрует текст активным ключом и возвращает шифртекст вместе с версией ключа
+func (km *KeyManager) Encrypt(plaintext string) (string, string, error) {
+	km.mu.RLock()
+	publicKey, keyID := km.publicKey, km.activeKeyID
+	km.mu.RUnlock()
+
+	ciphertext, err := EncryptWithPGP(plaintext, publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	return ciphertext, keyID, nil
+}
+
+// Decrypt расшифровывает текст, зашифрованный ключом версии keyID.
+// Пустой keyID — карты, выпущенные до появления версий ключей; для них перебираются все ключи.
+func (km *KeyManager) Decrypt(ciphertext, keyID string) (string, error) {
+	km.mu.RLock()
+	privateKeys, known := km.privateKeys, keyID == "" || km.keyIDs[keyID]
+	km.mu.RUnlock()
+
+	if !known {
+		return "", fmt.Errorf("PGP key %s is not loaded", keyID)
+	}
+	return DecryptWithPGP(ciphertext, privateKeys)
+}
+
+// WatchReloadSignal перечитывает ключи по SIGHUP и после смены активного ключа перешифровывает карты
+func (km *KeyManager) WatchReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := km.Reload(); err != nil {
+				log.Printf("PGP key reload failed, keeping previous keys: %v", err)
+				continue
+			}
+			log.Printf("PGP keys reloaded, active key %s", km.ActiveKeyID())
+			StartCardReencryption()
+		}
+	}()
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Уровни обслуживания клиента, от которых зависят лимиты
+const (
+	TierStandard = "standard"
+	TierPremium  = "premium"
+	TierBusiness = "business"
+)
+
+// Типы операций, на которые действуют лимиты
+const (
+	LimitOpTransfer      = "transfer"
+	LimitOpCardPayment   = "card_payment"
+	LimitOpATMWithdrawal = "atm_withdrawal"
+)
+
+var limitOperations = []string{LimitOpTransfer, LimitOpCardPayment, LimitOpATMWithdrawal}
+
+// Окна, по которым считаются лимиты
+const (
+	LimitWindowPerOperation         = "per_operation"
+	LimitWindowDaily                = "daily"
+	LimitWindowMonthly              = "monthly"
+	LimitWindowPerCounterpartyDaily = "per_counterparty_daily"
+)
+
+// Код отказа по лимиту на одного получателя
+const DeclineCounterpartyLimit = "counterparty_limit_exceeded"
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// OperationLimits — лимиты на один тип операций; нулевое значение означает отсутствие лимита
+type OperationLimits struct {
+	PerOperation         decimal.Decimal `json:"per_operation"`
+	Daily                decimal.Decimal `json:"daily"`
+	Monthly              decimal.Decimal `json:"monthly"`
+	PerCounterpartyDaily decimal.Decimal `json:"per_counterparty_daily"` // сумма за день в пользу одного получателя или мерчанта
+}
+
+// LimitError описывает превышенный лимит
+type LimitError struct {
+	Operation string
+	Window    string
+	Limit     decimal.Decimal
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %s limit of %s exceeded", e.Window, e.Operation, e.Limit.String())
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// LimitedOperation — операция, проверяемая по лимитам
+type LimitedOperation struct {
+	Type         string
+	Counterparty string // счёт получателя или мерчант; пусто — лимит на получателя не применяется
+	Amount       decimal.Decimal
+}
+
+//go:embed data/limits.json
+var embeddedLimits []byte
+
+// limitsConfig: уровень -> тип операции -> лимиты. Пока лимиты не загружены, они не проверяются.
+var limitsConfig map[string]map[string]OperationLimits
+
+// InitLimits загружает встроенные лимиты и, если задан, файл LIMITS_CONFIG_PATH
+// в том же формате; уровни из файла заменяют встроенные целиком
+func InitLimits() error {
+	config, err := parseLimits(embeddedLimits)
+	if err != nil {
+		return fmt.Errorf("встроенные лимиты: %w", err)
+	}
+	if path := os.Getenv("LIMITS_CONFIG_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		overrides, err := parseLimits(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for tier, limits := range overrides {
+			config[tier] = limits
+		}
+	}
+	if _, ok := config[TierStandard]; !ok {
+		return fmt.Errorf("не заданы лимиты уровня %s", TierStandard)
+	}
+	limitsConfig = config
+	return nil
+}
+
+func parseLimits(data []byte) (map[string]map[string]OperationLimits, error) {
+	var config map[string]map[string]OperationLimits
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	for tier, limits := range config {
+		for op, l := range limits {
+			if !containsString(limitOperations, op) {
+				return nil, fmt.Errorf("уровень %s: неизвестный тип операции %q", tier, op)
+			}
+			if l.PerOperation.IsNegative() || l.Daily.IsNegative() || l.Monthly.IsNegative() || l.PerCounterpartyDaily.IsNegative() {
+				return nil, fmt.Errorf("уровень %s, %s: лимиты не могут быть отрицательными", tier, op)
+			}
+		}
+	}
+	return config, nil
+}
+
+func isKnownTier(tier string) bool {
+	_, ok := limitsConfig[tier]
+	return ok
+}
+
+// tierLimits возвращает лимиты уровня на тип операций; неизвестный уровень считается стандартным
+func tierLimits(tier, op string) OperationLimits {
+	limits, ok := limitsConfig[tier]
+	if !ok {
+		limits = limitsConfig[TierStandard]
+	}
+	return limits[op]
+}
+
+// limitOperationOf возвращает тип операции транзакции с точки зрения лимитов
+func limitOperationOf(tx Transaction) string {
+	switch tx.TransactionType {
+	case "transfer", "external_transfer", "sbp_transfer", "sbp_payment", "bill_payment":
+		return LimitOpTransfer
+	case "payment":
+		return LimitOpCardPayment
+	case "atm_withdrawal":
+		return LimitOpATMWithdrawal
+	}
+	return ""
+}
+
+// Форматы периодов, за которые копятся нарастающие итоги лимитов
+const (
+	limitDayLayout   = "2006-01-02"
+	limitMonthLayout = "2006-01"
+)
+
+// limitUsageKey — нарастающий итог операций типа Operation владельца лимитов за день или месяц
+// (Period в формате limitDayLayout или limitMonthLayout); с Counterparty — дневной итог в пользу получателя
+type limitUsageKey struct {
+	Subject      string // ID пользователя или, для счетов организации, ID организации
+	Operation    string
+	Period       string
+	Counterparty string
+}
+
+// limitSubject возвращает, чьи лимиты расходует списание со счёта: лимиты счетов организации
+// общие для всех её счетов и подписантов
+func limitSubject(acc Account) string {
+	if acc.OrganizationID != "" {
+		return acc.OrganizationID
+	}
+	return acc.UserID
+}
+
+// limitExempt сообщает, что перевод идёт между своими счетами и лимиты не расходует:
+// между личными счетами одного клиента или между счетами одной организации
+func limitExempt(from, to Account) bool {
+	if from.OrganizationID != "" || to.OrganizationID != "" {
+		return from.OrganizationID == to.OrganizationID
+	}
+	return from.UserID == to.UserID
+}
+
+// cardLimitSubject — владелец итогов оплат по карте для её собственных дневного и месячного лимитов
+func cardLimitSubject(cardID string) string {
+	return "card:" + cardID
+}
+
+// addLimitUsage прибавляет сумму к итогам за день и месяц и к дневному итогу в пользу получателя.
+// Вызывается под storage.mu.
+func addLimitUsage(subject, op, counterparty string, at time.Time, amount decimal.Decimal) {
+	keys := []limitUsageKey{
+		{Subject: subject, Operation: op, Period: at.Format(limitDayLayout)},
+		{Subject: subject, Operation: op, Period: at.Format(limitMonthLayout)},
+	}
+	if counterparty != "" {
+		keys = append(keys, limitUsageKey{Subject: subject, Operation: op, Period: at.Format(limitDayLayout), Counterparty: counterparty})
+	}
+	for _, key := range keys {
+		if total := storage.limitUsage[key].Add(amount); total.IsPositive() {
+			storage.limitUsage[key] = total
+		} else {
+			delete(storage.limitUsage, key)
+		}
+	}
+}
+
+// recordLimitUsage учитывает в итогах лимитов проведённую транзакцию (amount — её сумма)
+// или её сторно (amount — сумма со знаком минус). Вызывается под storage.mu.
+func recordLimitUsage(tx Transaction, amount decimal.Decimal) {
+	if tx.CardID != "" && tx.TransactionType == "payment" {
+		addLimitUsage(cardLimitSubject(tx.CardID), LimitOpCardPayment, "", tx.Timestamp, amount)
+	}
+	op := limitOperationOf(tx)
+	from, ok := storage.accounts[tx.FromAccountID]
+	if op == "" || !ok {
+		return
+	}
+	counterparty := tx.MerchantID
+	if op == LimitOpTransfer && tx.ExternalAccount != "" {
+		counterparty = tx.ExternalAccount
+	} else if op == LimitOpTransfer {
+		if limitExempt(from, storage.accounts[tx.ToAccountID]) {
+			return
+		}
+		counterparty = tx.ToAccountID
+	}
+	addLimitUsage(limitSubject(from), op, counterparty, tx.Timestamp, amount)
+}
+
+// recordHoldUsage учитывает изменение холда авторизации в лимитах на оплаты картой
+// за день её создания. Вызывается под storage.mu.
+func recordHoldUsage(auth CardAuthorization, amount decimal.Decimal) {
+	addLimitUsage(cardLimitSubject(auth.CardID), LimitOpCardPayment, "", auth.CreatedAt, amount)
+	if acc, ok := storage.accounts[auth.AccountID]; ok {
+		addLimitUsage(limitSubject(acc), LimitOpCardPayment, auth.MerchantID, auth.CreatedAt, amount)
+	}
+}
+
+// limitUsage возвращает сумму операций типа op владельца лимитов за период (день или месяц),
+// а если задан counterparty — только в его пользу. По картам учитываются и удерживаемые
+// авторизации. Вызывается под storage.mu.
+func limitUsage(subject, op, counterparty, period string) decimal.Decimal {
+	return storage.limitUsage[limitUsageKey{Subject: subject, Operation: op, Period: period, Counterparty: counterparty}]
+}
+
+// PruneLimitUsage удаляет итоги лимитов за прошедшие месяцы
+func PruneLimitUsage(now time.Time) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	month := now.Format(limitMonthLayout)
+	for key := range storage.limitUsage {
+		// День текущего месяца ("2006-01-02") сравнивается больше месяца ("2006-01"), прошедшие — меньше
+		if key.Period < month {
+			delete(storage.limitUsage, key)
+		}
+	}
+}
+
+// checkLimits проверяет списания со счёта from по лимитам с учётом уже проведённых операций.
+// Для личного счёта действуют лимиты уровня клиента, для счёта организации — лимиты уровня
+// business, общие для всех её счетов. Несколько операций (пакет переводов) проверяются
+// нарастающим итогом. Вызывается под storage.mu.
+func checkLimits(from Account, ops []LimitedOperation, now time.Time) error {
+	if limitsConfig == nil {
+		return nil
+	}
+	tier := TierBusiness
+	if from.OrganizationID == "" {
+		user, ok := storage.users[from.UserID]
+		if !ok {
+			return nil
+		}
+		tier = user.Tier
+	}
+	subject := limitSubject(from)
+	day, month := now.Format(limitDayLayout), now.Format(limitMonthLayout)
+
+	// Использованные суммы считаются один раз и дальше накапливаются по операциям пакета
+	used := make(map[string]decimal.Decimal)
+	exceeds := func(op LimitedOperation, window string, limit decimal.Decimal, counterparty, period string) bool {
+		if !limit.IsPositive() {
+			return false
+		}
+		key := op.Type + "|" + window + "|" + counterparty
+		total, seen := used[key]
+		if !seen {
+			total = limitUsage(subject, op.Type, counterparty, period)
+		}
+		total = total.Add(op.Amount)
+		used[key] = total
+		return total.GreaterThan(limit)
+	}
+
+	for _, op := range ops {
+		limits := tierLimits(tier, op.Type)
+		switch {
+		case limits.PerOperation.IsPositive() && op.Amount.GreaterThan(limits.PerOperation):
+			return &LimitError{Operation: op.Type, Window: LimitWindowPerOperation, Limit: limits.PerOperation}
+		case exceeds(op, LimitWindowDaily, limits.Daily, "", day):
+			return &LimitError{Operation: op.Type, Window: LimitWindowDaily, Limit: limits.Daily}
+		case exceeds(op, LimitWindowMonthly, limits.Monthly, "", month):
+			return &LimitError{Operation: op.Type, Window: LimitWindowMonthly, Limit: limits.Monthly}
+		case op.Counterparty != "" && exceeds(op, LimitWindowPerCounterpartyDaily, limits.PerCounterpartyDaily, op.Counterparty, day):
+			return &LimitError{Operation: op.Type, Window: LimitWindowPerCounterpartyDaily, Limit: limits.PerCounterpartyDaily}
+		}
+	}
+	return nil
+}
+
+// cardLimitDecline переводит превышение лимита карточной операции в отказ с кодом
+func cardLimitDecline(err error) error {
+	var le *LimitError
+	if !errors.As(err, &le) {
+		return err
+	}
+	code := DeclinePerTransactionLimit
+	switch le.Window {
+	case LimitWindowDaily:
+		code = DeclineDailyLimit
+	case LimitWindowMonthly:
+		code = DeclineMonthlyLimit
+	case LimitWindowPerCounterpartyDaily:
+		code = DeclineCounterpartyLimit
+	}
+	return declinePayment(http.StatusForbidden, code, fmt.Sprintf("Amount exceeds %s", le.Error()))
+}
+
+// LimitWindowStatus — лимит за период и его использование
+type LimitWindowStatus struct {
+	Limit     decimal.Decimal `json:"limit"`
+	Used      decimal.Decimal `json:"used"`
+	Remaining decimal.Decimal `json:"remaining"`
+}
+
+// OperationLimitsStatus — лимиты и остатки по одному типу операций; отсутствующее окно — без лимита
+type OperationLimitsStatus struct {
+	Operation            string             `json:"operation"`
+	PerOperation         decimal.Decimal    `json:"per_operation"`
+	PerCounterpartyDaily decimal.Decimal    `json:"per_counterparty_daily"`
+	Daily                *LimitWindowStatus `json:"daily,omitempty"`
+	Monthly              *LimitWindowStatus `json:"monthly,omitempty"`
+}
+
+func newLimitWindowStatus(limit, used decimal.Decimal) *LimitWindowStatus {
+	if !limit.IsPositive() {
+		return nil
+	}
+	return &LimitWindowStatus{Limit: limit, Used: used, Remaining: decimal.Max(decimal.Zero, limit.Sub(used))}
+}
+
+// GetLimitsStatus возвращает уровень пользователя и остатки лимитов на текущий день и месяц
+func GetLimitsStatus(userID string, now time.Time) (string, []OperationLimitsStatus) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	tier := storage.users[userID].Tier
+	if !isKnownTier(tier) {
+		tier = TierStandard
+	}
+	day, month := now.Format(limitDayLayout), now.Format(limitMonthLayout)
+
+	statuses := make([]OperationLimitsStatus, 0, len(limitOperations))
+	for _, op := range limitOperations {
+		limits := tierLimits(tier, op)
+		statuses = append(statuses, OperationLimitsStatus{
+			Operation:            op,
+			PerOperation:         limits.PerOperation,
+			PerCounterpartyDaily: limits.PerCounterpartyDaily,
+			Daily:                newLimitWindowStatus(limits.Daily, limitUsage(userID, op, "", day)),
+			Monthly:              newLimitWindowStatus(limits.Monthly, limitUsage(userID, op, "", month)),
+		})
+	}
+	return tier, statuses
+}
+
+// GetLimitsHandler показывает клиенту лимиты его уровня и неизрасходованные остатки
+func GetLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	tier, limits := GetLimitsStatus(userID, time.Now())
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"tier":   tier,
+		"limits": limits,
+	})
+}
+
+// SetUserTierHandler меняет уровень обслуживания клиента (для операторов)
+func SetUserTierHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SetUserTierRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if !isKnownTier(req.Tier) {
+		respondValidationError(w, ValidationErrors{{Field: "tier", Message: "unknown tier"}})
+		return
+	}
+
+	user, err := SetUserTier(mux.Vars(r)["userId"], req.Tier)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
@@ -54,6 +54,48 @@ func JWTMiddleware(next http.Handler) http.Handler {
     })
 }
 
+// RequireVerifiedEmail запрещает операции с деньгами пользователям с неподтверждённым email
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := r.Context().Value(userContextKey).(string)
+        if !ok {
+            respondError(w, http.StatusUnauthorized, "User not found in context")
+            return
+        }
+
+        user, ok := GetUser(userID)
+        if !ok {
+            respondError(w, http.StatusUnauthorized, "User not found")
+            return
+        }
+        if !user.EmailVerified {
+            respondError(w, http.StatusForbidden, "Email address is not verified")
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// RequireOperator пропускает только пользователей с ролью оператора
+func RequireOperator(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := r.Context().Value(userContextKey).(string)
+        if !ok {
+            respondError(w, http.StatusUnauthorized, "User not found in context")
+            return
+        }
+
+        user, ok := GetUser(userID)
+        if !ok || user.Role != RoleOperator {
+            respondError(w, http.StatusForbidden, "Operator access required")
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
 func main() {
     log.SetOutput(os.Stdout)
     log.SetFormatter(&log.TextFormatter{
@@ -63,19 +105,91 @@ func main() {
 
     log.Println("Запуск Simple Bank API...")
 
+    if err := InitAuth(); err != nil {
+        log.Fatal(err)
+    }
+
     initDB()         
     defer db.Close() 
 
+    if err := InitOperator(); err != nil {
+        log.Fatalf("Не удалось создать учётную запись оператора: %v", err)
+    }
+
+    if err := InitCardHMACKeys(); err != nil {
+        log.Fatalf("Не удалось загрузить ключи целостности карт: %v", err)
+    }
+    log.Infof("Активная версия ключа HMAC карт: %s", cardHMACKeys.active)
+
+    if err := InitLimits(); err != nil {
+        log.Fatalf("Не удалось загрузить лимиты операций: %v", err)
+    }
+
+    // Ключи шифрования номеров карт загружаются один раз; SIGHUP перечитывает их без перезапуска
+    if err := InitCardEnvelope(); err != nil {
+        log.Fatalf("Не удалось загрузить мастер-ключ шифрования карт: %v", err)
+    }
+    if err := InitKeyManager(); err != nil {
+        log.Warnf("Менеджер ключей PGP не инициализирован: %v", err)
+    } else {
+        log.Infof("Активный PGP-ключ: %s", keyManager.ActiveKeyID())
+        keyManager.WatchReloadSignal()
+    }
+    if cardEnvelope != nil {
+        log.Infof("Конвертное шифрование карт, активный мастер-ключ: %s", cardEnvelope.ActiveKeyID())
+    } else if keyManager == nil {
+        log.Warn("Ключи шифрования карт не настроены, выпуск карт недоступен")
+    }
+    StartCardReencryption()
+    if err := InitClearing(); err != nil {
+        log.Fatalf("Неверные реквизиты банка для клиринга: %v", err)
+    }
+    log.Infof("Клиринг: БИК %s, используется локальный симулятор платёжной системы", bankRequisites.BIK)
+    if err := InitSBP(); err != nil {
+        log.Fatalf("Неверный идентификатор участника СБП: %v", err)
+    }
+    log.Infof("СБП: участник %s, используется локальный операционный центр", sbpMemberID)
+
     // Запуск шедулера для автоматической обработки платежей
+    // Регулярные переводы и клиринговый рейс выполняются каждый час, остальные задачи — раз в 12 часов
     go func() {
-    ticker := time.NewTicker(12 * time.Hour)
+    ticker := time.NewTicker(time.Hour)
     defer ticker.Stop()
+    lastBatchRun := time.Now()
     for {
-        <-ticker.C
+        now := <-ticker.C
+        if n := ExecuteDueStandingOrders(now); n > 0 {
+            log.Infof("Исполнено регулярных переводов: %d", n)
+        }
+        if sent, received := RunClearingCycle(now); sent+received > 0 {
+            log.Infof("Клиринг: отправлено поручений %d, получено поступлений %d", sent, received)
+        }
+        if now.Sub(lastBatchRun) < 12*time.Hour {
+            continue
+        }
+        lastBatchRun = now
         ProcessPayments(db)
+        if n := ExpireAuthorizations(now); n > 0 {
+            log.Infof("Снято просроченных авторизаций: %d", n)
+        }
+        PruneLimitUsage(now)
+        // Переподпись карт после ротации ключа HMAC и поиск подменённых записей
+        logCardIntegrityReport(RotateCardHMACs(now))
     }
 }()
 
+    // Закрытие операционного дня: в полночь фиксируются остатки на конец дня и начисляются проценты по овердрафту
+    go func() {
+        for {
+            now := time.Now()
+            dayEnd := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+            time.Sleep(time.Until(dayEnd))
+            if n := ChargeOverdraftInterest(dayEnd); n > 0 {
+                log.Infof("Начислены проценты по овердрафту на %d счетах", n)
+            }
+        }
+    }()
+
     // Получаем курсы валют с ЦБ РФ
     date := time.Now().Format("2025-06-14") 
     keyRate, err := GetCBRKeyRate(date)
@@ -90,6 +204,9 @@ func main() {
     // Открытые маршруты
     r.HandleFunc("/register", RegisterUserHandler).Methods("POST")
     r.HandleFunc("/login", LoginUserHandler).Methods("POST")
+    r.HandleFunc("/password/forgot", ForgotPasswordHandler).Methods("POST")
+    r.HandleFunc("/password/reset", ResetPasswordHandler).Methods("POST")
+    r.HandleFunc("/verify-email", VerifyEmailHandler).Methods("GET")
 
     // Защищённые маршруты
     secured := r.PathPrefix("/api").Subrouter()
@@ -97,16 +214,141 @@ func main() {
 
     secured.HandleFunc("/accounts", CreateAccountHandler).Methods("POST")
     secured.HandleFunc("/users/{userId}/accounts", GetUserAccountsHandler).Methods("GET")
+    secured.Handle("/accounts/{accountId}/close", RequireVerifiedEmail(http.HandlerFunc(CloseAccountHandler))).Methods("POST")
+    secured.HandleFunc("/accounts/{accountId}/members", InviteAccountMemberHandler).Methods("POST")
+    secured.HandleFunc("/accounts/{accountId}/members", GetAccountMembersHandler).Methods("GET")
+    secured.HandleFunc("/accounts/{accountId}/members/{membershipId}", RevokeAccountMemberHandler).Methods("DELETE")
+    secured.HandleFunc("/accounts/{accountId}/standing-orders", GetAccountStandingOrdersHandler).Methods("GET")
+    secured.HandleFunc("/account-invitations", GetAccountInvitationsHandler).Methods("GET")
+    secured.Handle("/external-payments", RequireVerifiedEmail(http.HandlerFunc(CreateExternalPaymentHandler))).Methods("POST")
+    secured.HandleFunc("/external-payments/{paymentId}", GetExternalPaymentHandler).Methods("GET")
+    secured.HandleFunc("/external-payments/{paymentId}/cancel", CancelExternalPaymentHandler).Methods("POST")
+    secured.HandleFunc("/accounts/{accountId}/external-payments", GetAccountExternalPaymentsHandler).Methods("GET")
+    secured.HandleFunc("/sbp/settings", GetSBPSettingsHandler).Methods("GET")
+    secured.HandleFunc("/sbp/settings", UpdateSBPSettingsHandler).Methods("PUT")
+    secured.HandleFunc("/sbp/settings", DeleteSBPSettingsHandler).Methods("DELETE")
+    secured.HandleFunc("/sbp/lookup", SBPLookupHandler).Methods("POST")
+    secured.Handle("/sbp/transfers", RequireVerifiedEmail(http.HandlerFunc(SBPTransferHandler))).Methods("POST")
+    secured.HandleFunc("/sbp/qr/parse", ParseSBPQRHandler).Methods("POST")
+    secured.Handle("/sbp/qr/pay", RequireVerifiedEmail(http.HandlerFunc(PaySBPQRHandler))).Methods("POST")
+    secured.HandleFunc("/billers", GetBillersHandler).Methods("GET")
+    secured.HandleFunc("/billers/{billerId}", GetBillerHandler).Methods("GET")
+    secured.Handle("/bill-payments", RequireVerifiedEmail(http.HandlerFunc(CreateBillPaymentHandler))).Methods("POST")
+    secured.HandleFunc("/bill-payments", GetBillPaymentsHandler).Methods("GET")
+    secured.HandleFunc("/bill-payments/{paymentId}", GetBillPaymentHandler).Methods("GET")
+    secured.HandleFunc("/bill-payments/{paymentId}/receipt", GetBillReceiptHandler).Methods("GET")
+    secured.HandleFunc("/bill-templates", CreateBillTemplateHandler).Methods("POST")
+    secured.HandleFunc("/bill-templates", GetBillTemplatesHandler).Methods("GET")
+    secured.HandleFunc("/bill-templates/{templateId}", GetBillTemplateHandler).Methods("GET")
+    secured.HandleFunc("/bill-templates/{templateId}", UpdateBillTemplateHandler).Methods("PUT")
+    secured.HandleFunc("/bill-templates/{templateId}", DeleteBillTemplateHandler).Methods("DELETE")
+    secured.Handle("/bill-templates/{templateId}/pay", RequireVerifiedEmail(http.HandlerFunc(PayBillTemplateHandler))).Methods("POST")
+    secured.HandleFunc("/organizations", CreateOrganizationHandler).Methods("POST")
+    secured.HandleFunc("/organizations", GetOrganizationsHandler).Methods("GET")
+    secured.HandleFunc("/organizations/{orgId}", GetOrganizationHandler).Methods("GET")
+    secured.HandleFunc("/organizations/{orgId}/accounts", CreateOrganizationAccountHandler).Methods("POST")
+    secured.HandleFunc("/organizations/{orgId}/accounts", GetOrganizationAccountsHandler).Methods("GET")
+    secured.HandleFunc("/organizations/{orgId}/approvals", GetPaymentApprovalsHandler).Methods("GET")
+    secured.Handle("/organizations/{orgId}/approvals/{approvalId}/approve", RequireVerifiedEmail(http.HandlerFunc(ApprovePaymentHandler))).Methods("POST")
+    secured.HandleFunc("/organizations/{orgId}/approvals/{approvalId}/reject", RejectPaymentHandler).Methods("POST")
+    secured.HandleFunc("/account-invitations/{membershipId}/accept", AcceptAccountInvitationHandler).Methods("POST")
+    secured.HandleFunc("/account-invitations/{membershipId}/decline", DeclineAccountInvitationHandler).Methods("POST")
     secured.HandleFunc("/cards", GenerateCardHandler).Methods("POST")
     secured.HandleFunc("/accounts/{accountId}/cards", GetAccountCardsHandler).Methods("GET")
-    secured.HandleFunc("/payments/card", PayWithCardHandler).Methods("POST")
-    secured.HandleFunc("/transfers", TransferHandler).Methods("POST")
-    secured.HandleFunc("/deposits", DepositHandler).Methods("POST")
-    secured.HandleFunc("/loans", ApplyLoanHandler).Methods("POST")
+    secured.HandleFunc("/cards/{cardId}/controls", GetCardControlsHandler).Methods("GET")
+    secured.HandleFunc("/cards/{cardId}/controls", UpdateCardControlsHandler).Methods("PUT")
+    secured.HandleFunc("/cards/{cardId}/close", CloseCardHandler).Methods("POST")
+    secured.HandleFunc("/cards/{cardId}/pin", SetPINHandler).Methods("POST")
+    secured.HandleFunc("/cards/{cardId}/pin", ChangePINHandler).Methods("PUT")
+    secured.HandleFunc("/cards/{cardId}/tokens", GetCardTokensHandler).Methods("GET")
+    secured.HandleFunc("/cards/{cardId}/tokens/{tokenId}/suspend", SuspendCardTokenHandler).Methods("POST")
+    secured.HandleFunc("/cards/{cardId}/tokens/{tokenId}/resume", ResumeCardTokenHandler).Methods("POST")
+    secured.HandleFunc("/cards/{cardId}/tokens/{tokenId}", DeleteCardTokenHandler).Methods("DELETE")
+    secured.HandleFunc("/verify-email/resend", ResendVerificationHandler).Methods("POST")
+    secured.HandleFunc("/phone/verify", RequestPhoneCodeHandler).Methods("POST")
+    secured.HandleFunc("/phone/confirm", ConfirmPhoneHandler).Methods("POST")
+
+    // Движение денег доступно только пользователям с подтверждённым email
+    secured.Handle("/payments/card", RequireVerifiedEmail(http.HandlerFunc(PayWithCardHandler))).Methods("POST")
+    secured.Handle("/transfers", RequireVerifiedEmail(http.HandlerFunc(TransferHandler))).Methods("POST")
+    secured.Handle("/transfers/resolve", RequireVerifiedEmail(http.HandlerFunc(ResolveRecipientHandler))).Methods("POST")
+    secured.HandleFunc("/limits", GetLimitsHandler).Methods("GET")
+    secured.Handle("/transfers/batch", RequireVerifiedEmail(http.HandlerFunc(BatchTransferHandler))).Methods("POST")
+    secured.Handle("/standing-orders", RequireVerifiedEmail(http.HandlerFunc(CreateStandingOrderHandler))).Methods("POST")
+    secured.HandleFunc("/standing-orders", GetStandingOrdersHandler).Methods("GET")
+    secured.HandleFunc("/standing-orders/{orderId}", GetStandingOrderHandler).Methods("GET")
+    secured.Handle("/standing-orders/{orderId}", RequireVerifiedEmail(http.HandlerFunc(UpdateStandingOrderHandler))).Methods("PUT")
+    secured.HandleFunc("/standing-orders/{orderId}", CancelStandingOrderHandler).Methods("DELETE")
+    secured.HandleFunc("/standing-orders/{orderId}/pause", PauseStandingOrderHandler).Methods("POST")
+    secured.Handle("/standing-orders/{orderId}/resume", RequireVerifiedEmail(http.HandlerFunc(ResumeStandingOrderHandler))).Methods("POST")
+    secured.HandleFunc("/standing-orders/{orderId}/executions", GetStandingOrderExecutionsHandler).Methods("GET")
+    secured.Handle("/deposits", RequireVerifiedEmail(http.HandlerFunc(DepositHandler))).Methods("POST")
+    secured.Handle("/loans", RequireVerifiedEmail(http.HandlerFunc(ApplyLoanHandler))).Methods("POST")
     secured.HandleFunc("/loans/{loanId}/schedule", GetLoanScheduleHandler).Methods("GET")
     secured.HandleFunc("/analytics/transactions/{accountId}", GetTransactionsHandler).Methods("GET")
     secured.HandleFunc("/analytics/summary/{userId}", GetFinancialSummaryHandler).Methods("GET")
     secured.HandleFunc("/analytics/forecast", GetFinancialForecastHandler).Methods("GET")
+    secured.Handle("/merchants", RequireVerifiedEmail(http.HandlerFunc(CreateMerchantHandler))).Methods("POST")
+    secured.HandleFunc("/merchants", GetUserMerchantsHandler).Methods("GET")
+    secured.Handle("/disputes", RequireVerifiedEmail(http.HandlerFunc(OpenDisputeHandler))).Methods("POST")
+    secured.HandleFunc("/disputes", GetUserDisputesHandler).Methods("GET")
+
+    // Операторские маршруты
+    ops := secured.PathPrefix("/ops").Subrouter()
+    ops.Use(RequireOperator)
+
+    ops.HandleFunc("/disputes", ListDisputesHandler).Methods("GET")
+    ops.HandleFunc("/disputes/{disputeId}/provisional-credit", ProvisionalCreditHandler).Methods("POST")
+    ops.HandleFunc("/disputes/{disputeId}/resolve", ResolveDisputeHandler).Methods("POST")
+    ops.HandleFunc("/transactions/{transactionId}/reverse", ReverseTransactionHandler).Methods("POST")
+    ops.HandleFunc("/users/{userId}/tier", SetUserTierHandler).Methods("PUT")
+    ops.HandleFunc("/merchants/{merchantId}/mcc", SetMerchantMCCHandler).Methods("PUT")
+    ops.HandleFunc("/users/{userId}/role", SetUserRoleHandler).Methods("PUT")
+    ops.HandleFunc("/accounts/{accountId}/overdraft", SetOverdraftHandler).Methods("PUT")
+    ops.HandleFunc("/accounts/{accountId}/status", SetAccountStatusHandler).Methods("PUT")
+    ops.HandleFunc("/organizations/{orgId}/signers", AddOrganizationSignerHandler).Methods("POST")
+    ops.HandleFunc("/clearing/batches", FormClearingBatchHandler).Methods("POST")
+    ops.HandleFunc("/clearing/batches", GetClearingBatchesHandler).Methods("GET")
+    ops.HandleFunc("/clearing/batches/{batchId}/file", GetClearingBatchFileHandler).Methods("GET")
+    ops.HandleFunc("/clearing/batches/{batchId}/submit", SubmitClearingBatchHandler).Methods("POST")
+    ops.HandleFunc("/clearing/incoming", GetIncomingPaymentsHandler).Methods("GET")
+    ops.HandleFunc("/clearing/incoming/fetch", FetchIncomingPaymentsHandler).Methods("POST")
+    ops.HandleFunc("/clearing/simulator/incoming", SimulateIncomingPaymentHandler).Methods("POST")
+    ops.HandleFunc("/sbp/simulator/aliases", SimulateSBPAliasHandler).Methods("POST")
+    ops.HandleFunc("/sbp/simulator/incoming", SimulateSBPIncomingHandler).Methods("POST")
+    ops.HandleFunc("/billers", CreateBillerHandler).Methods("POST")
+    ops.HandleFunc("/billers", GetAllBillersHandler).Methods("GET")
+    ops.HandleFunc("/billers/{billerId}", UpdateBillerHandler).Methods("PUT")
+    ops.HandleFunc("/organizations/{orgId}/signers/{userId}", RemoveOrganizationSignerHandler).Methods("DELETE")
+    ops.HandleFunc("/keys", GetKeyStatusHandler).Methods("GET")
+    ops.HandleFunc("/keys/reload", ReloadKeysHandler).Methods("POST")
+    ops.HandleFunc("/integrity/cards", CardIntegrityScanHandler).Methods("GET")
+    ops.HandleFunc("/integrity/cards/rotate", RotateCardHMACsHandler).Methods("POST")
+
+    // Маршруты мерчантов: аутентификация по API-ключу и HMAC-подписи запроса
+    merchantRouter := r.PathPrefix("/merchant").Subrouter()
+    merchantRouter.Use(MerchantMiddleware)
+
+    merchantRouter.HandleFunc("/payments", MerchantPaymentHandler).Methods("POST")
+    merchantRouter.HandleFunc("/tokens", CreateCardTokenHandler).Methods("POST")
+    merchantRouter.HandleFunc("/tokens/{tokenId}", DeleteMerchantCardTokenHandler).Methods("DELETE")
+    merchantRouter.HandleFunc("/authorizations", AuthorizeCardHandler).Methods("POST")
+    merchantRouter.HandleFunc("/authorizations/{authId}", GetAuthorizationHandler).Methods("GET")
+    merchantRouter.HandleFunc("/authorizations/{authId}/capture", CaptureAuthorizationHandler).Methods("POST")
+    merchantRouter.HandleFunc("/authorizations/{authId}/partial-capture", PartialCaptureHandler).Methods("POST")
+    merchantRouter.HandleFunc("/authorizations/{authId}/void", VoidAuthorizationHandler).Methods("POST")
+    merchantRouter.HandleFunc("/refunds", MerchantRefundHandler).Methods("POST")
+    merchantRouter.HandleFunc("/pin/verify", VerifyPINHandler).Methods("POST")
+    merchantRouter.HandleFunc("/atm/withdrawals", ATMWithdrawalHandler).Methods("POST")
+    merchantRouter.HandleFunc("/sbp/qr", CreateSBPQRHandler).Methods("POST")
+    merchantRouter.HandleFunc("/sbp/qr/{qrId}", GetSBPQRHandler).Methods("GET")
+
+    // ISO 8583 шлюз для POS-терминалов и эквайеров
+    if addr := os.Getenv("ISO8583_LISTEN_ADDR"); addr != "" {
+        if err := StartISO8583Gateway(addr); err != nil {
+            log.Fatalf("Не удалось запустить ISO 8583 шлюз: %v", err)
+        }
+    }
 
     port := "8080"
     log.Infof("Сервер запускается на порту %s", port)
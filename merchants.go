@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	merchantContextKey contextKey = "merchant"
+
+	// Допустимое расхождение часов мерчанта и банка при проверке подписи
+	merchantSignatureWindow = 5 * time.Minute
+)
+
+// merchantStringToSign собирает каноническую строку для подписи запроса мерчанта:
+// timestamp, метод, путь и SHA-256 тела запроса, разделённые переводом строки
+func merchantStringToSign(timestamp, method, path string, body []byte) string {
+	digest := sha256.Sum256(body)
+	return timestamp + "\n" + method + "\n" + path + "\n" + hex.EncodeToString(digest[:])
+}
+
+// MerchantMiddleware проверяет API-ключ и HMAC-подпись запроса мерчанта
+func MerchantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-Api-Key")
+		timestamp := r.Header.Get("X-Timestamp")
+		signature := r.Header.Get("X-Signature")
+		if apiKey == "" || timestamp == "" || signature == "" {
+			respondError(w, http.StatusUnauthorized, "Missing merchant authentication headers")
+			return
+		}
+
+		merchant, ok := GetMerchantByAPIKey(apiKey)
+		if !ok {
+			respondError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusUnauthorized, "Invalid timestamp")
+			return
+		}
+		signedAt := time.Unix(ts, 0)
+		if d := time.Since(signedAt); d > merchantSignatureWindow || d < -merchantSignatureWindow {
+			respondError(w, http.StatusUnauthorized, "Request timestamp outside of allowed window")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		expected := GenerateHMAC(merchantStringToSign(timestamp, r.Method, r.URL.Path, body), []byte(merchant.APISecret))
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			respondError(w, http.StatusUnauthorized, "Invalid signature")
+			return
+		}
+
+		// Повторная отправка того же подписанного запроса в пределах окна отклоняется
+		if !ConsumeActionToken("merchant-signature:"+signature, signedAt.Add(merchantSignatureWindow)) {
+			respondError(w, http.StatusUnauthorized, "Request has already been processed")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), merchantContextKey, merchant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func CreateMerchantHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateMerchantRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	if containsString(cashMCCs, req.MCC) {
+		respondError(w, http.StatusForbidden, fmt.Sprintf("MCC %s can only be assigned by an operator", req.MCC))
+		return
+	}
+
+	merchant := Merchant{
+		ID:                  GenerateID(),
+		OwnerUserID:         userID,
+		Name:                req.Name,
+		MCC:                 req.MCC,
+		SettlementAccountID: req.SettlementAccountID,
+		AcceptorID:          GenerateAcceptorID(),
+		APIKey:              "mk_" + GenerateSecureToken(16),
+		APISecret:           GenerateSecureToken(32),
+		TerminalMACKey:      GenerateSecureToken(32),
+		CreatedAt:           time.Now(),
+	}
+
+	if err := AddMerchant(merchant); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to create merchant: %v", err))
+		return
+	}
+
+	log.Printf("Merchant %s created for user %s", merchant.ID, userID)
+
+	// Секреты возвращаются только один раз, при создании мерчанта
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"merchant":         merchant,
+		"api_secret":       merchant.APISecret,
+		"terminal_mac_key": merchant.TerminalMACKey,
+	})
+}
+
+// SetMerchantMCCHandler меняет MCC мерчанта (для операторов), в том числе назначает коды выдачи наличных
+func SetMerchantMCCHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SetMerchantMCCRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, err := SetMerchantMCC(mux.Vars(r)["merchantId"], req.MCC)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Merchant not found")
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+	log.Printf("MCC of merchant %s set to %s by operator %s", merchant.ID, merchant.MCC, operatorID)
+	respondJSON(w, http.StatusOK, merchant)
+}
+
+func GetUserMerchantsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, GetUserMerchants(userID))
+}
+
+// MerchantPaymentHandler проводит оплату картой от имени аутентифицированного мерчанта
+func MerchantPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req MerchantPaymentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	var card Card
+	var token CardToken
+	var err error
+	if req.Token != "" {
+		card, token, err = resolveCardToken(req.Token, merchant.ID)
+	} else {
+		card, err = verifyCardForPayment(req.CardNumber, req.CVV)
+	}
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	description := fmt.Sprintf("Payment to %s", merchant.Name)
+	if req.Description != "" {
+		description = fmt.Sprintf("%s: %s", description, req.Description)
+	}
+
+	use := newCardUse(req.Amount, merchant.MCC, req.Channel, req.Country, merchant.ID)
+	use.TokenID = token.ID
+	tx, err := chargeCard(card, use, description, &merchant)
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	log.Printf("Merchant %s charged %s from account %s", merchant.ID, req.Amount.String(), tx.FromAccountID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Payment successful",
+		"transaction_id": tx.ID,
+		"amount":         tx.Amount,
+	})
+}
@@ -7,40 +7,563 @@ import (
 )
 
 type User struct {
-	ID           string    `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` 
-	CreatedAt    time.Time `json:"created_at"`
+	ID                string    `json:"id"`
+	Username          string    `json:"username"`
+	Email             string    `json:"email"`
+	PasswordHash      string    `json:"-"`
+	PasswordChangedAt time.Time `json:"-"` // токены сброса пароля, выданные раньше, недействительны
+	EmailVerified     bool      `json:"email_verified"`
+	Phone             string    `json:"phone,omitempty"` // в формате E.164, например +79161234567
+	PhoneVerified     bool      `json:"phone_verified"`  // подтверждён кодом из SMS; только такой номер ищется при переводах
+	FirstName         string    `json:"first_name,omitempty"`
+	LastName          string    `json:"last_name,omitempty"`
+	Role              string    `json:"role"`
+	Tier              string    `json:"tier"` // уровень обслуживания, от которого зависят лимиты
+	CreatedAt         time.Time `json:"created_at"`
 }
 
+// PhoneVerification — отправленный пользователю код подтверждения телефона
+type PhoneVerification struct {
+	UserID    string
+	Phone     string
+	CodeHash  string
+	Attempts  int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+const (
+	RoleCustomer = "customer"
+	RoleOperator = "operator"
+)
+
 type Account struct {
-	ID        string          `json:"id"`
-	UserID    string          `json:"user_id"`
-	Number    string          `json:"number"` 
-	Balance   decimal.Decimal `json:"balance"`
-	CreatedAt time.Time       `json:"created_at"`
+	ID               string          `json:"id"`
+	UserID           string          `json:"user_id"`
+	Number           string          `json:"number"`
+	Balance          decimal.Decimal `json:"balance"`           // текущий (бухгалтерский) остаток
+	AvailableBalance decimal.Decimal `json:"available_balance"` // остаток за вычетом авторизационных холдов
+	RecoveryHold     decimal.Decimal `json:"recovery_hold"`     // непогашенная недостача после сторно; пока она есть, списания запрещены
+	OverdraftLimit   decimal.Decimal `json:"overdraft_limit"`   // на сколько остаток может уйти в минус
+	OverdraftRate    decimal.Decimal `json:"overdraft_rate"`    // годовая ставка на отрицательный остаток, %
+	Status           string          `json:"status"`
+	ArrestedAmount   decimal.Decimal `json:"arrested_amount"` // сумма, заблокированная по постановлению (статус arrested)
+	StatusReason     string          `json:"status_reason,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	ClosedAt         *time.Time      `json:"closed_at,omitempty"`
+	OrganizationID   string          `json:"organization_id,omitempty"` // расчётный счёт организации; UserID — подписант, открывший счёт
+
+	OverdraftInterestDate time.Time       `json:"-"` // день, за который последний раз начислены проценты по овердрафту
+	EndOfDayDate          time.Time       `json:"-"` // последний закрытый операционный день
+	EndOfDayBalance       decimal.Decimal `json:"-"` // остаток на конец дня EndOfDayDate
+	EndOfDayRecoveryHold  decimal.Decimal `json:"-"` // недостача после сторно на конец дня EndOfDayDate
+}
+
+// Статусы счёта; счёт без статуса считается активным
+const (
+	AccountStatusActive   = "active"
+	AccountStatusFrozen   = "frozen"   // списания запрещены, зачисления разрешены
+	AccountStatusArrested = "arrested" // по постановлению заблокирована часть средств (ArrestedAmount)
+	AccountStatusClosed   = "closed"   // операции по счёту запрещены
+)
+
+// Права участника счёта: совладельца совместного счёта или доверенного лица
+const (
+	AccountPermissionView = "view" // просмотр счёта, карт и операций
+	AccountPermissionPay  = "pay"  // платежи и переводы со счёта в пределах PaymentLimit на операцию
+	AccountPermissionFull = "full" // все операции владельца, кроме управления участниками и закрытия счёта
+)
+
+const (
+	MembershipStatusInvited  = "invited"
+	MembershipStatusActive   = "active"
+	MembershipStatusDeclined = "declined"
+	MembershipStatusRevoked  = "revoked"
+)
+
+// AccountMembership — доступ пользователя к счёту, принадлежащему другому клиенту.
+// Владелец счёта (Account.UserID) участником не считается и всегда имеет полный доступ.
+type AccountMembership struct {
+	ID           string          `json:"id"`
+	AccountID    string          `json:"account_id"`
+	UserID       string          `json:"user_id"`
+	Permission   string          `json:"permission"`
+	PaymentLimit decimal.Decimal `json:"payment_limit"` // для pay: максимальная сумма одной операции, 0 — без ограничения
+	Status       string          `json:"status"`
+	InvitedBy    string          `json:"invited_by"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// Organization — клиент — юридическое лицо. Счетами организации распоряжаются её подписанты.
+type Organization struct {
+	ID                    string               `json:"id"`
+	Name                  string               `json:"name"`
+	INN                   string               `json:"inn"`
+	OGRN                  string               `json:"ogrn"`
+	KPP                   string               `json:"kpp"`
+	Signers               []OrganizationSigner `json:"signers"`
+	DualApprovalThreshold decimal.Decimal      `json:"dual_approval_threshold"` // платежи больше этой суммы требуют двух подписей
+	CreatedBy             string               `json:"created_by"`
+	CreatedAt             time.Time            `json:"created_at"`
+}
+
+type OrganizationSigner struct {
+	UserID  string    `json:"user_id"`
+	Title   string    `json:"title,omitempty"` // должность, например «Генеральный директор»
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Число подписей, необходимое для платежа выше порога
+const requiredApprovals = 2
+
+const (
+	PaymentApprovalPending  = "pending"
+	PaymentApprovalExecuted = "executed"
+	PaymentApprovalRejected = "rejected"
+)
+
+// PaymentApproval — исходящий платёж организации, ожидающий второй подписи
+type PaymentApproval struct {
+	ID                string          `json:"id"`
+	OrganizationID    string          `json:"organization_id"`
+	FromAccountID     string          `json:"from_account_id"`
+	ToAccountID       string          `json:"to_account_id,omitempty"`
+	ExternalPaymentID string          `json:"external_payment_id,omitempty"` // платёж в другой банк
+	Amount            decimal.Decimal `json:"amount"`
+	Description       string          `json:"description,omitempty"`
+	InitiatedBy       string          `json:"initiated_by"`
+	Approvals         []string        `json:"approvals"` // подписанты, подписавшие платёж
+	Status            string          `json:"status"`
+	TransactionID     string          `json:"transaction_id,omitempty"`
+	RejectedBy        string          `json:"rejected_by,omitempty"`
+	Reason            string          `json:"reason,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// Статусы платёжного поручения в другой банк
+const (
+	ExternalPaymentAwaitingApproval = "awaiting_approval" // ждёт второй подписи, средства не списаны
+	ExternalPaymentQueued           = "queued"            // средства списаны, ждёт включения в клиринговый пакет
+	ExternalPaymentSent             = "sent"              // включено в пакет, ждёт ответа клиринга
+	ExternalPaymentExecuted         = "executed"
+	ExternalPaymentRejected         = "rejected"  // отвергнуто клирингом, средства возвращены
+	ExternalPaymentCancelled        = "cancelled" // отменено до отправки
+)
+
+// ExternalPayment — платёжное поручение на счёт в другом банке
+type ExternalPayment struct {
+	ID            string          `json:"id"`
+	FromAccountID string          `json:"from_account_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	PayeeBIK      string          `json:"payee_bik"`
+	PayeeAccount  string          `json:"payee_account"`
+	PayeeName     string          `json:"payee_name"`
+	PayeeINN      string          `json:"payee_inn,omitempty"`
+	PayeeKPP      string          `json:"payee_kpp,omitempty"`
+	Purpose       string          `json:"purpose"`
+	Status        string          `json:"status"`
+	EDNo          int             `json:"ed_no,omitempty"` // номер электронного документа в пакете
+	BatchID       string          `json:"batch_id,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	RejectReason  string          `json:"reject_reason,omitempty"`
+	CreatedBy     string          `json:"created_by"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+const (
+	ClearingBatchFormed    = "formed"    // сформирован, ещё не принят клирингом
+	ClearingBatchProcessed = "processed" // клиринг вернул статусы по всем платежам
+)
+
+// ClearingBatch — пакет платёжных поручений, отправляемый в клиринг одним файлом
+type ClearingBatch struct {
+	ID          string          `json:"id"`
+	EDNo        int             `json:"ed_no"`
+	PaymentIDs  []string        `json:"payment_ids"`
+	Count       int             `json:"count"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	Status      string          `json:"status"`
+	Executed    int             `json:"executed"`
+	Rejected    int             `json:"rejected"`
+	LastError   string          `json:"last_error,omitempty"` // ошибка последней отправки
+	CreatedAt   time.Time       `json:"created_at"`
+	SubmittedAt *time.Time      `json:"submitted_at,omitempty"`
+}
+
+const (
+	IncomingPaymentCredited = "credited"
+	IncomingPaymentReturned = "returned" // счёт получателя не найден или закрыт
+)
+
+// IncomingPayment — поступление из другого банка
+type IncomingPayment struct {
+	ID            string          `json:"id"`
+	EDNo          int             `json:"ed_no"`
+	PayerBIK      string          `json:"payer_bik"`
+	PayerAccount  string          `json:"payer_account"`
+	PayerName     string          `json:"payer_name"`
+	PayeeAccount  string          `json:"payee_account"`
+	Amount        decimal.Decimal `json:"amount"`
+	Purpose       string          `json:"purpose"`
+	Status        string          `json:"status"`
+	AccountID     string          `json:"account_id,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	ReceivedAt    time.Time       `json:"received_at"`
+}
+
+// SBPAlias — привязка номера телефона клиента к счёту для переводов через СБП
+type SBPAlias struct {
+	Phone       string    `json:"phone"`
+	UserID      string    `json:"user_id"`
+	AccountID   string    `json:"account_id"`
+	DefaultBank bool      `json:"default_bank"` // банк по умолчанию для входящих переводов по этому номеру
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Типы QR-кодов СБП
+const (
+	SBPQRStatic  = "static"  // многоразовый, сумма может задаваться плательщиком
+	SBPQRDynamic = "dynamic" // одноразовый, с суммой и сроком действия
+)
+
+const (
+	SBPQRStatusActive  = "active"
+	SBPQRStatusPaid    = "paid"
+	SBPQRStatusExpired = "expired"
+)
+
+// SBPQRCode — QR-код мерчанта для оплаты через СБП; Payload одновременно служит платёжной ссылкой
+type SBPQRCode struct {
+	ID            string          `json:"id"` // QR ID: 32 символа, латинские буквы и цифры
+	MerchantID    string          `json:"merchant_id"`
+	Type          string          `json:"type"`
+	Amount        decimal.Decimal `json:"amount"` // для статического кода 0 — сумму вводит плательщик
+	Purpose       string          `json:"purpose,omitempty"`
+	Payload       string          `json:"payload"`
+	Status        string          `json:"status"`
+	Payments      int             `json:"payments"`
+	TransactionID string          `json:"transaction_id,omitempty"` // для оплаченного динамического кода
+	ExpiresAt     *time.Time      `json:"expires_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PaidAt        *time.Time      `json:"paid_at,omitempty"`
+}
+
+// Категории поставщиков услуг
+const (
+	BillerCategoryUtilities = "utilities"
+	BillerCategoryMobile    = "mobile"
+	BillerCategoryInternet  = "internet"
+)
+
+// Типы полей реквизитов платежа поставщику
+const (
+	BillerFieldText    = "text"
+	BillerFieldNumber  = "number"  // лицевой счёт, номер договора: только цифры
+	BillerFieldPhone   = "phone"   // номер телефона, приводится к виду +7XXXXXXXXXX
+	BillerFieldPeriod  = "period"  // расчётный период MM.YYYY
+	BillerFieldReading = "reading" // показание счётчика
+)
+
+// BillerField — поле, которое клиент заполняет при оплате услуг поставщика
+type BillerField struct {
+	Key       string `json:"key"`
+	Label     string `json:"label"`
+	Type      string `json:"type"`
+	Required  bool   `json:"required"`
+	MinLength int    `json:"min_length,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"`
+	Pattern   string `json:"pattern,omitempty"` // регулярное выражение для значения
+}
+
+// Biller — поставщик услуг из каталога; платежи зачисляются на его расчётный счёт в банке
+type Biller struct {
+	ID                  string          `json:"id"`
+	Name                string          `json:"name"`
+	Category            string          `json:"category"`
+	INN                 string          `json:"inn"`
+	SettlementAccountID string          `json:"-"`
+	Fields              []BillerField   `json:"fields"`
+	MinAmount           decimal.Decimal `json:"min_amount"`
+	MaxAmount           decimal.Decimal `json:"max_amount"` // 0 — без ограничения
+	Active              bool            `json:"active"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+// BillPayment — оплата услуг поставщика
+type BillPayment struct {
+	ID            string            `json:"id"`
+	ReceiptNumber string            `json:"receipt_number"`
+	UserID        string            `json:"user_id"`
+	FromAccountID string            `json:"from_account_id"`
+	BillerID      string            `json:"biller_id"`
+	Fields        map[string]string `json:"fields"`
+	Amount        decimal.Decimal   `json:"amount"`
+	TransactionID string            `json:"transaction_id"`
+	TemplateID    string            `json:"template_id,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// BillReceipt — квитанция об оплате услуг
+type BillReceipt struct {
+	ReceiptNumber string          `json:"receipt_number"`
+	PaidAt        time.Time       `json:"paid_at"`
+	BankName      string          `json:"bank_name"`
+	BankBIK       string          `json:"bank_bik"`
+	PayerName     string          `json:"payer_name"`
+	PayerAccount  string          `json:"payer_account"` // замаскированный номер счёта
+	BillerName    string          `json:"biller_name"`
+	BillerINN     string          `json:"biller_inn"`
+	Details       []ReceiptDetail `json:"details"` // реквизиты в порядке полей поставщика
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID string          `json:"transaction_id"`
+}
+
+type ReceiptDetail struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// BillTemplate — сохранённый шаблон для повторной оплаты услуг. Меняющиеся реквизиты
+// (период, показания) можно не сохранять и указывать при каждой оплате.
+type BillTemplate struct {
+	ID            string            `json:"id"`
+	UserID        string            `json:"user_id"`
+	Name          string            `json:"name"`
+	BillerID      string            `json:"biller_id"`
+	FromAccountID string            `json:"from_account_id"`
+	Fields        map[string]string `json:"fields"`
+	Amount        decimal.Decimal   `json:"amount"` // 0 — сумма указывается при оплате
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
 }
 
 type Card struct {
-    ID          string    `json:"id"`
-    AccountID   string    `json:"account_id"`
-    Number      string    `json:"number"`      
-    ExpiryMonth int       `json:"expiry_month"`
-    ExpiryYear  int       `json:"expiry_year"`
-    CVV         string    `json:"cvv"`         
-    CreatedAt   time.Time `json:"created_at"`
-    HMAC        string    `json:"hmac"`        
+	ID          string       `json:"id"`
+	AccountID   string       `json:"account_id"`
+	Number      string       `json:"number"`
+	ExpiryMonth int          `json:"expiry_month"`
+	ExpiryYear  int          `json:"expiry_year"`
+	CVV         string       `json:"cvv"`
+	CreatedAt   time.Time    `json:"created_at"`
+	HMAC        string       `json:"hmac"`
+	HMACVersion string       `json:"hmac_version"` // версия ключа, которой подписан HMAC
+	Controls    CardControls `json:"controls"`
+	Type        string       `json:"type"`
+	Status      string       `json:"status"`
+
+	LockedMerchantID string `json:"locked_merchant_id,omitempty"` // карта принимает оплату только от этого мерчанта
+
+	PANFingerprint string `json:"-"` // HMAC открытого номера для поиска карты по PAN
+	KeyID          string `json:"-"` // версия PGP-ключа, которой зашифрован Number
+
+	PINHash     string `json:"-"` // HMAC PIN-кода на отдельном ключе PIN_HMAC_KEY
+	PINAttempts int    `json:"pin_attempts"`
+	PINBlocked  bool   `json:"pin_blocked"`
+}
+
+const (
+	CardTypePhysical  = "physical"
+	CardTypeVirtual   = "virtual"
+	CardTypeSingleUse = "single_use" // закрывается после первой успешной оплаты
+)
+
+const (
+	CardStatusActive = "active"
+	CardStatusClosed = "closed"
+)
+
+const (
+	CardChannelOnline      = "online"
+	CardChannelContactless = "contactless"
+	CardChannelChip        = "chip"
+)
+
+// CardControls — ограничения, которые клиент задаёт для своей карты. Нулевой лимит означает отсутствие лимита.
+type CardControls struct {
+	DailyLimit          decimal.Decimal `json:"daily_limit"`
+	MonthlyLimit        decimal.Decimal `json:"monthly_limit"`
+	PerTransactionLimit decimal.Decimal `json:"per_transaction_limit"`
+	AllowedMCCs         []string        `json:"allowed_mccs,omitempty"` // если не пуст, разрешены только эти MCC
+	BlockedMCCs         []string        `json:"blocked_mccs,omitempty"`
+	OnlineEnabled       bool            `json:"online_enabled"`
+	ContactlessEnabled  bool            `json:"contactless_enabled"`
+	ForeignEnabled      bool            `json:"foreign_enabled"`
+}
+
+const (
+	CardTokenStatusActive    = "active"
+	CardTokenStatusSuspended = "suspended"
+	CardTokenStatusDeleted   = "deleted"
+)
+
+// CardToken — токен сохранённых реквизитов карты, выпущенный для одного мерчанта.
+// Мерчант хранит только токен; PAN остаётся в карте в зашифрованном PGP виде.
+type CardToken struct {
+	ID         string     `json:"id"`
+	Token      string     `json:"-"`
+	CardID     string     `json:"card_id"`
+	MerchantID string     `json:"merchant_id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
 }
 
 type Transaction struct {
 	ID              string          `json:"id"`
-	FromAccountID   string          `json:"from_account_id,omitempty"` 
-	ToAccountID     string          `json:"to_account_id,omitempty"`   
+	FromAccountID   string          `json:"from_account_id,omitempty"`
+	ToAccountID     string          `json:"to_account_id,omitempty"`
 	Amount          decimal.Decimal `json:"amount"`
 	Timestamp       time.Time       `json:"timestamp"`
 	TransactionType string          `json:"transaction_type"`
 	Description     string          `json:"description,omitempty"`
+	CardID          string          `json:"card_id,omitempty"`
+	MerchantID      string          `json:"merchant_id,omitempty"`
+	AuthorizationID string          `json:"authorization_id,omitempty"`
+	CardTokenID     string          `json:"card_token_id,omitempty"` // если оплата проведена токеном
+
+	OriginalTransactionID string `json:"original_transaction_id,omitempty"` // для возвратов и чарджбэков
+
+	ExternalAccount string `json:"external_account,omitempty"` // счёт в другом банке в виде БИК/номер счёта
+
+	ReversalOf string `json:"reversal_of,omitempty"` // для сторно — ID исходной транзакции
+	OperatorID string `json:"operator_id,omitempty"` // оператор, проведший корректировку
+	Reason     string `json:"reason,omitempty"`
+}
+
+const (
+	DisputeStatusOpened            = "opened"
+	DisputeStatusProvisionalCredit = "provisional_credit"
+	DisputeStatusWon               = "won"  // спор решён в пользу клиента
+	DisputeStatusLost              = "lost" // спор решён в пользу мерчанта
+)
+
+// Dispute — оспаривание клиентом карточной оплаты (чарджбэк)
+type Dispute struct {
+	ID                    string          `json:"id"`
+	TransactionID         string          `json:"transaction_id"`
+	AccountID             string          `json:"account_id"`
+	MerchantID            string          `json:"merchant_id"`
+	OpenedBy              string          `json:"opened_by"`
+	Amount                decimal.Decimal `json:"amount"`
+	Reason                string          `json:"reason"`
+	Status                string          `json:"status"`
+	ProvisionalCreditTxID string          `json:"provisional_credit_tx_id,omitempty"`
+	ResolutionTxID        string          `json:"resolution_tx_id,omitempty"`
+	ResolvedBy            string          `json:"resolved_by,omitempty"`
+	ResolutionNote        string          `json:"resolution_note,omitempty"`
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+}
+
+const (
+	AuthorizationStatusAuthorized        = "authorized"
+	AuthorizationStatusPartiallyCaptured = "partially_captured"
+	AuthorizationStatusCaptured          = "captured"
+	AuthorizationStatusVoided            = "voided"
+	AuthorizationStatusExpired           = "expired"
+)
+
+// CardAuthorization — холд на счёте карты, который затем списывается (capture) или снимается (void/истечение)
+type CardAuthorization struct {
+	ID             string          `json:"id"`
+	CardID         string          `json:"card_id"`
+	AccountID      string          `json:"account_id"`
+	MerchantID     string          `json:"merchant_id"`
+	TokenID        string          `json:"token_id,omitempty"`
+	Amount         decimal.Decimal `json:"amount"`
+	CapturedAmount decimal.Decimal `json:"captured_amount"`
+	Status         string          `json:"status"`
+	Description    string          `json:"description,omitempty"`
+	TransactionIDs []string        `json:"transaction_ids,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+}
+
+// HeldAmount возвращает сумму, которая ещё удерживается на счёте по авторизации
+func (a CardAuthorization) HeldAmount() decimal.Decimal {
+	if a.Status != AuthorizationStatusAuthorized && a.Status != AuthorizationStatusPartiallyCaptured {
+		return decimal.Zero
+	}
+	return a.Amount.Sub(a.CapturedAmount)
+}
+
+const (
+	StandingOrderOnce            = "once"
+	StandingOrderDaily           = "daily"
+	StandingOrderWeekly          = "weekly"
+	StandingOrderMonthly         = "monthly"           // ежемесячно в день DayOfMonth
+	StandingOrderLastBusinessDay = "last_business_day" // в последний рабочий день месяца
+)
+
+const (
+	StandingOrderStatusActive    = "active"
+	StandingOrderStatusPaused    = "paused"
+	StandingOrderStatusCompleted = "completed"
+	StandingOrderStatusFailed    = "failed" // остановлено после ошибки, не связанной с нехваткой средств
+	StandingOrderStatusCancelled = "cancelled"
+)
+
+// StandingOrder — запланированный или регулярный перевод клиента
+type StandingOrder struct {
+	ID               string          `json:"id"`
+	UserID           string          `json:"user_id"`
+	FromAccountID    string          `json:"from_account_id"`
+	ToAccountID      string          `json:"-"`
+	Recipient        string          `json:"recipient"`      // идентификатор получателя, как его указал клиент
+	RecipientName    string          `json:"recipient_name"` // замаскированное имя получателя
+	Amount           decimal.Decimal `json:"amount"`
+	Description      string          `json:"description,omitempty"`
+	Frequency        string          `json:"frequency"`
+	DayOfMonth       int             `json:"day_of_month,omitempty"` // для monthly; если в месяце меньше дней — последний день
+	StartDate        time.Time       `json:"start_date"`
+	EndDate          *time.Time      `json:"end_date,omitempty"`
+	DueDate          *time.Time      `json:"due_date,omitempty"`           // дата текущего исполнения по расписанию
+	NextRunAt        *time.Time      `json:"next_run_at,omitempty"`        // время следующей попытки; при повторах позже DueDate
+	Attempt          int             `json:"attempt"`                      // номер попытки текущего исполнения
+	LastExecutedDate *time.Time      `json:"last_executed_date,omitempty"` // дата по расписанию последнего успешного исполнения
+	Status           string          `json:"status"`
+	LastError        string          `json:"last_error,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+const (
+	ExecutionStatusSucceeded      = "succeeded"
+	ExecutionStatusRetryScheduled = "retry_scheduled"
+	ExecutionStatusFailed         = "failed"
+)
+
+// StandingOrderExecution — попытка исполнения регулярного перевода
+type StandingOrderExecution struct {
+	ID            string          `json:"id"`
+	OrderID       string          `json:"order_id"`
+	ScheduledFor  time.Time       `json:"scheduled_for"`
+	ExecutedAt    time.Time       `json:"executed_at"`
+	Attempt       int             `json:"attempt"`
+	Amount        decimal.Decimal `json:"amount"`
+	Status        string          `json:"status"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+type Merchant struct {
+	ID                  string    `json:"id"`
+	OwnerUserID         string    `json:"owner_user_id"`
+	Name                string    `json:"name"`
+	MCC                 string    `json:"mcc"`
+	SettlementAccountID string    `json:"settlement_account_id"`
+	AcceptorID          string    `json:"acceptor_id"` // идентификатор ТСП (поле 42 ISO 8583)
+	APIKey              string    `json:"api_key"`
+	APISecret           string    `json:"-"`
+	TerminalMACKey      string    `json:"-"` // ключ MAC сообщений ISO 8583 от терминалов ТСП
+	CreatedAt           time.Time `json:"created_at"`
 }
 
 type Loan struct {
@@ -65,9 +588,12 @@ type Payment struct {
 
 
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	Phone     string `json:"phone"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
 }
 
 type LoginRequest struct {
@@ -75,25 +601,395 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
 type CreateAccountRequest struct {
 	UserID string `json:"user_id"` 
 }
 
 type GenerateCardRequest struct {
-	AccountID string `json:"account_id"`
+	AccountID    string `json:"account_id"`
+	Type         string `json:"type"`          // physical (по умолчанию), virtual, single_use
+	ExpiryMonths int    `json:"expiry_months"` // срок действия виртуальной/одноразовой карты, 1-12 месяцев
+	MerchantID   string `json:"merchant_id"`   // привязка виртуальной/одноразовой карты к мерчанту
 }
 
 type PaymentRequest struct {
     CardNumber string          `json:"card_number"`
     CVV        string          `json:"cvv"`
+    Token      string          `json:"token"`       // токен сохранённой карты вместо card_number и cvv
+    MerchantID string          `json:"merchant_id"` // обязателен при оплате токеном
     Amount     decimal.Decimal `json:"amount"`
     Merchant   string          `json:"merchant"`
+    MCC        string          `json:"mcc"`
+    Channel    string          `json:"channel"` // online | contactless | chip, по умолчанию online
+    Country    string          `json:"country"` // ISO 3166-1 alpha-2, по умолчанию RU
+}
+
+type CreateMerchantRequest struct {
+	Name                string `json:"name"`
+	MCC                 string `json:"mcc"`
+	SettlementAccountID string `json:"settlement_account_id"`
+}
+
+type MerchantPaymentRequest struct {
+	CardNumber  string          `json:"card_number"`
+	CVV         string          `json:"cvv"`
+	Token       string          `json:"token"` // токен сохранённой карты вместо card_number и cvv
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	Channel     string          `json:"channel"`
+	Country     string          `json:"country"`
+}
+
+type CaptureRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type RefundRequest struct {
+	TransactionID string          `json:"transaction_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	Reason        string          `json:"reason"`
+}
+
+type OpenDisputeRequest struct {
+	TransactionID string          `json:"transaction_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	Reason        string          `json:"reason"`
+}
+
+type ResolveDisputeRequest struct {
+	Outcome string `json:"outcome"` // won | lost
+	Note    string `json:"note"`
+}
+
+type SetMerchantMCCRequest struct {
+	MCC string `json:"mcc"`
+}
+
+type SetUserTierRequest struct {
+	Tier string `json:"tier"`
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role"` // customer | operator
+}
+
+type ConfirmPhoneRequest struct {
+	Code string `json:"code"`
+}
+
+type SetOverdraftRequest struct {
+	Limit decimal.Decimal `json:"limit"` // 0 — отключить овердрафт
+	Rate  decimal.Decimal `json:"rate"`  // годовая ставка, %
+}
+
+type InviteAccountMemberRequest struct {
+	User         string          `json:"user"` // логин, email или телефон приглашаемого
+	Permission   string          `json:"permission"`
+	PaymentLimit decimal.Decimal `json:"payment_limit"`
+}
+
+type CreateOrganizationRequest struct {
+	Name                  string          `json:"name"`
+	INN                   string          `json:"inn"`
+	OGRN                  string          `json:"ogrn"`
+	KPP                   string          `json:"kpp"`
+	Title                 string          `json:"title"` // должность создающего подписанта
+	DualApprovalThreshold decimal.Decimal `json:"dual_approval_threshold"`
+}
+
+type AddOrganizationSignerRequest struct {
+	User  string `json:"user"` // логин, email или телефон
+	Title string `json:"title"`
+}
+
+type RejectPaymentApprovalRequest struct {
+	Reason string `json:"reason"`
+}
+
+type CreateExternalPaymentRequest struct {
+	FromAccountID string          `json:"from_account_id"`
+	BIK           string          `json:"bik"`
+	Account       string          `json:"account"`
+	Name          string          `json:"name"`
+	INN           string          `json:"inn"`
+	KPP           string          `json:"kpp"`
+	Amount        decimal.Decimal `json:"amount"`
+	Purpose       string          `json:"purpose"`
+}
+
+type SimulateIncomingPaymentRequest struct {
+	PayerBIK     string          `json:"payer_bik"`
+	PayerAccount string          `json:"payer_account"`
+	PayerName    string          `json:"payer_name"`
+	PayeeAccount string          `json:"payee_account"`
+	Amount       decimal.Decimal `json:"amount"`
+	Purpose      string          `json:"purpose"`
+}
+
+type UpdateSBPSettingsRequest struct {
+	AccountID   string `json:"account_id"`
+	DefaultBank bool   `json:"default_bank"`
+}
+
+type SBPLookupRequest struct {
+	Phone string `json:"phone"`
+}
+
+type SBPTransferRequest struct {
+	FromAccountID string          `json:"from_account_id"`
+	Phone         string          `json:"phone"`
+	BankID        string          `json:"bank_id"` // участник СБП получателя; пусто — банк по умолчанию
+	Amount        decimal.Decimal `json:"amount"`
+	Comment       string          `json:"comment"`
+}
+
+type CreateSBPQRRequest struct {
+	Type       string          `json:"type"`
+	Amount     decimal.Decimal `json:"amount"`
+	Purpose    string          `json:"purpose"`
+	TTLMinutes int             `json:"ttl_minutes"` // срок действия динамического кода; 0 — по умолчанию
+}
+
+type ParseSBPQRRequest struct {
+	Payload string `json:"payload"`
+}
+
+type PaySBPQRRequest struct {
+	FromAccountID string          `json:"from_account_id"`
+	Payload       string          `json:"payload"`
+	Amount        decimal.Decimal `json:"amount"` // только для статического кода без суммы
+}
+
+type SimulateSBPAliasRequest struct {
+	Phone   string `json:"phone"`
+	BankID  string `json:"bank_id"`
+	Name    string `json:"name"` // имя получателя в банке-участнике
+	Default bool   `json:"default"`
+}
+
+type SimulateSBPIncomingRequest struct {
+	FromBankID string          `json:"from_bank_id"`
+	SenderName string          `json:"sender_name"`
+	Phone      string          `json:"phone"`
+	Amount     decimal.Decimal `json:"amount"`
+	Comment    string          `json:"comment"`
+}
+
+// BillerRequest — создание или изменение поставщика услуг оператором
+type BillerRequest struct {
+	Name                string          `json:"name"`
+	Category            string          `json:"category"`
+	INN                 string          `json:"inn"`
+	SettlementAccountID string          `json:"settlement_account_id"`
+	Fields              []BillerField   `json:"fields"`
+	MinAmount           decimal.Decimal `json:"min_amount"`
+	MaxAmount           decimal.Decimal `json:"max_amount"`
+	Active              *bool           `json:"active"` // не указано — поставщик активен
+}
+
+type BillPaymentRequest struct {
+	FromAccountID string            `json:"from_account_id"`
+	BillerID      string            `json:"biller_id"`
+	Fields        map[string]string `json:"fields"`
+	Amount        decimal.Decimal   `json:"amount"`
+}
+
+type BillTemplateRequest struct {
+	Name          string            `json:"name"`
+	BillerID      string            `json:"biller_id"`
+	FromAccountID string            `json:"from_account_id"`
+	Fields        map[string]string `json:"fields"`
+	Amount        decimal.Decimal   `json:"amount"`
+}
+
+// PayBillTemplateRequest — оплата по шаблону; указанные значения дополняют и заменяют сохранённые
+type PayBillTemplateRequest struct {
+	FromAccountID string            `json:"from_account_id"`
+	Fields        map[string]string `json:"fields"`
+	Amount        decimal.Decimal   `json:"amount"`
+}
+
+type CloseAccountRequest struct {
+	SweepToAccountID string `json:"sweep_to_account_id"` // куда перевести остаток; не нужен при нулевом остатке
+}
+
+type SetAccountStatusRequest struct {
+	Status         string          `json:"status"` // active | frozen | arrested
+	ArrestedAmount decimal.Decimal `json:"arrested_amount"`
+	Reason         string          `json:"reason"`
+}
+
+type ReverseTransactionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// UpdateCardControlsRequest — изменение ограничений карты; незаданные поля остаются прежними
+type UpdateCardControlsRequest struct {
+	DailyLimit          *decimal.Decimal `json:"daily_limit"`
+	MonthlyLimit        *decimal.Decimal `json:"monthly_limit"`
+	PerTransactionLimit *decimal.Decimal `json:"per_transaction_limit"`
+	AllowedMCCs         *[]string        `json:"allowed_mccs"`
+	BlockedMCCs         *[]string        `json:"blocked_mccs"`
+	OnlineEnabled       *bool            `json:"online_enabled"`
+	ContactlessEnabled  *bool            `json:"contactless_enabled"`
+	ForeignEnabled      *bool            `json:"foreign_enabled"`
+}
+
+// Apply переносит заданные в запросе поля в ограничения карты
+func (req UpdateCardControlsRequest) Apply(c *CardControls) {
+	if req.DailyLimit != nil {
+		c.DailyLimit = *req.DailyLimit
+	}
+	if req.MonthlyLimit != nil {
+		c.MonthlyLimit = *req.MonthlyLimit
+	}
+	if req.PerTransactionLimit != nil {
+		c.PerTransactionLimit = *req.PerTransactionLimit
+	}
+	if req.AllowedMCCs != nil {
+		c.AllowedMCCs = *req.AllowedMCCs
+	}
+	if req.BlockedMCCs != nil {
+		c.BlockedMCCs = *req.BlockedMCCs
+	}
+	if req.OnlineEnabled != nil {
+		c.OnlineEnabled = *req.OnlineEnabled
+	}
+	if req.ContactlessEnabled != nil {
+		c.ContactlessEnabled = *req.ContactlessEnabled
+	}
+	if req.ForeignEnabled != nil {
+		c.ForeignEnabled = *req.ForeignEnabled
+	}
+}
+
+type SetPINRequest struct {
+	PIN string `json:"pin"`
+}
+
+type ChangePINRequest struct {
+	OldPIN string `json:"old_pin"`
+	NewPIN string `json:"new_pin"`
+}
+
+type VerifyPINRequest struct {
+	CardNumber string `json:"card_number"`
+	PIN        string `json:"pin"`
+}
+
+type ATMWithdrawalRequest struct {
+	CardNumber string          `json:"card_number"`
+	PIN        string          `json:"pin"`
+	Amount     decimal.Decimal `json:"amount"`
+}
+
+type CreateCardTokenRequest struct {
+	CardNumber string `json:"card_number"`
+	CVV        string `json:"cvv"`
 }
 
 type TransferRequest struct {
 	FromAccountID string          `json:"from_account_id"`
 	ToAccountID   string          `json:"to_account_id"`
+	To            string          `json:"to"`      // номер счёта, номер карты или телефон получателя вместо to_account_id
+	ToType        string          `json:"to_type"` // account_number | card_number | phone; по умолчанию определяется по формату
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+// Режимы исполнения пакетного перевода
+const (
+	BatchModeAtomic     = "atomic"      // все строки или ни одной
+	BatchModeBestEffort = "best_effort" // каждая строка независимо
+)
+
+// Статусы строки и пакета в отчёте о пакетном переводе
+const (
+	BatchLineSucceeded   = "succeeded"
+	BatchLineFailed      = "failed"
+	BatchLineInvalid     = "invalid"
+	BatchLineNotExecuted = "not_executed"
+
+	BatchStatusCompleted = "completed"
+	BatchStatusPartial   = "partially_completed"
+	BatchStatusFailed    = "failed"
+	BatchStatusRejected  = "rejected"
+)
+
+// BatchTransferLine — строка пакета (зарплатной ведомости): получатель задаётся так же, как в TransferRequest
+type BatchTransferLine struct {
+	ToAccountID string          `json:"to_account_id"`
+	To          string          `json:"to"`
+	ToType      string          `json:"to_type"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	Reference   string          `json:"reference"` // например, табельный номер сотрудника
+}
+
+type BatchTransferRequest struct {
+	FromAccountID string              `json:"from_account_id"`
+	Mode          string              `json:"mode"` // atomic | best_effort, по умолчанию atomic
+	Transfers     []BatchTransferLine `json:"transfers"`
+}
+
+type BatchTransferLineResult struct {
+	Line          int             `json:"line"` // номер строки в пакете, начиная с 1
+	Reference     string          `json:"reference,omitempty"`
+	Recipient     string          `json:"recipient"`
+	Amount        decimal.Decimal `json:"amount"`
+	Status        string          `json:"status"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// BatchTransferReport — построчный отчёт об исполнении пакета
+type BatchTransferReport struct {
+	BatchID           string                    `json:"batch_id"`
+	FromAccountID     string                    `json:"from_account_id"`
+	Mode              string                    `json:"mode"`
+	Status            string                    `json:"status"`
+	Error             string                    `json:"error,omitempty"`
+	TotalLines        int                       `json:"total_lines"`
+	Succeeded         int                       `json:"succeeded"`
+	Failed            int                       `json:"failed"`
+	TotalAmount       decimal.Decimal           `json:"total_amount"`
+	TransferredAmount decimal.Decimal           `json:"transferred_amount"`
+	Lines             []BatchTransferLineResult `json:"lines"`
+}
+
+type ResolveRecipientRequest struct {
+	Recipient     string `json:"recipient"`
+	RecipientType string `json:"recipient_type"`
+}
+
+type CreateStandingOrderRequest struct {
+	FromAccountID string          `json:"from_account_id"`
+	ToAccountID   string          `json:"to_account_id"`
+	To            string          `json:"to"` // номер счёта, номер карты или телефон получателя вместо to_account_id
+	ToType        string          `json:"to_type"`
 	Amount        decimal.Decimal `json:"amount"`
+	Description   string          `json:"description"`
+	Frequency     string          `json:"frequency"`
+	DayOfMonth    int             `json:"day_of_month"`
+	StartDate     string          `json:"start_date"` // YYYY-MM-DD
+	EndDate       string          `json:"end_date"`   // YYYY-MM-DD, необязательно
+}
+
+type UpdateStandingOrderRequest struct {
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	Frequency   string          `json:"frequency"`
+	DayOfMonth  int             `json:"day_of_month"`
+	StartDate   string          `json:"start_date"`
+	EndDate     string          `json:"end_date"`
 }
 
 type DepositRequest struct {
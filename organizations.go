@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func respondOrganizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOrganizationNotFound):
+		respondError(w, http.StatusNotFound, "Organization not found")
+	case errors.Is(err, ErrApprovalNotFound):
+		respondError(w, http.StatusNotFound, "Payment approval not found")
+	case errors.Is(err, ErrNotSigner):
+		respondError(w, http.StatusForbidden, "User is not a signer of the organization")
+	case errors.Is(err, ErrOrganizationExists), errors.Is(err, ErrAlreadySigner), errors.Is(err, ErrLastSigner),
+		errors.Is(err, ErrInvalidApprovalState), errors.Is(err, ErrAlreadyApproved):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		// Ошибки исполнения платежа после второй подписи
+		respondTransferError(w, err)
+	}
+}
+
+// signerOrganization возвращает организацию из пути запроса, если пользователь — её подписант
+func signerOrganization(w http.ResponseWriter, r *http.Request) (Organization, string, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return Organization{}, "", false
+	}
+	org, ok := GetSignerOrganization(mux.Vars(r)["orgId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Organization not found")
+		return Organization{}, "", false
+	}
+	return org, userID, true
+}
+
+// CreateOrganizationHandler регистрирует юридическое лицо; создавший его пользователь становится первым подписантом
+func CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateOrganizationRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	now := time.Now()
+	org := Organization{
+		ID:   GenerateID(),
+		Name: strings.TrimSpace(req.Name),
+		INN:  req.INN,
+		OGRN: req.OGRN,
+		KPP:  req.KPP,
+		Signers: []OrganizationSigner{
+			{UserID: userID, Title: req.Title, AddedBy: userID, AddedAt: now},
+		},
+		DualApprovalThreshold: req.DualApprovalThreshold,
+		CreatedBy:             userID,
+		CreatedAt:             now,
+	}
+	if err := AddOrganization(org); err != nil {
+		respondOrganizationError(w, err)
+		return
+	}
+
+	log.Printf("Organization %s (INN %s) registered by user %s", org.ID, org.INN, userID)
+	respondJSON(w, http.StatusCreated, org)
+}
+
+func GetOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetUserOrganizations(userID))
+}
+
+func GetOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := signerOrganization(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, org)
+}
+
+// CreateOrganizationAccountHandler открывает организации расчётный счёт на балансовом счёте 40702
+func CreateOrganizationAccountHandler(w http.ResponseWriter, r *http.Request) {
+	org, userID, ok := signerOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	account := Account{
+		ID:               GenerateID(),
+		UserID:           userID,
+		OrganizationID:   org.ID,
+		Number:           GenerateAccountNumber(BalanceAccountSettlement),
+		Balance:          decimal.Zero,
+		AvailableBalance: decimal.Zero,
+		Status:           AccountStatusActive,
+		CreatedAt:        time.Now(),
+	}
+	account, err := AddAccount(account)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create account: %v", err))
+		return
+	}
+
+	log.Printf("Settlement account %s opened for organization %s by user %s", account.Number, org.ID, userID)
+	respondJSON(w, http.StatusCreated, account)
+}
+
+func GetOrganizationAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := signerOrganization(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, GetOrganizationAccounts(org.ID))
+}
+
+// GetPaymentApprovalsHandler возвращает платежи организации; ?status=pending — только ожидающие подписи
+func GetPaymentApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := signerOrganization(w, r)
+	if !ok {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", PaymentApprovalPending, PaymentApprovalExecuted, PaymentApprovalRejected:
+	default:
+		respondError(w, http.StatusBadRequest, "status must be one of pending, executed, rejected")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetOrganizationApprovals(org.ID, status))
+}
+
+// organizationApproval возвращает платёж организации из пути запроса
+func organizationApproval(w http.ResponseWriter, r *http.Request) (PaymentApproval, bool) {
+	vars := mux.Vars(r)
+	approval, ok := GetPaymentApproval(vars["approvalId"])
+	if !ok || approval.OrganizationID != vars["orgId"] {
+		respondError(w, http.StatusNotFound, "Payment approval not found")
+		return PaymentApproval{}, false
+	}
+	return approval, true
+}
+
+// ApprovePaymentHandler ставит подпись подписанта; вторая подпись исполняет платёж
+func ApprovePaymentHandler(w http.ResponseWriter, r *http.Request) {
+	org, userID, ok := signerOrganization(w, r)
+	if !ok {
+		return
+	}
+	approval, ok := organizationApproval(w, r)
+	if !ok {
+		return
+	}
+
+	signed, err := ApprovePayment(approval.ID, userID, time.Now())
+	if err != nil {
+		log.Printf("Approval of payment %s by user %s failed: %v", approval.ID, userID, err)
+		respondOrganizationError(w, err)
+		return
+	}
+
+	log.Printf("Payment %s of organization %s signed by user %s, status %s", signed.ID, org.ID, userID, signed.Status)
+	respondJSON(w, http.StatusOK, signed)
+}
+
+// RejectPaymentHandler отклоняет ожидающий платёж. Инициатор, не являющийся подписантом,
+// может отозвать свой платёж.
+func RejectPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req RejectPaymentApprovalRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	approval, ok := organizationApproval(w, r)
+	if !ok {
+		return
+	}
+
+	rejected, err := RejectPayment(approval.ID, userID, req.Reason, time.Now())
+	if err != nil {
+		if errors.Is(err, ErrNotSigner) {
+			respondError(w, http.StatusNotFound, "Payment approval not found")
+			return
+		}
+		respondOrganizationError(w, err)
+		return
+	}
+
+	log.Printf("Payment %s of organization %s rejected by user %s", rejected.ID, rejected.OrganizationID, userID)
+	respondJSON(w, http.StatusOK, rejected)
+}
+
+// AddOrganizationSignerHandler добавляет подписанта по карточке образцов подписей.
+// Состав подписантов меняет оператор: иначе один подписант мог бы сам обойти требование двух подписей.
+func AddOrganizationSignerHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req AddOrganizationSignerRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+	user, ok := findUserByIdentifier(req.User)
+	if !ok {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	org, err := AddOrganizationSigner(mux.Vars(r)["orgId"], OrganizationSigner{
+		UserID:  user.ID,
+		Title:   req.Title,
+		AddedBy: operatorID,
+		AddedAt: time.Now(),
+	})
+	if err != nil {
+		respondOrganizationError(w, err)
+		return
+	}
+
+	log.Printf("User %s added as signer of organization %s by operator %s", user.ID, org.ID, operatorID)
+	respondJSON(w, http.StatusOK, org)
+}
+
+func RemoveOrganizationSignerHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	org, err := RemoveOrganizationSigner(vars["orgId"], vars["userId"])
+	if err != nil {
+		respondOrganizationError(w, err)
+		return
+	}
+
+	log.Printf("User %s removed from signers of organization %s by operator %s", vars["userId"], org.ID, operatorID)
+	respondJSON(w, http.StatusOK, org)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SetOverdraftHandler устанавливает лимит и ставку овердрафта по счёту (для операторов).
+// Снижение лимита ниже текущего минуса допускается: новые списания будут отклоняться до пополнения.
+func SetOverdraftHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SetOverdraftRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	account, err := SetOverdraft(mux.Vars(r)["accountId"], req.Limit, req.Rate)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			respondError(w, http.StatusNotFound, "Account not found")
+		} else {
+			respondError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("Overdraft on account %s set to %s at %s%% by operator %s", account.ID, req.Limit.String(), req.Rate.String(), operatorID)
+	respondJSON(w, http.StatusOK, account)
+}
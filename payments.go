@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Коды отказа в карточной операции
+const (
+	DeclineCardNotFound       = "card_not_found"
+	DeclineInvalidCVV         = "invalid_cvv"
+	DeclineCardExpired        = "card_expired"
+	DeclineInsufficientFunds  = "insufficient_funds"
+	DeclineCardClosed         = "card_closed"
+	DeclineCardInUse          = "card_in_use"
+	DeclineMerchantNotAllowed = "merchant_not_allowed"
+	DeclineAccountBlocked     = "account_blocked"
+	DeclineDualApproval       = "dual_approval_required"
+)
+
+// PaymentError описывает отказ в проведении карточной операции с понятной причиной
+type PaymentError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *PaymentError) Error() string {
+	return e.Message
+}
+
+func declinePayment(status int, code, message string) *PaymentError {
+	return &PaymentError{Status: status, Code: code, Message: message}
+}
+
+func respondPaymentError(w http.ResponseWriter, err error) {
+	var pe *PaymentError
+	if errors.As(err, &pe) {
+		respondJSON(w, pe.Status, map[string]string{
+			"error":        pe.Message,
+			"decline_code": pe.Code,
+		})
+		return
+	}
+	respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process payment: %v", err))
+}
+
+// accountDecline переводит отказ по счёту карты (нехватка средств, заморозка, закрытие) в отказ с кодом
+func accountDecline(err error) error {
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		return declinePayment(http.StatusPaymentRequired, DeclineInsufficientFunds, "Insufficient funds")
+	case errors.Is(err, ErrAccountFrozen):
+		return declinePayment(http.StatusForbidden, DeclineAccountBlocked, "Account is frozen")
+	case errors.Is(err, ErrAccountClosed):
+		return declinePayment(http.StatusForbidden, DeclineAccountBlocked, "Account is closed")
+	}
+	return err
+}
+
+// verifyCardForPayment находит карту, проверяет CVV и срок действия
+func verifyCardForPayment(cardID, cvv string) (Card, error) {
+	card, ok := GetCardByID(cardID)
+	if !ok {
+		return Card{}, declinePayment(http.StatusNotFound, DeclineCardNotFound, "Card not found")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(card.CVV), []byte(cvv)); err != nil {
+		return Card{}, declinePayment(http.StatusUnauthorized, DeclineInvalidCVV, "Invalid CVV")
+	}
+
+	return card, checkCardActive(card)
+}
+
+// checkCardActive проверяет, что карта не закрыта и не истекла
+func checkCardActive(card Card) error {
+	if card.Status != CardStatusActive {
+		return declinePayment(http.StatusForbidden, DeclineCardClosed, "Card is closed")
+	}
+
+	now := time.Now()
+	expiry := time.Date(card.ExpiryYear, time.Month(card.ExpiryMonth), 1, 23, 59, 59, 0, time.UTC).AddDate(0, 1, -1)
+	if now.After(expiry) {
+		return declinePayment(http.StatusBadRequest, DeclineCardExpired, "Card expired")
+	}
+
+	return nil
+}
+
+// cardGuard возвращает проверку, выполняемую под storage.mu непосредственно перед движением средств:
+// актуальный статус карты (одноразовая карта могла быть уже использована), привязка к мерчанту,
+// статус токена (мог быть приостановлен), ограничения карты и лимиты клиента
+func cardGuard(cardID string, use CardUse, now time.Time) func() error {
+	return func() error {
+		card, ok := storage.cards[cardID]
+		if !ok {
+			return declinePayment(http.StatusNotFound, DeclineCardNotFound, "Card not found")
+		}
+		if card.Status != CardStatusActive {
+			return declinePayment(http.StatusForbidden, DeclineCardClosed, "Card is closed")
+		}
+		// Одноразовая карта закрывается при списании, поэтому пока по ней открыта авторизация, новые операции не принимаются
+		if card.Type == CardTypeSingleUse && hasOpenAuthorization(card.ID) {
+			return declinePayment(http.StatusConflict, DeclineCardInUse, "Single-use card has a pending authorization")
+		}
+		if card.LockedMerchantID != "" && card.LockedMerchantID != use.MerchantID {
+			return declinePayment(http.StatusForbidden, DeclineMerchantNotAllowed, "Card is locked to a different merchant")
+		}
+		if use.TokenID != "" {
+			if err := checkCardToken(storage.cardTokens[use.TokenID], use.MerchantID); err != nil {
+				return err
+			}
+		}
+		if err := checkCardControls(card, use, now); err != nil {
+			return err
+		}
+
+		// Карточная операция не может ждать второй подписи, поэтому по счёту организации суммы выше порога отклоняются
+		acc := storage.accounts[card.AccountID]
+		if requiresDualApproval(acc, use.Amount) {
+			return declinePayment(http.StatusForbidden, DeclineDualApproval, "Amount exceeds the organization's dual approval threshold")
+		}
+
+		op := LimitedOperation{Type: use.Operation, Counterparty: use.MerchantID, Amount: use.Amount}
+		if op.Type == "" {
+			op.Type = LimitOpCardPayment
+		}
+		return cardLimitDecline(checkLimits(acc, []LimitedOperation{op}, now))
+	}
+}
+
+// newCardUse заполняет канал и страну операции значениями по умолчанию
+func newCardUse(amount decimal.Decimal, mcc, channel, country, merchantID string) CardUse {
+	if channel == "" {
+		channel = CardChannelOnline
+	}
+	if country == "" {
+		country = homeCountry
+	}
+	return CardUse{Amount: amount, MCC: mcc, Channel: channel, Country: country, MerchantID: merchantID}
+}
+
+// chargeCard проверяет ограничения карты и списывает сумму со счёта карты;
+// если указан мерчант, выручка зачисляется на его расчётный счёт
+func chargeCard(card Card, use CardUse, description string, merchant *Merchant) (Transaction, error) {
+	now := time.Now()
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   card.AccountID,
+		Amount:          use.Amount,
+		Timestamp:       now,
+		TransactionType: "payment",
+		Description:     description,
+		CardID:          card.ID,
+		CardTokenID:     use.TokenID,
+	}
+	if merchant != nil {
+		tx.ToAccountID = merchant.SettlementAccountID
+		tx.MerchantID = merchant.ID
+	}
+
+	if err := ChargeAccount(tx, cardGuard(card.ID, use, now)); err != nil {
+		return Transaction{}, accountDecline(err)
+	}
+	return tx, nil
+}
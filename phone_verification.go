@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Параметры кода подтверждения телефона
+const (
+	phoneCodeLength         = 6
+	phoneCodeTTL            = 10 * time.Minute
+	phoneCodeResendInterval = time.Minute
+	maxPhoneCodeAttempts    = 5
+)
+
+// generatePhoneCode генерирует шестизначный код подтверждения
+func generatePhoneCode() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1000000))
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+// hashPhoneCode хранит не сам код, а HMAC от него, привязанный к пользователю и номеру
+func hashPhoneCode(userID, phone, code string) string {
+	return GenerateHMAC(userID+":"+phone+":"+code, deriveKey(jwtKey, "phone-code"))
+}
+
+// SendSMSNotification отправляет SMS через HTTP-шлюз из SMS_GATEWAY_URL (POST {"phone", "text"}).
+// Без настроенного шлюза отправка пропускается.
+func SendSMSNotification(phone, text string) error {
+	gatewayURL := os.Getenv("SMS_GATEWAY_URL")
+	if gatewayURL == "" {
+		log.Printf("SMS-шлюз не настроен. Пропускаем отправку SMS на %s", phone)
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"phone": phone, "text": text})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(gatewayURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("не удалось отправить SMS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS-шлюз ответил %s", resp.Status)
+	}
+	return nil
+}
+
+// RequestPhoneCodeHandler отправляет на телефон пользователя код подтверждения
+func RequestPhoneCodeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	user, ok := GetUser(userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.Phone == "" {
+		respondError(w, http.StatusConflict, "User has no phone number")
+		return
+	}
+	if user.PhoneVerified {
+		respondError(w, http.StatusConflict, "Phone already verified")
+		return
+	}
+
+	now := time.Now()
+	if prev, ok := GetPhoneVerification(userID); ok && now.Sub(prev.SentAt) < phoneCodeResendInterval {
+		respondError(w, http.StatusTooManyRequests, "Confirmation code was sent recently, try again later")
+		return
+	}
+
+	code := generatePhoneCode()
+	SavePhoneVerification(PhoneVerification{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		CodeHash:  hashPhoneCode(user.ID, user.Phone, code),
+		SentAt:    now,
+		ExpiresAt: now.Add(phoneCodeTTL),
+	})
+
+	go func() {
+		text := fmt.Sprintf("Simple Bank: код подтверждения телефона %s. Никому его не сообщайте.", code)
+		if err := SendSMSNotification(user.Phone, text); err != nil {
+			log.Printf("Failed to send phone confirmation code to user %s: %v", user.ID, err)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":    "Confirmation code sent",
+		"expires_at": now.Add(phoneCodeTTL),
+	})
+}
+
+// ConfirmPhoneHandler подтверждает телефон кодом из SMS
+func ConfirmPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ConfirmPhoneRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	user, ok := GetUser(userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	user, err := ConfirmPhone(userID, hashPhoneCode(userID, user.Phone, req.Code), time.Now())
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrIncorrectPhoneCode), errors.Is(err, ErrPhoneCodeExpired):
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrPhoneTaken):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	default:
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to confirm phone: %v", err))
+		return
+	}
+
+	log.Printf("Phone verified for user %s", user.ID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Phone verified"})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// MCC банкоматов: выдача наличных доступна только терминалам-мерчантам с этим кодом
+const atmMCC = "6011"
+
+// MCC выдачи наличных (6010 — в кассе, 6011 — банкомат) назначает мерчанту только оператор
+var cashMCCs = []string{"6010", atmMCC}
+
+const (
+	DeclineIncorrectPIN = "incorrect_pin"
+	DeclinePINBlocked   = "pin_blocked"
+	DeclinePINNotSet    = "pin_not_set"
+	DeclineNotATM       = "not_atm_terminal"
+)
+
+// pinKey возвращает ключ для хеширования PIN-кодов. Ключ обязан отличаться от ключа HMAC карт.
+func pinKey() ([]byte, error) {
+	key := []byte(os.Getenv("PIN_HMAC_KEY"))
+	if len(key) == 0 {
+		return nil, errors.New("PIN_HMAC_KEY not set")
+	}
+	if isCardIntegrityKey(key) {
+		return nil, errors.New("PIN_HMAC_KEY must differ from the card HMAC keys")
+	}
+	return key, nil
+}
+
+// hashPIN вычисляет keyed-хеш PIN-кода; ID карты используется как соль
+func hashPIN(cardID, pin string) (string, error) {
+	key, err := pinKey()
+	if err != nil {
+		return "", err
+	}
+	return GenerateHMAC(cardID+":"+pin, key), nil
+}
+
+// verifyPIN проверяет PIN-код карты и переводит ошибки в отказы с кодами
+func verifyPIN(card Card, pin string) error {
+	pinHash, err := hashPIN(card.ID, pin)
+	if err != nil {
+		return err
+	}
+
+	switch err := VerifyCardPIN(card.ID, pinHash); {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrIncorrectPIN):
+		return declinePayment(http.StatusUnauthorized, DeclineIncorrectPIN, "Incorrect PIN")
+	case errors.Is(err, ErrPINBlocked):
+		return declinePayment(http.StatusForbidden, DeclinePINBlocked, "PIN is blocked after too many incorrect attempts")
+	case errors.Is(err, ErrPINNotSet):
+		return declinePayment(http.StatusForbidden, DeclinePINNotSet, "PIN is not set for this card")
+	default:
+		return err
+	}
+}
+
+// SetPINHandler устанавливает PIN-код; повторная установка владельцем также снимает блокировку PIN
+func SetPINHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SetPINRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+	if card.PINHash != "" && !card.PINBlocked {
+		respondError(w, http.StatusConflict, "PIN is already set, use PUT to change it")
+		return
+	}
+
+	pinHash, err := hashPIN(card.ID, req.PIN)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to set PIN: %v", err))
+		return
+	}
+	if err := SetCardPIN(card.ID, pinHash); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to set PIN: %v", err))
+		return
+	}
+
+	log.Printf("PIN set for card %s", card.ID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "PIN set"})
+}
+
+func ChangePINHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ChangePINRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+
+	if err := verifyPIN(card, req.OldPIN); err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	pinHash, err := hashPIN(card.ID, req.NewPIN)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to change PIN: %v", err))
+		return
+	}
+	if err := SetCardPIN(card.ID, pinHash); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to change PIN: %v", err))
+		return
+	}
+
+	log.Printf("PIN changed for card %s", card.ID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "PIN changed"})
+}
+
+// VerifyPINHandler — проверка PIN-кода POS-терминалом мерчанта
+func VerifyPINHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req VerifyPINRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	card, ok := GetCardByID(req.CardNumber)
+	if !ok {
+		respondPaymentError(w, declinePayment(http.StatusNotFound, DeclineCardNotFound, "Card not found"))
+		return
+	}
+	if err := checkCardActive(card); err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+	if err := verifyPIN(card, req.PIN); err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]bool{"verified": true})
+}
+
+// ATMWithdrawalHandler — выдача наличных в банкомате: проверка PIN и списание со счёта карты
+func ATMWithdrawalHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ATMWithdrawalRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	terminal, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+	if terminal.MCC != atmMCC {
+		respondPaymentError(w, declinePayment(http.StatusForbidden, DeclineNotATM, "Cash withdrawal is only available for ATM terminals"))
+		return
+	}
+
+	card, ok := GetCardByID(req.CardNumber)
+	if !ok {
+		respondPaymentError(w, declinePayment(http.StatusNotFound, DeclineCardNotFound, "Card not found"))
+		return
+	}
+	if err := checkCardActive(card); err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+	if err := verifyPIN(card, req.PIN); err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	tx, err := withdrawCash(card, req.Amount, terminal)
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+
+	log.Printf("ATM withdrawal of %s from account %s at terminal %s", tx.Amount.String(), tx.FromAccountID, terminal.ID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Withdrawal successful",
+		"transaction_id": tx.ID,
+		"amount":         tx.Amount,
+	})
+}
+
+// withdrawCash списывает сумму выдачи наличных со счёта карты
+func withdrawCash(card Card, amount decimal.Decimal, terminal Merchant) (Transaction, error) {
+	now := time.Now()
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   card.AccountID,
+		Amount:          amount,
+		Timestamp:       now,
+		TransactionType: "atm_withdrawal",
+		Description:     fmt.Sprintf("Cash withdrawal at %s", terminal.Name),
+		CardID:          card.ID,
+		MerchantID:      terminal.ID,
+	}
+
+	use := newCardUse(amount, terminal.MCC, CardChannelChip, homeCountry, terminal.ID)
+	use.Operation = LimitOpATMWithdrawal
+	if err := ChargeAccount(tx, cardGuard(card.ID, use, now)); err != nil {
+		return Transaction{}, accountDecline(err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func respondRefundError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrTransactionNotFound):
+		respondError(w, http.StatusNotFound, "Original payment transaction not found")
+	case errors.Is(err, ErrRefundExceedsOriginal):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInsufficientFunds):
+		respondError(w, http.StatusPaymentRequired, "Insufficient funds in settlement account")
+	case errors.Is(err, ErrDisputeNotFound):
+		respondError(w, http.StatusNotFound, "Dispute not found")
+	case errors.Is(err, ErrDisputeAlreadyOpen), errors.Is(err, ErrInvalidDisputeState):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrAccountClosed):
+		respondError(w, http.StatusConflict, "Account is closed")
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// MerchantRefundHandler возвращает клиенту полную или частичную сумму оплаты мерчанта
+func MerchantRefundHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req RefundRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	description := fmt.Sprintf("Refund from %s", merchant.Name)
+	if req.Reason != "" {
+		description = fmt.Sprintf("%s: %s", description, req.Reason)
+	}
+
+	tx, err := RefundTransaction(merchant.ID, Transaction{
+		ID:                    GenerateID(),
+		Amount:                req.Amount,
+		Timestamp:             time.Now(),
+		Description:           description,
+		OriginalTransactionID: req.TransactionID,
+	})
+	if err != nil {
+		respondRefundError(w, err)
+		return
+	}
+
+	log.Printf("Refund %s of %s for transaction %s by merchant %s", tx.ID, tx.Amount.String(), req.TransactionID, merchant.ID)
+	respondJSON(w, http.StatusCreated, tx)
+}
+
+func OpenDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req OpenDisputeRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	orig, ok := GetTransaction(req.TransactionID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Original payment transaction not found")
+		return
+	}
+	account, err := AuthorizeAccount(orig.FromAccountID, userID, AccountPermissionFull, decimal.Zero)
+	if err != nil {
+		respondAccountAccessError(w, err, "Original payment transaction not found")
+		return
+	}
+
+	now := time.Now()
+	dispute, err := OpenDispute(Dispute{
+		ID:            GenerateID(),
+		TransactionID: orig.ID,
+		AccountID:     account.ID,
+		OpenedBy:      userID,
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		respondRefundError(w, err)
+		return
+	}
+
+	log.Printf("Dispute %s opened by user %s for transaction %s", dispute.ID, userID, orig.ID)
+	respondJSON(w, http.StatusCreated, dispute)
+}
+
+func GetUserDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ListDisputes(userID, ""))
+}
+
+func ListDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, ListDisputes("", r.URL.Query().Get("status")))
+}
+
+func ProvisionalCreditHandler(w http.ResponseWriter, r *http.Request) {
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	dispute, err := GrantProvisionalCredit(mux.Vars(r)["disputeId"], time.Now())
+	if err != nil {
+		respondRefundError(w, err)
+		return
+	}
+
+	log.Printf("Provisional credit of %s granted for dispute %s by operator %s", dispute.Amount.String(), dispute.ID, operatorID)
+	respondJSON(w, http.StatusOK, dispute)
+}
+
+func ResolveDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ResolveDisputeRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	dispute, err := ResolveDispute(mux.Vars(r)["disputeId"], req.Outcome, operatorID, req.Note, time.Now())
+	if err != nil {
+		respondRefundError(w, err)
+		return
+	}
+
+	log.Printf("Dispute %s resolved as %s by operator %s", dispute.ID, dispute.Status, operatorID)
+	respondJSON(w, http.StatusOK, dispute)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ReverseTransactionHandler сторнирует ошибочное зачисление или перевод по решению оператора
+func ReverseTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ReverseTransactionRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	operatorID, _ := r.Context().Value(userContextKey).(string)
+
+	tx, shortfall, err := ReverseTransaction(mux.Vars(r)["transactionId"], operatorID, req.Reason, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTransactionNotFound):
+			respondError(w, http.StatusNotFound, "Transaction not found")
+		case errors.Is(err, ErrAlreadyReversed), errors.Is(err, ErrNotReversible):
+			respondError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, ErrAccountNotFound):
+			respondError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			// Закрытый или замороженный счёт и другие отказы по статусу счёта
+			respondTransferError(w, err)
+		}
+		return
+	}
+
+	if shortfall.IsPositive() {
+		log.Printf("Transaction %s reversed by operator %s with shortfall %s on account %s placed on recovery hold",
+			tx.ReversalOf, operatorID, shortfall.String(), tx.FromAccountID)
+	} else {
+		log.Printf("Transaction %s reversed by operator %s", tx.ReversalOf, operatorID)
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"transaction":   tx,
+		"recovery_hold": shortfall,
+	})
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// ID участника СБП по умолчанию; переопределяется переменной SBP_MEMBER_ID
+const defaultSBPMemberID = "100000000999"
+
+// Срок действия динамического QR-кода, если мерчант его не указал
+const defaultSBPQRTTL = 30 * time.Minute
+
+var (
+	sbpMemberID string
+	sbpSwitch   PaymentSwitch
+)
+
+// InitSBP подключает банк к операционному центру СБП. Настоящего подключения нет,
+// поэтому используется локальный операционный центр в памяти процесса.
+func InitSBP() error {
+	sbpMemberID = envOrDefault("SBP_MEMBER_ID", defaultSBPMemberID)
+	var errs ValidationErrors
+	validateSBPBankID(&errs, "SBP_MEMBER_ID", sbpMemberID)
+	if len(errs) > 0 {
+		return errs
+	}
+	local := NewLocalSwitch()
+	local.Connect(sbpMemberID, bankRequisites.Name, receiveSBPTransfer)
+	sbpSwitch = local
+	return nil
+}
+
+// receiveSBPTransfer зачисляет перевод, пришедший из операционного центра
+func receiveSBPTransfer(t SwitchTransfer) error {
+	tx, err := CreditSBPTransfer(t, time.Now())
+	if err != nil {
+		log.Printf("SBP transfer %s from %s to %s rejected: %v", t.ID, t.FromMemberID, t.Phone, err)
+		return err
+	}
+	log.Printf("SBP transfer %s of %s from %s credited to account %s", t.ID, t.Amount.String(), t.FromMemberID, tx.ToAccountID)
+	return nil
+}
+
+func respondSBPError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSBPRecipientNotFound):
+		respondError(w, http.StatusNotFound, "Recipient is not registered in SBP")
+	case errors.Is(err, ErrSBPQRNotFound):
+		respondError(w, http.StatusNotFound, "QR code not found")
+	case errors.Is(err, ErrSBPUnknownMember):
+		respondError(w, http.StatusUnprocessableEntity, "Unknown SBP participant")
+	case errors.Is(err, ErrSBPRejected):
+		respondError(w, http.StatusUnprocessableEntity, "Transfer rejected by recipient bank")
+	case errors.Is(err, ErrInvalidSBPQR), errors.Is(err, ErrSBPQRChecksum):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrSBPQRNotPayable), errors.Is(err, ErrSBPQRExpired):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondTransferError(w, err)
+	}
+}
+
+// SBPSettings — привязка номера телефона клиента и банки, в которых номер зарегистрирован в СБП
+type SBPSettings struct {
+	Phone string            `json:"phone"`
+	Alias *SBPAlias         `json:"alias,omitempty"`
+	Banks []SwitchRecipient `json:"banks"`
+}
+
+// sbpUser возвращает пользователя из контекста; переводы по номеру телефона возможны только при указанном в профиле номере
+func sbpUser(w http.ResponseWriter, r *http.Request) (User, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return User{}, false
+	}
+	user, ok := GetUser(userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "User not found")
+		return User{}, false
+	}
+	if user.Phone == "" {
+		respondError(w, http.StatusConflict, "Phone number is not set in profile")
+		return User{}, false
+	}
+	return user, true
+}
+
+func sbpSettings(phone string) SBPSettings {
+	settings := SBPSettings{Phone: phone, Banks: []SwitchRecipient{}}
+	if alias, ok := GetSBPAlias(phone); ok {
+		settings.Alias = &alias
+	}
+	if banks, err := sbpSwitch.Lookup(phone); err == nil {
+		settings.Banks = banks
+	}
+	return settings
+}
+
+func GetSBPSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if user, ok := sbpUser(w, r); ok {
+		respondJSON(w, http.StatusOK, sbpSettings(user.Phone))
+	}
+}
+
+// UpdateSBPSettingsHandler привязывает номер телефона из профиля к личному счёту клиента
+// и регистрирует его в СБП; default_bank делает банк основным для входящих переводов
+func UpdateSBPSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req UpdateSBPSettingsRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	user, ok := sbpUser(w, r)
+	if !ok {
+		return
+	}
+	// Привязать можно только номер, которым клиент подтвердил владение кодом из SMS
+	if !user.PhoneVerified {
+		respondError(w, http.StatusConflict, "Phone number is not verified")
+		return
+	}
+
+	// Номер привязывается только к собственному личному счёту, не к общему доступу и не к счёту организации
+	account, ok := IsAccountHolder(req.AccountID, user.ID)
+	if !ok || account.UserID != user.ID || account.OrganizationID != "" {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Account %s not found", req.AccountID))
+		return
+	}
+	alias, err := SetSBPAlias(SBPAlias{
+		Phone:       user.Phone,
+		UserID:      user.ID,
+		AccountID:   account.ID,
+		DefaultBank: req.DefaultBank,
+	}, time.Now())
+	if err != nil {
+		respondTransferError(w, err)
+		return
+	}
+	if err := sbpSwitch.RegisterAlias(alias.Phone, sbpMemberID, maskRecipientName(user), alias.DefaultBank); err != nil {
+		RemoveSBPAlias(alias.Phone)
+		respondError(w, http.StatusBadGateway, fmt.Sprintf("Failed to register phone in SBP: %v", err))
+		return
+	}
+
+	log.Printf("User %s linked phone %s to account %s in SBP (default bank: %t)", user.ID, alias.Phone, account.ID, alias.DefaultBank)
+	respondJSON(w, http.StatusOK, sbpSettings(user.Phone))
+}
+
+// DeleteSBPSettingsHandler отвязывает номер телефона от счёта; входящие переводы по номеру в банк больше не поступают
+func DeleteSBPSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := sbpUser(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := RemoveSBPAlias(user.Phone); !ok {
+		respondError(w, http.StatusNotFound, "Phone number is not linked to an account")
+		return
+	}
+	sbpSwitch.RemoveAlias(user.Phone, sbpMemberID)
+
+	log.Printf("User %s unlinked phone %s from SBP", user.ID, user.Phone)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unlinkSBPAccount отвязывает от закрытого счёта номера телефонов и снимает их регистрацию в СБП
+func unlinkSBPAccount(accountID string) {
+	for _, alias := range RemoveAccountSBPAliases(accountID) {
+		sbpSwitch.RemoveAlias(alias.Phone, sbpMemberID)
+		log.Printf("Phone %s unlinked from SBP: account %s closed", alias.Phone, accountID)
+	}
+}
+
+// SBPLookupHandler показывает банки, в которых номер телефона зарегистрирован в СБП, и маскированное имя получателя
+func SBPLookupHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SBPLookupRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	phone, _ := NormalizePhone(req.Phone)
+	banks, err := sbpSwitch.Lookup(phone)
+	if err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, banks)
+}
+
+// SBPTransferResponse — результат перевода через СБП
+type SBPTransferResponse struct {
+	TransactionID string          `json:"transaction_id"`
+	BankID        string          `json:"bank_id"`
+	BankName      string          `json:"bank_name"`
+	MaskedName    string          `json:"masked_name"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+// SBPTransferHandler переводит деньги по номеру телефона в банк получателя: указанный
+// в запросе или банк по умолчанию. Перевод внутри банка проводится сразу; перевод в другой
+// банк списывается и возвращается на счёт, если банк получателя его не зачислил.
+func SBPTransferHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SBPTransferRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, req.Amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return
+	}
+	if fromAccount.OrganizationID != "" {
+		respondError(w, http.StatusUnprocessableEntity, "SBP transfers by phone are available for personal accounts only")
+		return
+	}
+
+	phone, _ := NormalizePhone(req.Phone)
+	banks, err := sbpSwitch.Lookup(phone)
+	if err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	var recipient SwitchRecipient
+	for _, bank := range banks {
+		if bank.MemberID == req.BankID || (req.BankID == "" && (bank.Default || len(banks) == 1)) {
+			recipient = bank
+			break
+		}
+	}
+	if recipient.MemberID == "" {
+		if req.BankID != "" {
+			respondError(w, http.StatusNotFound, "Recipient is not registered in the specified bank")
+			return
+		}
+		respondError(w, http.StatusConflict, "Recipient has no default bank; specify bank_id")
+		return
+	}
+
+	description := "SBP transfer to " + recipient.MaskedName
+	if comment := strings.TrimSpace(req.Comment); comment != "" {
+		description += ": " + comment
+	}
+	response := SBPTransferResponse{
+		BankID:     recipient.MemberID,
+		BankName:   recipient.MemberName,
+		MaskedName: recipient.MaskedName,
+		Amount:     req.Amount,
+	}
+
+	// Получатель в нашем банке: обычный перевод на привязанный к номеру счёт
+	if recipient.MemberID == sbpMemberID {
+		alias, ok := GetSBPAlias(phone)
+		if !ok {
+			respondSBPError(w, ErrSBPRecipientNotFound)
+			return
+		}
+		tx, err := ExecuteTransfer(fromAccount.ID, alias.AccountID, req.Amount, description)
+		if err != nil {
+			respondTransferError(w, err)
+			return
+		}
+		response.TransactionID = tx.ID
+		log.Printf("SBP transfer %s of %s from %s to account %s", tx.ID, req.Amount.String(), fromAccount.ID, alias.AccountID)
+		respondJSON(w, http.StatusCreated, response)
+		return
+	}
+
+	now := time.Now()
+	tx, err := DebitSBPTransfer(fromAccount.ID, recipient.MemberID, phone, req.Amount, description, now)
+	if err != nil {
+		respondTransferError(w, err)
+		return
+	}
+	sender, _ := GetUser(userID)
+	err = sbpSwitch.Send(SwitchTransfer{
+		ID:           tx.ID,
+		FromMemberID: sbpMemberID,
+		ToMemberID:   recipient.MemberID,
+		Phone:        phone,
+		SenderName:   maskRecipientName(sender),
+		Amount:       req.Amount,
+		Comment:      strings.TrimSpace(req.Comment),
+	})
+	if err != nil {
+		if _, returnErr := ReturnSBPTransfer(tx.ID, err.Error(), now); returnErr != nil {
+			log.Printf("Failed to return SBP transfer %s: %v", tx.ID, returnErr)
+		}
+		log.Printf("SBP transfer %s to %s in %s failed, amount returned: %v", tx.ID, phone, recipient.MemberID, err)
+		respondSBPError(w, err)
+		return
+	}
+
+	response.TransactionID = tx.ID
+	log.Printf("SBP transfer %s of %s from %s sent to %s", tx.ID, req.Amount.String(), fromAccount.ID, recipient.MemberID)
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// CreateSBPQRHandler создаёт QR-код СБП для оплаты мерчанту: статический многоразовый
+// или динамический на конкретную сумму с ограниченным сроком действия
+func CreateSBPQRHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateSBPQRRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	now := time.Now()
+	qr := SBPQRCode{
+		ID:         GenerateSBPQRID(),
+		MerchantID: merchant.ID,
+		Type:       req.Type,
+		Amount:     req.Amount,
+		Purpose:    strings.TrimSpace(req.Purpose),
+		Status:     SBPQRStatusActive,
+		CreatedAt:  now,
+	}
+	if qr.Type == SBPQRDynamic {
+		ttl := defaultSBPQRTTL
+		if req.TTLMinutes > 0 {
+			ttl = time.Duration(req.TTLMinutes) * time.Minute
+		}
+		expiresAt := now.Add(ttl)
+		qr.ExpiresAt = &expiresAt
+	}
+	qr.Payload = BuildSBPPayload(SBPPayload{QRID: qr.ID, Type: qr.Type, BankID: sbpMemberID, Amount: qr.Amount})
+	AddSBPQRCode(qr)
+
+	log.Printf("Merchant %s created %s SBP QR code %s", merchant.ID, qr.Type, qr.ID)
+	respondJSON(w, http.StatusCreated, qr)
+}
+
+func GetSBPQRHandler(w http.ResponseWriter, r *http.Request) {
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+	qr, ok := GetSBPQRCode(mux.Vars(r)["qrId"], time.Now())
+	if !ok || qr.MerchantID != merchant.ID {
+		respondError(w, http.StatusNotFound, "QR code not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, qr)
+}
+
+// SBPQRDetails — разобранный QR-код с данными мерчанта, если код выпущен нашим банком
+type SBPQRDetails struct {
+	SBPPayload
+	BankName     string `json:"bank_name,omitempty"`
+	MerchantName string `json:"merchant_name,omitempty"`
+	Purpose      string `json:"purpose,omitempty"`
+	Status       string `json:"status,omitempty"`
+	Payable      bool   `json:"payable"`
+}
+
+// parseSBPQR разбирает платёжную ссылку и дополняет её данными QR-кода нашего банка
+func parseSBPQR(payload string) (SBPQRDetails, SBPQRCode, error) {
+	parsed, err := ParseSBPPayload(payload)
+	if err != nil {
+		return SBPQRDetails{}, SBPQRCode{}, err
+	}
+	details := SBPQRDetails{SBPPayload: parsed, BankName: localSwitchMembers[parsed.BankID]}
+	if parsed.BankID != sbpMemberID {
+		return details, SBPQRCode{}, nil
+	}
+	details.BankName = bankRequisites.Name
+	qr, ok := GetSBPQRCode(parsed.QRID, time.Now())
+	if !ok {
+		return SBPQRDetails{}, SBPQRCode{}, ErrSBPQRNotFound
+	}
+	merchant, _ := GetMerchant(qr.MerchantID)
+	details.MerchantName, details.Purpose, details.Status = merchant.Name, qr.Purpose, qr.Status
+	details.Payable = qr.Status == SBPQRStatusActive
+	return details, qr, nil
+}
+
+// ParseSBPQRHandler разбирает отсканированный QR-код и показывает клиенту получателя и сумму перед оплатой
+func ParseSBPQRHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ParseSBPQRRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	details, _, err := parseSBPQR(req.Payload)
+	if err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, details)
+}
+
+// PaySBPQRHandler оплачивает QR-код мерчанта нашего банка. Сумма берётся из кода,
+// для статического кода без суммы её указывает плательщик.
+func PaySBPQRHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req PaySBPQRRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	details, qr, err := parseSBPQR(req.Payload)
+	if err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	if details.BankID != sbpMemberID {
+		respondError(w, http.StatusUnprocessableEntity, "QR codes of other banks are not supported")
+		return
+	}
+
+	amount := req.Amount
+	if qr.Amount.IsPositive() {
+		if !amount.IsZero() && !amount.Equal(qr.Amount) {
+			respondValidationError(w, ValidationErrors{{Field: "amount", Message: "must equal the QR code amount " + qr.Amount.String()}})
+			return
+		}
+		amount = qr.Amount
+	} else if !amount.IsPositive() {
+		respondValidationError(w, ValidationErrors{{Field: "amount", Message: "is required for a QR code without amount"}})
+		return
+	}
+
+	fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return
+	}
+	if RequiresDualApproval(fromAccount, amount) {
+		respondError(w, http.StatusConflict, "Payment requires a second signature; use a transfer instead")
+		return
+	}
+
+	tx, err := PaySBPQRCode(qr.ID, fromAccount.ID, amount, time.Now())
+	if err != nil {
+		respondSBPError(w, err)
+		return
+	}
+
+	log.Printf("SBP QR code %s paid from %s: %s", qr.ID, fromAccount.ID, amount.String())
+	respondJSON(w, http.StatusCreated, tx)
+}
+
+// SimulateSBPAliasHandler регистрирует номер телефона в другом банке-участнике локального операционного центра
+func SimulateSBPAliasHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SimulateSBPAliasRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if _, ok := sbpSwitch.(*LocalSwitch); !ok {
+		respondError(w, http.StatusConflict, "SBP simulator is not in use")
+		return
+	}
+	if req.BankID == sbpMemberID {
+		respondError(w, http.StatusUnprocessableEntity, "Use SBP settings to link phones in this bank")
+		return
+	}
+
+	phone, _ := NormalizePhone(req.Phone)
+	if err := sbpSwitch.RegisterAlias(phone, req.BankID, strings.TrimSpace(req.Name), req.Default); err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	banks, _ := sbpSwitch.Lookup(phone)
+	respondJSON(w, http.StatusOK, banks)
+}
+
+// SimulateSBPIncomingHandler отправляет через локальный операционный центр перевод из другого банка клиенту нашего банка
+func SimulateSBPIncomingHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req SimulateSBPIncomingRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if _, ok := sbpSwitch.(*LocalSwitch); !ok {
+		respondError(w, http.StatusConflict, "SBP simulator is not in use")
+		return
+	}
+
+	phone, _ := NormalizePhone(req.Phone)
+	transfer := SwitchTransfer{
+		ID:           GenerateID(),
+		FromMemberID: req.FromBankID,
+		ToMemberID:   sbpMemberID,
+		Phone:        phone,
+		SenderName:   strings.TrimSpace(req.SenderName),
+		Amount:       req.Amount,
+		Comment:      strings.TrimSpace(req.Comment),
+	}
+	if err := sbpSwitch.Send(transfer); err != nil {
+		respondSBPError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{"transfer_id": transfer.ID, "status": "credited"})
+}
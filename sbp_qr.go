@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Платёжная ссылка СБП: https://qr.nspk.ru/<QR ID>?type=02&bank=<ID участника>&sum=<копейки>&cur=RUB&crc=<CRC16>.
+// Сумма указывается для динамических кодов и статических с фиксированной суммой; crc — последний параметр,
+// CRC-16/CCITT-FALSE от всей строки перед «&crc=».
+const sbpQRHost = "qr.nspk.ru"
+
+// Значения параметра type платёжной ссылки
+const (
+	sbpQRTypeStatic  = "01"
+	sbpQRTypeDynamic = "02"
+)
+
+const sbpQRIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var (
+	sbpQRIDPattern     = regexp.MustCompile(`^[A-Z0-9]{32}$`)
+	ErrInvalidSBPQR    = errors.New("invalid SBP QR payload")
+	ErrSBPQRChecksum   = errors.New("SBP QR payload checksum mismatch")
+	ErrSBPQRNotFound   = errors.New("SBP QR code not found")
+	ErrSBPQRNotPayable = errors.New("SBP QR code is no longer payable")
+	ErrSBPQRExpired    = errors.New("SBP QR code has expired")
+)
+
+// SBPPayload — данные, закодированные в платёжной ссылке СБП
+type SBPPayload struct {
+	QRID     string          `json:"qr_id"`
+	Type     string          `json:"type"` // static | dynamic
+	BankID   string          `json:"bank_id"`
+	Amount   decimal.Decimal `json:"amount"` // 0 — сумму вводит плательщик
+	Currency string          `json:"currency,omitempty"`
+}
+
+// GenerateSBPQRID генерирует идентификатор QR-кода из 32 латинских букв и цифр
+func GenerateSBPQRID() string {
+	id := make([]byte, 32)
+	max := big.NewInt(int64(len(sbpQRIDAlphabet)))
+	for i := range id {
+		n, _ := rand.Int(rand.Reader, max)
+		id[i] = sbpQRIDAlphabet[n.Int64()]
+	}
+	return string(id)
+}
+
+// crc16CCITT считает CRC-16/CCITT-FALSE (полином 0x1021, начальное значение 0xFFFF)
+func crc16CCITT(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// BuildSBPPayload формирует платёжную ссылку для QR-кода
+func BuildSBPPayload(p SBPPayload) string {
+	qrType := sbpQRTypeStatic
+	if p.Type == SBPQRDynamic {
+		qrType = sbpQRTypeDynamic
+	}
+	payload := fmt.Sprintf("https://%s/%s?type=%s&bank=%s", sbpQRHost, p.QRID, qrType, p.BankID)
+	if p.Amount.IsPositive() {
+		payload += fmt.Sprintf("&sum=%d&cur=RUB", p.Amount.Shift(2).IntPart())
+	}
+	return payload + fmt.Sprintf("&crc=%04X", crc16CCITT(payload))
+}
+
+// ParseSBPPayload разбирает платёжную ссылку СБП и проверяет её контрольную сумму
+func ParseSBPPayload(payload string) (SBPPayload, error) {
+	payload = strings.TrimSpace(payload)
+	i := strings.LastIndex(payload, "&crc=")
+	if i < 0 {
+		return SBPPayload{}, fmt.Errorf("%w: crc is missing", ErrInvalidSBPQR)
+	}
+	crc, err := strconv.ParseUint(payload[i+len("&crc="):], 16, 16)
+	if err != nil {
+		return SBPPayload{}, fmt.Errorf("%w: malformed crc", ErrInvalidSBPQR)
+	}
+	if uint16(crc) != crc16CCITT(payload[:i]) {
+		return SBPPayload{}, ErrSBPQRChecksum
+	}
+
+	u, err := url.Parse(payload[:i])
+	if err != nil || u.Scheme != "https" || u.Host != sbpQRHost {
+		return SBPPayload{}, fmt.Errorf("%w: not an SBP payment link", ErrInvalidSBPQR)
+	}
+	p := SBPPayload{QRID: strings.TrimPrefix(u.Path, "/"), Amount: decimal.Zero}
+	if !sbpQRIDPattern.MatchString(p.QRID) {
+		return SBPPayload{}, fmt.Errorf("%w: malformed QR ID", ErrInvalidSBPQR)
+	}
+	query := u.Query()
+	switch query.Get("type") {
+	case sbpQRTypeStatic:
+		p.Type = SBPQRStatic
+	case sbpQRTypeDynamic:
+		p.Type = SBPQRDynamic
+	default:
+		return SBPPayload{}, fmt.Errorf("%w: unknown type %q", ErrInvalidSBPQR, query.Get("type"))
+	}
+	p.BankID = query.Get("bank")
+	if !sbpMemberIDPattern.MatchString(p.BankID) {
+		return SBPPayload{}, fmt.Errorf("%w: malformed bank", ErrInvalidSBPQR)
+	}
+	if sum := query.Get("sum"); sum != "" {
+		kopecks, err := strconv.ParseInt(sum, 10, 64)
+		if err != nil || kopecks <= 0 {
+			return SBPPayload{}, fmt.Errorf("%w: malformed sum", ErrInvalidSBPQR)
+		}
+		if p.Currency = query.Get("cur"); p.Currency != "RUB" {
+			return SBPPayload{}, fmt.Errorf("%w: unsupported currency %q", ErrInvalidSBPQR, p.Currency)
+		}
+		p.Amount = decimal.New(kopecks, -2)
+	}
+	if p.Type == SBPQRDynamic && !p.Amount.IsPositive() {
+		return SBPPayload{}, fmt.Errorf("%w: dynamic QR code without sum", ErrInvalidSBPQR)
+	}
+	return p, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint16
+	}{
+		{"", 0xFFFF},
+		{"123456789", 0x29B1},
+		{"A", 0xB915},
+	}
+
+	for _, tt := range tests {
+		if got := crc16CCITT(tt.in); got != tt.want {
+			t.Errorf("crc16CCITT(%q) = %04X, want %04X", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSBPPayloadRoundTrip(t *testing.T) {
+	const qrID = "AD10006M8KH234KD8RAPBP2E3TPFBL4F"
+	tests := []struct {
+		name    string
+		payload SBPPayload
+	}{
+		{"static without sum", SBPPayload{QRID: qrID, Type: SBPQRStatic, BankID: "100000000111", Amount: decimal.Zero}},
+		{"static with sum", SBPPayload{QRID: qrID, Type: SBPQRStatic, BankID: "100000000111", Amount: decimal.RequireFromString("150.50"), Currency: "RUB"}},
+		{"dynamic", SBPPayload{QRID: qrID, Type: SBPQRDynamic, BankID: "100000000004", Amount: decimal.RequireFromString("0.01"), Currency: "RUB"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := BuildSBPPayload(tt.payload)
+			got, err := ParseSBPPayload(link)
+			if err != nil {
+				t.Fatalf("ParseSBPPayload(%q): %v", link, err)
+			}
+			if got.QRID != tt.payload.QRID || got.Type != tt.payload.Type || got.BankID != tt.payload.BankID ||
+				!got.Amount.Equal(tt.payload.Amount) || got.Currency != tt.payload.Currency {
+				t.Errorf("ParseSBPPayload(%q) = %+v, want %+v", link, got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestParseSBPPayloadErrors(t *testing.T) {
+	const base = "https://qr.nspk.ru/AD10006M8KH234KD8RAPBP2E3TPFBL4F"
+	// sign дописывает верную контрольную сумму, чтобы проверялся разбор ссылки, а не crc
+	sign := func(link string) string {
+		return fmt.Sprintf("%s&crc=%04X", link, crc16CCITT(link))
+	}
+	valid := sign(base + "?type=02&bank=100000000111&sum=10000&cur=RUB")
+
+	tests := []struct {
+		name    string
+		payload string
+		want    error
+	}{
+		{"missing crc", base + "?type=02&bank=100000000111&sum=10000&cur=RUB", ErrInvalidSBPQR},
+		{"malformed crc", base + "?type=02&bank=100000000111&sum=10000&cur=RUB&crc=XYZ", ErrInvalidSBPQR},
+		{"tampered sum", strings.Replace(valid, "sum=10000", "sum=100", 1), ErrSBPQRChecksum},
+		{"other host", sign("https://example.com/AD10006M8KH234KD8RAPBP2E3TPFBL4F?type=01&bank=100000000111"), ErrInvalidSBPQR},
+		{"malformed QR ID", sign("https://qr.nspk.ru/ad10006?type=01&bank=100000000111"), ErrInvalidSBPQR},
+		{"unknown type", sign(base + "?type=03&bank=100000000111"), ErrInvalidSBPQR},
+		{"malformed bank", sign(base + "?type=01&bank=1000"), ErrInvalidSBPQR},
+		{"unsupported currency", sign(base + "?type=02&bank=100000000111&sum=10000&cur=USD"), ErrInvalidSBPQR},
+		{"dynamic without sum", sign(base + "?type=02&bank=100000000111"), ErrInvalidSBPQR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSBPPayload(tt.payload); !errors.Is(err, tt.want) {
+				t.Errorf("ParseSBPPayload(%q) error = %v, want %v", tt.payload, err, tt.want)
+			}
+		})
+	}
+	if _, err := ParseSBPPayload(valid); err != nil {
+		t.Errorf("ParseSBPPayload(%q): %v", valid, err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrSBPRecipientNotFound = errors.New("recipient is not registered in SBP")
+	ErrSBPUnknownMember     = errors.New("unknown SBP participant")
+	ErrSBPRejected          = errors.New("transfer rejected by recipient bank")
+)
+
+// SwitchTransfer — перевод между банками-участниками СБП по номеру телефона
+type SwitchTransfer struct {
+	ID           string
+	FromMemberID string
+	ToMemberID   string
+	Phone        string
+	SenderName   string
+	Amount       decimal.Decimal
+	Comment      string
+}
+
+// SwitchRecipient — банк, в котором номер телефона зарегистрирован в СБП
+type SwitchRecipient struct {
+	MemberID   string `json:"bank_id"`
+	MemberName string `json:"bank_name"`
+	MaskedName string `json:"masked_name"`
+	Default    bool   `json:"default"`
+}
+
+// SwitchReceiver зачисляет перевод, адресованный банку-участнику; ошибка означает отказ в зачислении
+type SwitchReceiver func(t SwitchTransfer) error
+
+// PaymentSwitch — операционный центр СБП: справочник номеров телефонов и маршрутизация переводов
+type PaymentSwitch interface {
+	// RegisterAlias регистрирует номер телефона в банке; isDefault делает банк основным для номера
+	RegisterAlias(phone, memberID, maskedName string, isDefault bool) error
+	RemoveAlias(phone, memberID string) error
+	// Lookup возвращает банки, в которых зарегистрирован номер; банк по умолчанию — первым
+	Lookup(phone string) ([]SwitchRecipient, error)
+	// Send передаёт перевод в банк получателя и возвращает результат его зачисления
+	Send(t SwitchTransfer) error
+}
+
+type switchAlias struct {
+	memberID   string
+	maskedName string
+	isDefault  bool
+}
+
+// LocalSwitch — операционный центр в памяти процесса. Банки, подключившие получателя через Connect,
+// зачисляют переводы сами; переводы в остальные участники из справочника считаются зачисленными.
+type LocalSwitch struct {
+	mu        sync.RWMutex
+	members   map[string]string         // key: ID участника -> наименование банка
+	aliases   map[string][]switchAlias  // key: телефон
+	receivers map[string]SwitchReceiver // key: ID участника
+}
+
+// Участники СБП, известные локальному операционному центру
+var localSwitchMembers = map[string]string{
+	"100000000111": "Сбербанк",
+	"100000000008": "Альфа-Банк",
+	"100000000004": "Т-Банк",
+	"100000000005": "ВТБ",
+}
+
+func NewLocalSwitch() *LocalSwitch {
+	s := &LocalSwitch{
+		members:   make(map[string]string, len(localSwitchMembers)),
+		aliases:   make(map[string][]switchAlias),
+		receivers: make(map[string]SwitchReceiver),
+	}
+	for id, name := range localSwitchMembers {
+		s.members[id] = name
+	}
+	return s
+}
+
+// Connect подключает банк к операционному центру
+func (s *LocalSwitch) Connect(memberID, name string, receiver SwitchReceiver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[memberID] = name
+	s.receivers[memberID] = receiver
+}
+
+func (s *LocalSwitch) RegisterAlias(phone, memberID, maskedName string, isDefault bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[memberID]; !ok {
+		return ErrSBPUnknownMember
+	}
+
+	aliases := make([]switchAlias, 0, len(s.aliases[phone])+1)
+	for _, a := range s.aliases[phone] {
+		if a.memberID == memberID {
+			continue
+		}
+		// Банк по умолчанию у номера может быть только один
+		if isDefault {
+			a.isDefault = false
+		}
+		aliases = append(aliases, a)
+	}
+	s.aliases[phone] = append(aliases, switchAlias{memberID: memberID, maskedName: maskedName, isDefault: isDefault})
+	return nil
+}
+
+func (s *LocalSwitch) RemoveAlias(phone, memberID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	aliases := s.aliases[phone]
+	for i, a := range aliases {
+		if a.memberID == memberID {
+			s.aliases[phone] = append(aliases[:i:i], aliases[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSBPRecipientNotFound
+}
+
+func (s *LocalSwitch) Lookup(phone string) ([]SwitchRecipient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	aliases := s.aliases[phone]
+	if len(aliases) == 0 {
+		return nil, ErrSBPRecipientNotFound
+	}
+	recipients := make([]SwitchRecipient, 0, len(aliases))
+	for _, a := range aliases {
+		recipients = append(recipients, SwitchRecipient{
+			MemberID:   a.memberID,
+			MemberName: s.members[a.memberID],
+			MaskedName: a.maskedName,
+			Default:    a.isDefault,
+		})
+	}
+	sort.SliceStable(recipients, func(i, j int) bool {
+		return recipients[i].Default && !recipients[j].Default
+	})
+	return recipients, nil
+}
+
+func (s *LocalSwitch) Send(t SwitchTransfer) error {
+	s.mu.RLock()
+	registered := false
+	for _, a := range s.aliases[t.Phone] {
+		registered = registered || a.memberID == t.ToMemberID
+	}
+	receiver := s.receivers[t.ToMemberID]
+	_, knownSender := s.members[t.FromMemberID]
+	s.mu.RUnlock()
+
+	if !knownSender {
+		return ErrSBPUnknownMember
+	}
+	if !registered {
+		return ErrSBPRecipientNotFound
+	}
+	if receiver == nil {
+		return nil
+	}
+	if err := receiver(t); err != nil {
+		return errors.Join(ErrSBPRejected, err)
+	}
+	return nil
+}
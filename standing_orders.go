@@ -0,0 +1,422 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Правила повтора при нехватке средств: до standingOrderMaxAttempts попыток с интервалом standingOrderRetryDelay,
+// но не позже следующего исполнения по расписанию
+const (
+	standingOrderMaxAttempts = 3
+	standingOrderRetryDelay  = 4 * time.Hour
+)
+
+const standingOrderDateLayout = "2006-01-02"
+
+// standingOrdersMu упорядочивает исполнение поручений и их изменение клиентом
+var standingOrdersMu sync.Mutex
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func daysInMonth(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
+
+// lastBusinessDay — последний будний день месяца (праздники не учитываются)
+func lastBusinessDay(year int, month time.Month, loc *time.Location) time.Time {
+	day := time.Date(year, month, daysInMonth(year, month, loc), 0, 0, 0, 0, loc)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// occurrenceOnOrAfter возвращает первую дату исполнения поручения не раньше from
+// или nil, если исполнений больше не будет
+func occurrenceOnOrAfter(order StandingOrder, from time.Time) *time.Time {
+	start := startOfDay(order.StartDate)
+	from = startOfDay(from)
+	if from.Before(start) {
+		from = start
+	}
+
+	var next time.Time
+	switch order.Frequency {
+	case StandingOrderOnce:
+		if from.After(start) {
+			return nil
+		}
+		next = start
+	case StandingOrderDaily:
+		next = from
+	case StandingOrderWeekly:
+		next = start
+		for next.Before(from) {
+			next = next.AddDate(0, 0, 7)
+		}
+	case StandingOrderMonthly, StandingOrderLastBusinessDay:
+		year, month := from.Year(), from.Month()
+		for {
+			if order.Frequency == StandingOrderMonthly {
+				day := order.DayOfMonth
+				if dim := daysInMonth(year, month, from.Location()); day > dim {
+					day = dim
+				}
+				next = time.Date(year, month, day, 0, 0, 0, 0, from.Location())
+			} else {
+				next = lastBusinessDay(year, month, from.Location())
+			}
+			if !next.Before(from) {
+				break
+			}
+			month++
+			if month > time.December {
+				month = time.January
+				year++
+			}
+		}
+	default:
+		return nil
+	}
+
+	if order.EndDate != nil && next.After(startOfDay(*order.EndDate)) {
+		return nil
+	}
+	return &next
+}
+
+// scheduleStandingOrder назначает ближайшее исполнение не раньше from и не раньше дня после последнего
+// исполненного, чтобы после возобновления или изменения поручения оплаченная дата не оплачивалась повторно.
+// Если исполнений больше нет, активное поручение завершается.
+func scheduleStandingOrder(order *StandingOrder, from time.Time) {
+	if order.LastExecutedDate != nil {
+		if after := order.LastExecutedDate.AddDate(0, 0, 1); from.Before(after) {
+			from = after
+		}
+	}
+	order.Attempt = 0
+	order.DueDate = occurrenceOnOrAfter(*order, from)
+	order.NextRunAt = order.DueDate
+	if order.DueDate == nil && order.Status == StandingOrderStatusActive {
+		order.Status = StandingOrderStatusCompleted
+	}
+}
+
+// ExecuteDueStandingOrders исполняет наступившие поручения; вызывается из планировщика
+func ExecuteDueStandingOrders(now time.Time) int {
+	standingOrdersMu.Lock()
+	defer standingOrdersMu.Unlock()
+
+	executed := 0
+	for _, order := range DueStandingOrders(now) {
+		if executeStandingOrder(order, now) {
+			executed++
+		}
+	}
+	return executed
+}
+
+// executeStandingOrder выполняет одну попытку перевода и записывает её в историю. Вызывается под standingOrdersMu.
+func executeStandingOrder(order StandingOrder, now time.Time) bool {
+	scheduled := *order.DueDate
+	order.Attempt++
+	exec := StandingOrderExecution{
+		ID:           GenerateID(),
+		OrderID:      order.ID,
+		ScheduledFor: scheduled,
+		ExecutedAt:   now,
+		Attempt:      order.Attempt,
+		Amount:       order.Amount,
+	}
+
+	description := order.Description
+	if description == "" {
+		description = fmt.Sprintf("Standing order %s", order.ID)
+	}
+	// Права автора на счёт списания могли быть отозваны или урезаны после создания поручения
+	_, err := AuthorizeAccount(order.FromAccountID, order.UserID, AccountPermissionPay, order.Amount)
+	var tx Transaction
+	if err == nil {
+		tx, err = ExecuteTransfer(order.FromAccountID, order.ToAccountID, order.Amount, description)
+	}
+	switch {
+	case err == nil:
+		exec.Status = ExecutionStatusSucceeded
+		exec.TransactionID = tx.ID
+		order.LastError = ""
+		order.LastExecutedDate = &scheduled
+		scheduleStandingOrder(&order, scheduled.AddDate(0, 0, 1))
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrLimitExceeded), errors.Is(err, ErrAccountFrozen):
+		// Средства могут поступить, дневной лимит — обновиться, а счёт — разморозиться, поэтому исполнение повторяется
+		exec.Error = err.Error()
+		order.LastError = err.Error()
+		retryAt := now.Add(standingOrderRetryDelay)
+		nextScheduled := occurrenceOnOrAfter(order, scheduled.AddDate(0, 0, 1))
+		if order.Attempt < standingOrderMaxAttempts && (nextScheduled == nil || retryAt.Before(*nextScheduled)) {
+			exec.Status = ExecutionStatusRetryScheduled
+			order.NextRunAt = &retryAt
+		} else {
+			// Попытки исчерпаны: это исполнение пропускается, поручение продолжает действовать по расписанию
+			exec.Status = ExecutionStatusFailed
+			scheduleStandingOrder(&order, scheduled.AddDate(0, 0, 1))
+		}
+	default:
+		// Счёт закрыт или не найден либо у автора нет права платить с него — повторять бессмысленно, поручение останавливается
+		exec.Status = ExecutionStatusFailed
+		exec.Error = err.Error()
+		order.LastError = err.Error()
+		order.Status = StandingOrderStatusFailed
+		order.DueDate = nil
+		order.NextRunAt = nil
+	}
+
+	order.UpdatedAt = now
+	AddStandingOrderExecution(exec)
+	SaveStandingOrder(order)
+	log.Printf("Standing order %s attempt %d: %s", order.ID, exec.Attempt, exec.Status)
+	return exec.Status == ExecutionStatusSucceeded
+}
+
+func parseStandingOrderDate(value string) (time.Time, error) {
+	return time.ParseInLocation(standingOrderDateLayout, value, time.Local)
+}
+
+// applyStandingOrderSchedule заполняет расписание поручения из запроса и вычисляет первое исполнение
+func applyStandingOrderSchedule(order *StandingOrder, frequency string, dayOfMonth int, startDate, endDate string, now time.Time) error {
+	start, err := parseStandingOrderDate(startDate)
+	if err != nil {
+		return err
+	}
+	order.Frequency = frequency
+	order.DayOfMonth = dayOfMonth
+	order.StartDate = start
+	order.EndDate = nil
+	if endDate != "" {
+		end, err := parseStandingOrderDate(endDate)
+		if err != nil {
+			return err
+		}
+		order.EndDate = &end
+	}
+
+	scheduleStandingOrder(order, now)
+	if order.DueDate == nil {
+		return errors.New("schedule has no future executions")
+	}
+	return nil
+}
+
+func CreateStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateStandingOrderRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	fromAccount, err := AuthorizeAccount(req.FromAccountID, userID, AccountPermissionPay, req.Amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", req.FromAccountID))
+		return
+	}
+	// Регулярный перевод исполняется без участия подписантов, поэтому вторая подпись для него невозможна
+	if RequiresDualApproval(fromAccount, req.Amount) {
+		respondError(w, http.StatusConflict, "Amount exceeds the organization's dual approval threshold")
+		return
+	}
+
+	recipient := TransferRecipient{AccountID: req.ToAccountID}
+	if req.To != "" {
+		var err error
+		if recipient, err = ResolveRecipient(req.To, req.ToType); err != nil {
+			respondError(w, http.StatusNotFound, "Recipient not found")
+			return
+		}
+	} else {
+		toAccount, ok := GetAccount(req.ToAccountID)
+		if !ok {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Destination account %s not found", req.ToAccountID))
+			return
+		}
+		user, _ := GetUser(toAccount.UserID)
+		recipient.MaskedName = maskRecipientName(user)
+		recipient.MaskedAccount = maskAccountNumber(toAccount.Number)
+	}
+	if recipient.AccountID == fromAccount.ID {
+		respondError(w, http.StatusBadRequest, "Source and destination accounts must differ")
+		return
+	}
+
+	now := time.Now()
+	order := StandingOrder{
+		ID:            GenerateID(),
+		UserID:        userID,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   recipient.AccountID,
+		Recipient:     recipient.MaskedAccount,
+		RecipientName: recipient.MaskedName,
+		Amount:        req.Amount,
+		Description:   req.Description,
+		Status:        StandingOrderStatusActive,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := applyStandingOrderSchedule(&order, req.Frequency, req.DayOfMonth, req.StartDate, req.EndDate, now); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid schedule: %v", err))
+		return
+	}
+
+	SaveStandingOrder(order)
+	log.Printf("Standing order %s created by user %s", order.ID, userID)
+	respondJSON(w, http.StatusCreated, order)
+}
+
+func GetStandingOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	respondJSON(w, http.StatusOK, GetUserStandingOrders(userID))
+}
+
+// GetAccountStandingOrdersHandler показывает владельцу счёта все поручения с него, в том числе созданные доверенными лицами
+func GetAccountStandingOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	account, _, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, GetAccountStandingOrders(account.ID))
+}
+
+// ownedStandingOrder возвращает поручение текущего пользователя или отвечает 404
+func ownedStandingOrder(w http.ResponseWriter, r *http.Request) (StandingOrder, bool) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return StandingOrder{}, false
+	}
+	order, ok := GetStandingOrder(mux.Vars(r)["orderId"])
+	if !ok || order.UserID != userID {
+		respondError(w, http.StatusNotFound, "Standing order not found")
+		return StandingOrder{}, false
+	}
+	return order, true
+}
+
+func GetStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if order, ok := ownedStandingOrder(w, r); ok {
+		respondJSON(w, http.StatusOK, order)
+	}
+}
+
+func GetStandingOrderExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	if order, ok := ownedStandingOrder(w, r); ok {
+		respondJSON(w, http.StatusOK, GetStandingOrderExecutions(order.ID))
+	}
+}
+
+// UpdateStandingOrderHandler меняет сумму и расписание; незавершённые повторы текущего исполнения сбрасываются
+func UpdateStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req UpdateStandingOrderRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	standingOrdersMu.Lock()
+	defer standingOrdersMu.Unlock()
+
+	order, ok := ownedStandingOrder(w, r)
+	if !ok {
+		return
+	}
+	if order.Status != StandingOrderStatusActive && order.Status != StandingOrderStatusPaused {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Standing order is %s", order.Status))
+		return
+	}
+
+	fromAccount, err := AuthorizeAccount(order.FromAccountID, order.UserID, AccountPermissionPay, req.Amount)
+	if err != nil {
+		respondAccountAccessError(w, err, fmt.Sprintf("Source account %s not found", order.FromAccountID))
+		return
+	}
+	if RequiresDualApproval(fromAccount, req.Amount) {
+		respondError(w, http.StatusConflict, "Amount exceeds the organization's dual approval threshold")
+		return
+	}
+
+	now := time.Now()
+	order.Amount = req.Amount
+	order.Description = req.Description
+	if err := applyStandingOrderSchedule(&order, req.Frequency, req.DayOfMonth, req.StartDate, req.EndDate, now); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid schedule: %v", err))
+		return
+	}
+	order.UpdatedAt = now
+
+	SaveStandingOrder(order)
+	respondJSON(w, http.StatusOK, order)
+}
+
+func PauseStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	setStandingOrderStatus(w, r, StandingOrderStatusActive, StandingOrderStatusPaused)
+}
+
+func ResumeStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	setStandingOrderStatus(w, r, StandingOrderStatusPaused, StandingOrderStatusActive)
+}
+
+func CancelStandingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	setStandingOrderStatus(w, r, "", StandingOrderStatusCancelled)
+}
+
+// setStandingOrderStatus переводит поручение из статуса from (пусто — из active или paused) в статус to
+func setStandingOrderStatus(w http.ResponseWriter, r *http.Request, from, to string) {
+	standingOrdersMu.Lock()
+	defer standingOrdersMu.Unlock()
+
+	order, ok := ownedStandingOrder(w, r)
+	if !ok {
+		return
+	}
+	allowed := order.Status == from
+	if from == "" {
+		allowed = order.Status == StandingOrderStatusActive || order.Status == StandingOrderStatusPaused
+	}
+	if !allowed {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Standing order is %s", order.Status))
+		return
+	}
+
+	now := time.Now()
+	order.Status = to
+	order.UpdatedAt = now
+	switch to {
+	case StandingOrderStatusActive:
+		// Исполнения, пропущенные на паузе, не навёрстываются
+		scheduleStandingOrder(&order, now)
+	case StandingOrderStatusCancelled:
+		order.DueDate = nil
+		order.NextRunAt = nil
+	}
+
+	SaveStandingOrder(order)
+	log.Printf("Standing order %s is now %s", order.ID, order.Status)
+	respondJSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func localDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestOccurrenceOnOrAfter(t *testing.T) {
+	end := localDate(2026, time.March, 31)
+	tests := []struct {
+		name  string
+		order StandingOrder
+		from  time.Time
+		want  *time.Time
+	}{
+		{"once before start", StandingOrder{Frequency: StandingOrderOnce, StartDate: localDate(2026, time.May, 10)}, localDate(2026, time.May, 1), ptr(localDate(2026, time.May, 10))},
+		{"once on start", StandingOrder{Frequency: StandingOrderOnce, StartDate: localDate(2026, time.May, 10)}, localDate(2026, time.May, 10).Add(15 * time.Hour), ptr(localDate(2026, time.May, 10))},
+		{"once after start", StandingOrder{Frequency: StandingOrderOnce, StartDate: localDate(2026, time.May, 10)}, localDate(2026, time.May, 11), nil},
+		{"daily", StandingOrder{Frequency: StandingOrderDaily, StartDate: localDate(2026, time.May, 1)}, localDate(2026, time.May, 7).Add(9 * time.Hour), ptr(localDate(2026, time.May, 7))},
+		{"weekly keeps weekday of start", StandingOrder{Frequency: StandingOrderWeekly, StartDate: localDate(2026, time.May, 4)}, localDate(2026, time.May, 6), ptr(localDate(2026, time.May, 11))},
+		{"weekly on occurrence", StandingOrder{Frequency: StandingOrderWeekly, StartDate: localDate(2026, time.May, 4)}, localDate(2026, time.May, 18), ptr(localDate(2026, time.May, 18))},
+		{"monthly this month", StandingOrder{Frequency: StandingOrderMonthly, DayOfMonth: 15, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.May, 10), ptr(localDate(2026, time.May, 15))},
+		{"monthly next month", StandingOrder{Frequency: StandingOrderMonthly, DayOfMonth: 15, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.May, 16), ptr(localDate(2026, time.June, 15))},
+		{"monthly clamps to short month", StandingOrder{Frequency: StandingOrderMonthly, DayOfMonth: 31, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.February, 1), ptr(localDate(2026, time.February, 28))},
+		{"monthly crosses year", StandingOrder{Frequency: StandingOrderMonthly, DayOfMonth: 5, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.December, 6), ptr(localDate(2027, time.January, 5))},
+		{"last business day skips weekend", StandingOrder{Frequency: StandingOrderLastBusinessDay, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.May, 1), ptr(localDate(2026, time.May, 29))},
+		{"last business day next month", StandingOrder{Frequency: StandingOrderLastBusinessDay, StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.May, 30), ptr(localDate(2026, time.June, 30))},
+		{"not before start date", StandingOrder{Frequency: StandingOrderDaily, StartDate: localDate(2026, time.June, 1)}, localDate(2026, time.May, 1), ptr(localDate(2026, time.June, 1))},
+		{"on end date", StandingOrder{Frequency: StandingOrderDaily, StartDate: localDate(2026, time.January, 1), EndDate: &end}, localDate(2026, time.March, 31), ptr(end)},
+		{"after end date", StandingOrder{Frequency: StandingOrderMonthly, DayOfMonth: 15, StartDate: localDate(2026, time.January, 1), EndDate: &end}, localDate(2026, time.March, 16), nil},
+		{"unknown frequency", StandingOrder{Frequency: "yearly", StartDate: localDate(2026, time.January, 1)}, localDate(2026, time.May, 1), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrenceOnOrAfter(tt.order, tt.from)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("occurrenceOnOrAfter(from %s) = %v, want %v", tt.from.Format(standingOrderDateLayout), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleStandingOrderSkipsExecutedDate(t *testing.T) {
+	today := localDate(2026, time.May, 15)
+	yesterday := localDate(2026, time.May, 14)
+	tests := []struct {
+		name         string
+		frequency    string
+		lastExecuted *time.Time
+		want         *time.Time
+	}{
+		{"daily not executed today", StandingOrderDaily, &yesterday, ptr(today)},
+		{"daily already executed today", StandingOrderDaily, &today, ptr(localDate(2026, time.May, 16))},
+		{"monthly already executed today", StandingOrderMonthly, &today, ptr(localDate(2026, time.June, 15))},
+		{"once already executed", StandingOrderOnce, &today, nil},
+		{"never executed", StandingOrderMonthly, nil, ptr(today)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := StandingOrder{
+				Frequency:        tt.frequency,
+				DayOfMonth:       15,
+				StartDate:        today,
+				Status:           StandingOrderStatusActive,
+				Attempt:          2,
+				LastExecutedDate: tt.lastExecuted,
+			}
+			scheduleStandingOrder(&order, today.Add(10*time.Hour))
+			if order.Attempt != 0 {
+				t.Errorf("Attempt = %d, want reset to 0", order.Attempt)
+			}
+			switch {
+			case order.DueDate == nil && tt.want == nil:
+				if order.Status != StandingOrderStatusCompleted {
+					t.Errorf("Status = %s, want %s", order.Status, StandingOrderStatusCompleted)
+				}
+			case order.DueDate == nil || tt.want == nil || !order.DueDate.Equal(*tt.want):
+				t.Errorf("DueDate = %v, want %v", order.DueDate, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
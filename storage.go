@@ -3,40 +3,180 @@ package main
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type InMemoryStorage struct {
-	users        map[string]User     // key: UserID
-	accounts     map[string]Account  // key: AccountID
-	cards        map[string]Card     // key: CardID
-	loans        map[string]Loan     // key: LoanID
-	transactions []Transaction       // список всех транзакций
-	userIndex    map[string]string   // key: Username -> UserID 
-	emailIndex   map[string]string   // key: Email -> UserID
-	accountIndex map[string][]string // key: UserID -> []AccountID
-	cardIndex    map[string][]string // key: AccountID -> []CardID
-	loanIndex    map[string][]string // key: UserID -> []LoanID
-	mu           sync.RWMutex        // Mutex для защиты доступа к данным
+	users                   map[string]User                     // key: UserID
+	accounts                map[string]Account                  // key: AccountID
+	cards                   map[string]Card                     // key: CardID
+	loans                   map[string]Loan                     // key: LoanID
+	merchants               map[string]Merchant                 // key: MerchantID
+	authorizations          map[string]CardAuthorization        // key: AuthorizationID
+	transactions            []Transaction                       // список всех транзакций
+	transactionIndex        map[string]int                      // key: TransactionID -> индекс в transactions
+	userIndex               map[string]string                   // key: Username -> UserID
+	emailIndex              map[string]string                   // key: Email -> UserID
+	accountIndex            map[string][]string                 // key: UserID -> []AccountID
+	cardIndex               map[string][]string                 // key: AccountID -> []CardID
+	loanIndex               map[string][]string                 // key: UserID -> []LoanID
+	usedTokens              map[string]time.Time                // key: TokenID -> время истечения использованного токена
+	merchantKeyIndex        map[string]string                   // key: APIKey -> MerchantID
+	merchantUserIndex       map[string][]string                 // key: UserID -> []MerchantID
+	disputes                map[string]Dispute                  // key: DisputeID
+	paymentDisputeIndex     map[string][]string                 // key: TransactionID оплаты -> []DisputeID
+	refundedAmounts         map[string]decimal.Decimal          // key: TransactionID оплаты -> сумма возвратов по ней
+	panIndex                map[string]string                   // key: PANFingerprint -> CardID
+	acceptorIndex           map[string]string                   // key: AcceptorID (поле 42 ISO 8583) -> MerchantID
+	cardTokens              map[string]CardToken                // key: CardTokenID
+	tokenValueIndex         map[string]string                   // key: значение токена -> CardTokenID
+	cardTokenIndex          map[string][]string                 // key: CardID -> []CardTokenID
+	phoneIndex              map[string]string                   // key: Phone -> UserID, только подтверждённые номера
+	phoneVerifications      map[string]PhoneVerification        // key: UserID -> ожидающий ввода код подтверждения телефона
+	accountNumberIndex      map[string]string                   // key: Account.Number -> AccountID
+	standingOrders          map[string]StandingOrder            // key: StandingOrderID
+	standingOrderExecutions map[string][]StandingOrderExecution // key: StandingOrderID -> история исполнений
+	reversals               map[string]string                   // key: TransactionID исходной -> ID сторнирующей транзакции
+	limitUsage              map[limitUsageKey]decimal.Decimal   // нарастающие итоги операций для проверки лимитов
+	memberships             map[string]AccountMembership        // key: MembershipID
+	accountMemberIndex      map[string][]string                 // key: AccountID -> []MembershipID
+	userMembershipIndex     map[string][]string                 // key: UserID -> []MembershipID
+	organizations           map[string]Organization             // key: OrganizationID
+	innIndex                map[string]string                   // key: INN -> OrganizationID
+	organizationAccounts    map[string][]string                 // key: OrganizationID -> []AccountID
+	userOrganizationIndex   map[string][]string                 // key: UserID подписанта -> []OrganizationID
+	paymentApprovals        map[string]PaymentApproval          // key: PaymentApprovalID
+	externalPayments        map[string]ExternalPayment          // key: ExternalPaymentID
+	externalPaymentIndex    map[string][]string                 // key: AccountID -> []ExternalPaymentID
+	clearingBatches         map[string]ClearingBatch            // key: ClearingBatchID
+	incomingPayments        []IncomingPayment                   // поступления из других банков в порядке получения
+	lastEDNo                int                                 // последний присвоенный номер электронного документа
+	sbpAliases              map[string]SBPAlias                 // key: Phone
+	sbpQRCodes              map[string]SBPQRCode                // key: QR ID
+	billers                 map[string]Biller                   // key: BillerID
+	billPayments            map[string]BillPayment              // key: BillPaymentID
+	billPaymentIndex        map[string][]string                 // key: UserID -> []BillPaymentID
+	billTemplates           map[string]BillTemplate             // key: BillTemplateID
+	lastReceiptNo           int                                 // последний номер квитанции об оплате услуг
+	mu                      sync.RWMutex                        // Mutex для защиты доступа к данным
 }
 
 var storage *InMemoryStorage
 
+var (
+	ErrInsufficientFunds           = errors.New("insufficient funds")
+	ErrAuthorizationNotFound       = errors.New("authorization not found")
+	ErrAuthorizationClosed         = errors.New("authorization is no longer open")
+	ErrCaptureExceedsAuthorization = errors.New("capture amount exceeds remaining authorized amount")
+	ErrTransactionNotFound         = errors.New("transaction not found")
+	ErrRefundExceedsOriginal       = errors.New("amount exceeds the refundable remainder of the original transaction")
+	ErrDisputeNotFound             = errors.New("dispute not found")
+	ErrDisputeAlreadyOpen          = errors.New("transaction already has an open dispute")
+	ErrInvalidDisputeState         = errors.New("operation not allowed in current dispute state")
+	ErrPINNotSet                   = errors.New("PIN is not set")
+	ErrPINBlocked                  = errors.New("PIN is blocked")
+	ErrIncorrectPIN                = errors.New("incorrect PIN")
+	ErrCardTokenNotFound           = errors.New("card token not found")
+	ErrCardTokenDeleted            = errors.New("card token is deleted")
+	ErrCardNotFound                = errors.New("card not found")
+	ErrPhoneCodeExpired            = errors.New("phone confirmation code is missing or expired")
+	ErrIncorrectPhoneCode          = errors.New("incorrect phone confirmation code")
+	ErrPhoneTaken                  = errors.New("phone is already confirmed by another user")
+	ErrCardAlreadyClosed           = errors.New("card is already closed")
+	ErrAccountNotFound             = errors.New("account not found")
+	ErrSameAccount                 = errors.New("source and destination accounts must differ")
+	ErrOverdraftLimitExceeded      = fmt.Errorf("%w: overdraft limit exceeded", ErrInsufficientFunds)
+	ErrAccountFrozen               = errors.New("account is frozen")
+	ErrAccountClosed               = errors.New("account is closed")
+	ErrFundsArrested               = fmt.Errorf("%w: funds are under arrest", ErrInsufficientFunds)
+	ErrAccountNotEmpty             = errors.New("account balance must be zero or swept to another account")
+	ErrAccountHasHolds             = errors.New("account has pending card authorizations")
+	ErrInvalidAccountStatus        = errors.New("status change not allowed")
+	ErrMembershipNotFound          = errors.New("account membership not found")
+	ErrAlreadyMember               = errors.New("user already has access to the account")
+	ErrInvalidMembershipState      = errors.New("operation not allowed in current membership state")
+	ErrOrganizationNotFound        = errors.New("organization not found")
+	ErrOrganizationExists          = errors.New("organization with this INN is already registered")
+	ErrAlreadySigner               = errors.New("user is already a signer of the organization")
+	ErrNotSigner                   = errors.New("user is not a signer of the organization")
+	ErrLastSigner                  = errors.New("organization must have at least one signer")
+	ErrApprovalNotFound            = errors.New("payment approval not found")
+	ErrInvalidApprovalState        = errors.New("payment is no longer awaiting approval")
+	ErrAlreadyApproved             = errors.New("payment is already signed by this user")
+	ErrAccountHasPendingPayments   = errors.New("account has payments to other banks awaiting clearing")
+	ErrExternalPaymentNotFound     = errors.New("external payment not found")
+	ErrInvalidExternalPaymentState = errors.New("operation not allowed in current payment state")
+	ErrClearingBatchNotFound       = errors.New("clearing batch not found")
+	ErrNothingToClear              = errors.New("no payments queued for clearing")
+	ErrBatchAlreadyProcessed       = errors.New("clearing batch is already processed")
+	ErrInvalidAccountKey           = errors.New("account number control key does not match bank BIK")
+	ErrAlreadyReversed             = errors.New("transaction has already been reversed")
+	ErrNotReversible               = errors.New("transaction type cannot be reversed")
+	ErrBillerNotFound              = errors.New("biller not found")
+	ErrBillerInactive              = errors.New("biller is not accepting payments")
+	ErrBillTemplateNotFound        = errors.New("bill template not found")
+)
+
+// Число неверных попыток ввода PIN, после которого PIN блокируется
+const maxPINAttempts = 3
+
 func InitStorage() {
 	storage = &InMemoryStorage{
-		users:        make(map[string]User),
-		accounts:     make(map[string]Account),
-		cards:        make(map[string]Card),
-		loans:        make(map[string]Loan),
-		transactions: make([]Transaction, 0),
-		userIndex:    make(map[string]string),
-		emailIndex:   make(map[string]string),
-		accountIndex: make(map[string][]string),
-		cardIndex:    make(map[string][]string),
-		loanIndex:    make(map[string][]string),
+		users:                   make(map[string]User),
+		accounts:                make(map[string]Account),
+		cards:                   make(map[string]Card),
+		loans:                   make(map[string]Loan),
+		merchants:               make(map[string]Merchant),
+		authorizations:          make(map[string]CardAuthorization),
+		transactions:            make([]Transaction, 0),
+		transactionIndex:        make(map[string]int),
+		userIndex:               make(map[string]string),
+		emailIndex:              make(map[string]string),
+		accountIndex:            make(map[string][]string),
+		cardIndex:               make(map[string][]string),
+		loanIndex:               make(map[string][]string),
+		usedTokens:              make(map[string]time.Time),
+		merchantKeyIndex:        make(map[string]string),
+		merchantUserIndex:       make(map[string][]string),
+		disputes:                make(map[string]Dispute),
+		paymentDisputeIndex:     make(map[string][]string),
+		refundedAmounts:         make(map[string]decimal.Decimal),
+		panIndex:                make(map[string]string),
+		acceptorIndex:           make(map[string]string),
+		cardTokens:              make(map[string]CardToken),
+		tokenValueIndex:         make(map[string]string),
+		cardTokenIndex:          make(map[string][]string),
+		phoneIndex:              make(map[string]string),
+		phoneVerifications:      make(map[string]PhoneVerification),
+		accountNumberIndex:      make(map[string]string),
+		standingOrders:          make(map[string]StandingOrder),
+		standingOrderExecutions: make(map[string][]StandingOrderExecution),
+		reversals:               make(map[string]string),
+		limitUsage:              make(map[limitUsageKey]decimal.Decimal),
+		memberships:             make(map[string]AccountMembership),
+		accountMemberIndex:      make(map[string][]string),
+		userMembershipIndex:     make(map[string][]string),
+		organizations:           make(map[string]Organization),
+		innIndex:                make(map[string]string),
+		organizationAccounts:    make(map[string][]string),
+		userOrganizationIndex:   make(map[string][]string),
+		paymentApprovals:        make(map[string]PaymentApproval),
+		externalPayments:        make(map[string]ExternalPayment),
+		externalPaymentIndex:    make(map[string][]string),
+		clearingBatches:         make(map[string]ClearingBatch),
+		incomingPayments:        make([]IncomingPayment, 0),
+		sbpAliases:              make(map[string]SBPAlias),
+		sbpQRCodes:              make(map[string]SBPQRCode),
+		billers:                 make(map[string]Biller),
+		billPayments:            make(map[string]BillPayment),
+		billPaymentIndex:        make(map[string][]string),
+		billTemplates:           make(map[string]BillTemplate),
 	}
 }
 
@@ -50,7 +190,11 @@ func AddUser(user User) error {
 	if _, exists := storage.emailIndex[user.Email]; exists {
 		return fmt.Errorf("email '%s' already registered", user.Email)
 	}
+	if _, exists := storage.phoneIndex[user.Phone]; exists && user.Phone != "" {
+		return fmt.Errorf("phone '%s' already registered", user.Phone)
+	}
 
+	// Телефон попадает в индекс поиска получателей только после подтверждения кодом из SMS
 	storage.users[user.ID] = user
 	storage.userIndex[user.Username] = user.ID
 	storage.emailIndex[user.Email] = user.ID
@@ -68,15 +212,186 @@ func GetUserByUsername(username string) (User, bool) {
 	return user, ok
 }
 
-func AddAccount(account Account) error {
+func GetUser(userID string) (User, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	user, ok := storage.users[userID]
+	return user, ok
+}
+
+func GetUserByEmail(email string) (User, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	userID, ok := storage.emailIndex[email]
+	if !ok {
+		return User{}, false
+	}
+	user, ok := storage.users[userID]
+	return user, ok
+}
+
+func UpdateUserPassword(userID, passwordHash string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	user, ok := storage.users[userID]
+	if !ok {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = time.Now()
+	storage.users[userID] = user
+	return nil
+}
+
+func MarkEmailVerified(userID string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	user, ok := storage.users[userID]
+	if !ok {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	user.EmailVerified = true
+	storage.users[userID] = user
+	return nil
+}
+
+// SavePhoneVerification сохраняет код подтверждения телефона, заменяя ранее отправленный
+func SavePhoneVerification(v PhoneVerification) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.phoneVerifications[v.UserID] = v
+}
+
+func GetPhoneVerification(userID string) (PhoneVerification, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	v, ok := storage.phoneVerifications[userID]
+	return v, ok
+}
+
+// ConfirmPhone сверяет хеш кода с отправленным и при совпадении отмечает телефон подтверждённым,
+// добавляя его в индекс поиска получателей. После maxPhoneCodeAttempts неверных попыток код аннулируется.
+func ConfirmPhone(userID, codeHash string, now time.Time) (User, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	v, ok := storage.phoneVerifications[userID]
+	if !ok || now.After(v.ExpiresAt) {
+		delete(storage.phoneVerifications, userID)
+		return User{}, ErrPhoneCodeExpired
+	}
+	user, ok := storage.users[userID]
+	if !ok || user.Phone != v.Phone {
+		delete(storage.phoneVerifications, userID)
+		return User{}, ErrPhoneCodeExpired
+	}
+	if !hmac.Equal([]byte(v.CodeHash), []byte(codeHash)) {
+		v.Attempts++
+		if v.Attempts >= maxPhoneCodeAttempts {
+			delete(storage.phoneVerifications, userID)
+		} else {
+			storage.phoneVerifications[userID] = v
+		}
+		return User{}, ErrIncorrectPhoneCode
+	}
+	if ownerID, exists := storage.phoneIndex[user.Phone]; exists && ownerID != userID {
+		return User{}, ErrPhoneTaken
+	}
+
+	delete(storage.phoneVerifications, userID)
+	user.PhoneVerified = true
+	storage.users[userID] = user
+	storage.phoneIndex[user.Phone] = userID
+	return user, nil
+}
+
+// ConsumeActionToken помечает токен использованным; возвращает false при повторном использовании
+func ConsumeActionToken(tokenID string, expiresAt time.Time) bool {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range storage.usedTokens {
+		if now.After(exp) {
+			delete(storage.usedTokens, id)
+		}
+	}
+
+	if _, used := storage.usedTokens[tokenID]; used {
+		return false
+	}
+	storage.usedTokens[tokenID] = expiresAt
+	return true
+}
+
+// Сколько раз AddAccount генерирует новый номер, если сгенерированный уже занят
+const accountNumberAttempts = 10
+
+// AddAccount сохраняет счёт. Если его номер уже занят, генерируется новый номер на том же
+// балансовом счёте. Возвращает сохранённый счёт.
+func AddAccount(account Account) (Account, error) {
 	storage.mu.Lock()
 	defer storage.mu.Unlock()
 	if _, exists := storage.users[account.UserID]; !exists {
-		return fmt.Errorf("user with ID %s not found", account.UserID)
+		return Account{}, fmt.Errorf("user with ID %s not found", account.UserID)
+	}
+	for attempt := 0; account.Number != ""; attempt++ {
+		if _, exists := storage.accountNumberIndex[account.Number]; !exists {
+			break
+		}
+		if attempt == accountNumberAttempts {
+			return Account{}, fmt.Errorf("account number %s already exists", account.Number)
+		}
+		account.Number = GenerateAccountNumber(account.Number[:5])
+	}
+	// Счета организации числятся за организацией, а не за открывшим их подписантом
+	if account.OrganizationID != "" {
+		if _, exists := storage.organizations[account.OrganizationID]; !exists {
+			return Account{}, fmt.Errorf("%w: %s", ErrOrganizationNotFound, account.OrganizationID)
+		}
+		storage.organizationAccounts[account.OrganizationID] = append(storage.organizationAccounts[account.OrganizationID], account.ID)
+	} else {
+		storage.accountIndex[account.UserID] = append(storage.accountIndex[account.UserID], account.ID)
 	}
 	storage.accounts[account.ID] = account
-	storage.accountIndex[account.UserID] = append(storage.accountIndex[account.UserID], account.ID)
-	return nil
+	if account.Number != "" {
+		storage.accountNumberIndex[account.Number] = account.ID
+	}
+	return account, nil
+}
+
+func GetAccountByNumber(number string) (Account, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	id, ok := storage.accountNumberIndex[number]
+	if !ok {
+		return Account{}, false
+	}
+	account, ok := storage.accounts[id]
+	return account, ok
+}
+
+func GetUserByPhone(phone string) (User, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	id, ok := storage.phoneIndex[phone]
+	if !ok {
+		return User{}, false
+	}
+	user, ok := storage.users[id]
+	return user, ok
+}
+
+// GetPrimaryAccount возвращает первый открытый счёт пользователя — на него зачисляются переводы по телефону
+func GetPrimaryAccount(userID string) (Account, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	for _, id := range storage.accountIndex[userID] {
+		if account, ok := storage.accounts[id]; ok && account.Status != AccountStatusClosed {
+			return account, true
+		}
+	}
+	return Account{}, false
 }
 
 func GetAccount(accountID string) (Account, bool) {
@@ -86,6 +401,8 @@ func GetAccount(accountID string) (Account, bool) {
 	return acc, ok
 }
 
+// GetUserAccounts возвращает собственные счета пользователя, счета организаций, где он подписант,
+// и счета, к которым у него есть активный доступ
 func GetUserAccounts(userID string) []Account {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
@@ -96,129 +413,2500 @@ func GetUserAccounts(userID string) []Account {
 			accounts = append(accounts, acc)
 		}
 	}
+	for _, orgID := range storage.userOrganizationIndex[userID] {
+		for _, id := range storage.organizationAccounts[orgID] {
+			accounts = append(accounts, storage.accounts[id])
+		}
+	}
+	for _, id := range storage.userMembershipIndex[userID] {
+		m := storage.memberships[id]
+		if acc, ok := storage.accounts[m.AccountID]; ok && m.Status == MembershipStatusActive {
+			accounts = append(accounts, acc)
+		}
+	}
 	return accounts
 }
 
-func UpdateAccountBalance(accountID string, amount decimal.Decimal) error {
+// accountHolder сообщает, является ли пользователь владельцем счёта. Владельцы счёта
+// организации — её подписанты. Вызывается под storage.mu.
+func accountHolder(acc Account, userID string) bool {
+	if acc.OrganizationID != "" {
+		return isSigner(storage.organizations[acc.OrganizationID], userID)
+	}
+	return acc.UserID == userID
+}
+
+// IsAccountHolder возвращает счёт, если пользователь — его владелец
+func IsAccountHolder(accountID, userID string) (Account, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	acc, ok := storage.accounts[accountID]
+	if !ok || !accountHolder(acc, userID) {
+		return Account{}, false
+	}
+	return acc, true
+}
+
+// accountAccess возвращает права пользователя на счёт; у владельца они полные. Вызывается под storage.mu.
+func accountAccess(acc Account, userID string) (AccountMembership, bool) {
+	if accountHolder(acc, userID) {
+		return AccountMembership{AccountID: acc.ID, UserID: userID, Permission: AccountPermissionFull, Status: MembershipStatusActive}, true
+	}
+	for _, id := range storage.accountMemberIndex[acc.ID] {
+		if m := storage.memberships[id]; m.UserID == userID && m.Status == MembershipStatusActive {
+			return m, true
+		}
+	}
+	return AccountMembership{}, false
+}
+
+// AddAccountMembership сохраняет приглашение; у пользователя не должно быть другого доступа к счёту
+func AddAccountMembership(m AccountMembership) error {
 	storage.mu.Lock()
 	defer storage.mu.Unlock()
 
-	acc, ok := storage.accounts[accountID]
+	acc, ok := storage.accounts[m.AccountID]
 	if !ok {
-		return fmt.Errorf("account %s not found", accountID)
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, m.AccountID)
 	}
-
-	newBalance := acc.Balance.Add(amount)
-	if newBalance.IsNegative() {
+	if accountHolder(acc, m.UserID) {
+		return ErrAlreadyMember
+	}
+	for _, id := range storage.accountMemberIndex[acc.ID] {
+		existing := storage.memberships[id]
+		if existing.UserID == m.UserID && (existing.Status == MembershipStatusInvited || existing.Status == MembershipStatusActive) {
+			return ErrAlreadyMember
+		}
 	}
 
-	acc.Balance = newBalance
-	storage.accounts[accountID] = acc
+	storage.memberships[m.ID] = m
+	storage.accountMemberIndex[m.AccountID] = append(storage.accountMemberIndex[m.AccountID], m.ID)
+	storage.userMembershipIndex[m.UserID] = append(storage.userMembershipIndex[m.UserID], m.ID)
 	return nil
 }
 
-func AddTransaction(tx Transaction) {
-	storage.mu.Lock()
-	defer storage.mu.Unlock()
-	storage.transactions = append(storage.transactions, tx)
+func GetAccountMembership(membershipID string) (AccountMembership, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	m, ok := storage.memberships[membershipID]
+	return m, ok
 }
 
-func GetAccountTransactions(accountID string) []Transaction {
+func GetAccountMemberships(accountID string) []AccountMembership {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	var accountTxs []Transaction
-	for _, tx := range storage.transactions {
-		if tx.FromAccountID == accountID || tx.ToAccountID == accountID {
-			accountTxs = append(accountTxs, tx)
+	members := make([]AccountMembership, 0, len(storage.accountMemberIndex[accountID]))
+	for _, id := range storage.accountMemberIndex[accountID] {
+		members = append(members, storage.memberships[id])
+	}
+	return members
+}
+
+// GetUserMemberships возвращает доступы пользователя к чужим счетам с указанным статусом (пусто — все)
+func GetUserMemberships(userID, status string) []AccountMembership {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	members := make([]AccountMembership, 0)
+	for _, id := range storage.userMembershipIndex[userID] {
+		if m := storage.memberships[id]; status == "" || m.Status == status {
+			members = append(members, m)
 		}
 	}
-	return accountTxs
+	return members
 }
 
-var secretHMACKey = []byte("your-secret-hmac-key")
+// SetMembershipStatus переводит доступ в новый статус: приглашение принимается или отклоняется,
+// действующий доступ или приглашение отзывается
+func SetMembershipStatus(membershipID, status string, now time.Time) (AccountMembership, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	m, ok := storage.memberships[membershipID]
+	if !ok {
+		return AccountMembership{}, ErrMembershipNotFound
+	}
+	switch status {
+	case MembershipStatusActive, MembershipStatusDeclined:
+		if m.Status != MembershipStatusInvited {
+			return AccountMembership{}, ErrInvalidMembershipState
+		}
+	case MembershipStatusRevoked:
+		if m.Status != MembershipStatusInvited && m.Status != MembershipStatusActive {
+			return AccountMembership{}, ErrInvalidMembershipState
+		}
+	default:
+		return AccountMembership{}, fmt.Errorf("unknown membership status %q", status)
+	}
+
+	// Поручения, созданные участником с этого счёта, без его доступа исполняться не должны
+	if status == MembershipStatusRevoked && m.Status == MembershipStatusActive {
+		cancelStandingOrders(func(order StandingOrder) bool {
+			return order.UserID == m.UserID && order.FromAccountID == m.AccountID
+		}, "account access revoked", now)
+	}
 
-// Функция для генерации HMAC карты
-func computeCardHMAC(card Card) string {    
-    data := card.Number + card.CVV + fmt.Sprintf("%02d%04d", card.ExpiryMonth, card.ExpiryYear)
-    return GenerateHMAC(data, secretHMACKey)
+	m.Status = status
+	m.UpdatedAt = now
+	storage.memberships[m.ID] = m
+	return m, nil
 }
 
-func GenerateHMAC(data string, key []byte) string {
-    mac := hmac.New(sha256.New, key)
-    mac.Write([]byte(data))
-    return fmt.Sprintf("%x", mac.Sum(nil))
+func isSigner(org Organization, userID string) bool {
+	for _, signer := range org.Signers {
+		if signer.UserID == userID {
+			return true
+		}
+	}
+	return false
 }
 
-func AddCard(card Card) error {
-    storage.mu.Lock()
-    defer storage.mu.Unlock()
+// AddOrganization регистрирует организацию; ИНН должен быть уникальным
+func AddOrganization(org Organization) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if _, exists := storage.innIndex[org.INN]; exists {
+		return ErrOrganizationExists
+	}
+	storage.organizations[org.ID] = org
+	storage.innIndex[org.INN] = org.ID
+	for _, signer := range org.Signers {
+		storage.userOrganizationIndex[signer.UserID] = append(storage.userOrganizationIndex[signer.UserID], org.ID)
+	}
+	return nil
+}
 
-    if _, exists := storage.accounts[card.AccountID]; !exists {
-        return fmt.Errorf("account %s not found", card.AccountID)
-    }
+func GetOrganization(orgID string) (Organization, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	org, ok := storage.organizations[orgID]
+	return org, ok
+}
 
-    card.HMAC = computeCardHMAC(card)
-    
-    storage.cards[card.ID] = card
-    storage.cardIndex[card.AccountID] = append(storage.cardIndex[card.AccountID], card.ID)
+// GetSignerOrganization возвращает организацию, если пользователь — её подписант
+func GetSignerOrganization(orgID, userID string) (Organization, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	org, ok := storage.organizations[orgID]
+	if !ok || !isSigner(org, userID) {
+		return Organization{}, false
+	}
+	return org, true
+}
 
-    return nil
+// GetUserOrganizations возвращает организации, в которых пользователь — подписант
+func GetUserOrganizations(userID string) []Organization {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	orgs := make([]Organization, 0, len(storage.userOrganizationIndex[userID]))
+	for _, id := range storage.userOrganizationIndex[userID] {
+		orgs = append(orgs, storage.organizations[id])
+	}
+	return orgs
 }
 
-func GetAccountCards(accountID string) []Card {
+func GetOrganizationAccounts(orgID string) []Account {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	cardIDs := storage.cardIndex[accountID]
-	cards := make([]Card, 0, len(cardIDs))
-	for _, id := range cardIDs {
-		if card, ok := storage.cards[id]; ok {
-			cards = append(cards, card)
+	accounts := make([]Account, 0, len(storage.organizationAccounts[orgID]))
+	for _, id := range storage.organizationAccounts[orgID] {
+		accounts = append(accounts, storage.accounts[id])
+	}
+	return accounts
+}
+
+func AddOrganizationSigner(orgID string, signer OrganizationSigner) (Organization, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	org, ok := storage.organizations[orgID]
+	if !ok {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	if isSigner(org, signer.UserID) {
+		return Organization{}, ErrAlreadySigner
+	}
+	org.Signers = append(org.Signers, signer)
+	storage.organizations[orgID] = org
+	storage.userOrganizationIndex[signer.UserID] = append(storage.userOrganizationIndex[signer.UserID], orgID)
+	return org, nil
+}
+
+// RemoveOrganizationSigner лишает пользователя права подписи; последнего подписанта удалить нельзя.
+// Подписи, уже поставленные им под ожидающими платежами, сохраняются.
+func RemoveOrganizationSigner(orgID, userID string) (Organization, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	org, ok := storage.organizations[orgID]
+	if !ok {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	if !isSigner(org, userID) {
+		return Organization{}, ErrNotSigner
+	}
+	if len(org.Signers) == 1 {
+		return Organization{}, ErrLastSigner
+	}
+
+	signers := make([]OrganizationSigner, 0, len(org.Signers)-1)
+	for _, signer := range org.Signers {
+		if signer.UserID != userID {
+			signers = append(signers, signer)
 		}
 	}
-	return cards
+	org.Signers = signers
+	storage.organizations[orgID] = org
+
+	orgIDs := storage.userOrganizationIndex[userID]
+	for i, id := range orgIDs {
+		if id == orgID {
+			storage.userOrganizationIndex[userID] = append(orgIDs[:i:i], orgIDs[i+1:]...)
+			break
+		}
+	}
+	return org, nil
 }
 
-func GetCardByNumber(number string) (Card, bool) {
+// RequiresDualApproval сообщает, нужна ли платежу со счёта вторая подпись
+func RequiresDualApproval(acc Account, amount decimal.Decimal) bool {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	for _, card := range storage.cards {
-		if card.Number == number {
-			return card, true
-		}
+	return requiresDualApproval(acc, amount)
+}
+
+// requiresDualApproval — RequiresDualApproval для вызова под storage.mu
+func requiresDualApproval(acc Account, amount decimal.Decimal) bool {
+	if acc.OrganizationID == "" {
+		return false
 	}
-	return Card{}, false
+	return amount.GreaterThan(storage.organizations[acc.OrganizationID].DualApprovalThreshold)
 }
 
-func AddLoan(loan Loan) error {
+// CreatePaymentApproval сохраняет платёж до получения подписей. Если платёж создал подписант,
+// его подпись засчитывается сразу.
+func CreatePaymentApproval(approval PaymentApproval) (PaymentApproval, error) {
 	storage.mu.Lock()
 	defer storage.mu.Unlock()
-	if _, exists := storage.users[loan.UserID]; !exists {
-		return fmt.Errorf("user %s not found", loan.UserID)
+	org, ok := storage.organizations[approval.OrganizationID]
+	if !ok {
+		return PaymentApproval{}, ErrOrganizationNotFound
 	}
-	if _, exists := storage.accounts[loan.AccountID]; !exists {
-		return fmt.Errorf("account %s not found", loan.AccountID)
+	approval.Approvals = []string{}
+	if isSigner(org, approval.InitiatedBy) {
+		approval.Approvals = append(approval.Approvals, approval.InitiatedBy)
 	}
-	storage.loans[loan.ID] = loan
-	storage.loanIndex[loan.UserID] = append(storage.loanIndex[loan.UserID], loan.ID)
-	return nil
+	approval.Status = PaymentApprovalPending
+	storage.paymentApprovals[approval.ID] = approval
+	return approval, nil
 }
 
-func GetUserLoans(userID string) []Loan {
+func GetPaymentApproval(approvalID string) (PaymentApproval, bool) {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	loanIDs := storage.loanIndex[userID]
-	loans := make([]Loan, 0, len(loanIDs))
-	for _, id := range loanIDs {
-		if loan, ok := storage.loans[id]; ok {
-			loans = append(loans, loan)
-		}
-	}
-	return loans
+	approval, ok := storage.paymentApprovals[approvalID]
+	return approval, ok
 }
 
-func GetLoan(loanID string) (Loan, bool) {
+// GetOrganizationApprovals возвращает платежи организации с указанным статусом (пусто — все), новые первыми
+func GetOrganizationApprovals(orgID, status string) []PaymentApproval {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	loan, ok := storage.loans[loanID]
-	return loan, ok
-}
\ No newline at end of file
+	approvals := make([]PaymentApproval, 0)
+	for _, approval := range storage.paymentApprovals {
+		if approval.OrganizationID == orgID && (status == "" || approval.Status == status) {
+			approvals = append(approvals, approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.After(approvals[j].CreatedAt)
+	})
+	return approvals
+}
+
+// ApprovePayment добавляет подпись подписанта. Набрав нужное число подписей, платёж исполняется;
+// если перевод не прошёл (например, не хватило средств), подпись не сохраняется и платёж остаётся ожидающим.
+func ApprovePayment(approvalID, userID string, now time.Time) (PaymentApproval, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	approval, ok := storage.paymentApprovals[approvalID]
+	if !ok {
+		return PaymentApproval{}, ErrApprovalNotFound
+	}
+	if approval.Status != PaymentApprovalPending {
+		return PaymentApproval{}, ErrInvalidApprovalState
+	}
+	if !isSigner(storage.organizations[approval.OrganizationID], userID) {
+		return PaymentApproval{}, ErrNotSigner
+	}
+	for _, id := range approval.Approvals {
+		if id == userID {
+			return PaymentApproval{}, ErrAlreadyApproved
+		}
+	}
+
+	approval.Approvals = append(approval.Approvals[:len(approval.Approvals):len(approval.Approvals)], userID)
+	if len(approval.Approvals) >= requiredApprovals {
+		if approval.ExternalPaymentID != "" {
+			// Платёж в другой банк после второй подписи встаёт в очередь клиринга
+			p := storage.externalPayments[approval.ExternalPaymentID]
+			if err := queueExternalPaymentLocked(&p, now); err != nil {
+				return PaymentApproval{}, err
+			}
+			storage.externalPayments[p.ID] = p
+			approval.TransactionID = p.TransactionID
+		} else {
+			tx, err := transferLocked(approval.FromAccountID, approval.ToAccountID, approval.Amount, approval.Description)
+			if err != nil {
+				return PaymentApproval{}, err
+			}
+			approval.TransactionID = tx.ID
+		}
+		approval.Status = PaymentApprovalExecuted
+	}
+	approval.UpdatedAt = now
+	storage.paymentApprovals[approval.ID] = approval
+	return approval, nil
+}
+
+// RejectPayment отклоняет ожидающий платёж: подписант — отказывая в подписи, инициатор — отзывая платёж
+func RejectPayment(approvalID, userID, reason string, now time.Time) (PaymentApproval, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	approval, ok := storage.paymentApprovals[approvalID]
+	if !ok {
+		return PaymentApproval{}, ErrApprovalNotFound
+	}
+	if approval.Status != PaymentApprovalPending {
+		return PaymentApproval{}, ErrInvalidApprovalState
+	}
+	if approval.InitiatedBy != userID && !isSigner(storage.organizations[approval.OrganizationID], userID) {
+		return PaymentApproval{}, ErrNotSigner
+	}
+
+	approval.Status, approval.RejectedBy, approval.Reason = PaymentApprovalRejected, userID, reason
+	approval.UpdatedAt = now
+	storage.paymentApprovals[approval.ID] = approval
+	if p, ok := storage.externalPayments[approval.ExternalPaymentID]; ok {
+		p.Status, p.RejectReason, p.UpdatedAt = ExternalPaymentCancelled, "Payment rejected by signer", now
+		storage.externalPayments[p.ID] = p
+	}
+	return approval, nil
+}
+
+// nextEDNo возвращает следующий номер электронного документа. Вызывается под storage.mu.
+func nextEDNo() int {
+	storage.lastEDNo++
+	return storage.lastEDNo
+}
+
+// externalCounterparty — идентификатор счёта в другом банке для лимитов и выписки
+func externalCounterparty(bik, account string) string {
+	return bik + "/" + account
+}
+
+// AddExternalPayment сохраняет платёжное поручение в другой банк. Поручение, не требующее
+// второй подписи, сразу списывается со счёта и встаёт в очередь клиринга.
+func AddExternalPayment(p ExternalPayment, now time.Time) (ExternalPayment, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if _, ok := storage.accounts[p.FromAccountID]; !ok {
+		return ExternalPayment{}, fmt.Errorf("source %w: %s", ErrAccountNotFound, p.FromAccountID)
+	}
+	if p.Status != ExternalPaymentAwaitingApproval {
+		if err := queueExternalPaymentLocked(&p, now); err != nil {
+			return ExternalPayment{}, err
+		}
+	}
+	storage.externalPayments[p.ID] = p
+	storage.externalPaymentIndex[p.FromAccountID] = append(storage.externalPaymentIndex[p.FromAccountID], p.ID)
+	return p, nil
+}
+
+// queueExternalPaymentLocked списывает сумму поручения со счёта плательщика с проверкой лимитов
+// и ставит поручение в очередь клиринга. Вызывается под storage.mu.
+func queueExternalPaymentLocked(p *ExternalPayment, now time.Time) error {
+	from, ok := storage.accounts[p.FromAccountID]
+	if !ok {
+		return fmt.Errorf("source %w: %s", ErrAccountNotFound, p.FromAccountID)
+	}
+	counterparty := externalCounterparty(p.PayeeBIK, p.PayeeAccount)
+	op := LimitedOperation{Type: LimitOpTransfer, Counterparty: counterparty, Amount: p.Amount}
+	if err := checkLimits(from, []LimitedOperation{op}, now); err != nil {
+		return err
+	}
+	if err := checkDebit(from, p.Amount); err != nil {
+		return err
+	}
+
+	debitAccount(&from, p.Amount)
+	storage.accounts[from.ID] = from
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   from.ID,
+		Amount:          p.Amount,
+		Timestamp:       now,
+		TransactionType: "external_transfer",
+		Description:     p.Purpose,
+		ExternalAccount: counterparty,
+	}
+	appendTransaction(tx)
+
+	p.Status, p.TransactionID, p.UpdatedAt = ExternalPaymentQueued, tx.ID, now
+	return nil
+}
+
+// returnExternalPaymentLocked возвращает списанную сумму на счёт плательщика. Возврат учитывается
+// как сторно списания, поэтому не расходует лимиты. Вызывается под storage.mu.
+func returnExternalPaymentLocked(p *ExternalPayment, status, reason string, now time.Time) {
+	debit := storage.transactions[storage.transactionIndex[p.TransactionID]]
+	returnDebitLocked(debit, "external_transfer_return", "Return of payment: "+reason, now)
+	p.Status, p.RejectReason, p.UpdatedAt = status, reason, now
+}
+
+// returnDebitLocked зачисляет обратно на счёт сумму списания, не дошедшего до получателя.
+// Вызывается под storage.mu.
+func returnDebitLocked(debit Transaction, txType, description string, now time.Time) Transaction {
+	acc := storage.accounts[debit.FromAccountID]
+	creditAccount(&acc, debit.Amount)
+	storage.accounts[acc.ID] = acc
+	tx := Transaction{
+		ID:                    GenerateID(),
+		ToAccountID:           acc.ID,
+		Amount:                debit.Amount,
+		Timestamp:             now,
+		TransactionType:       txType,
+		Description:           description,
+		ExternalAccount:       debit.ExternalAccount,
+		OriginalTransactionID: debit.ID,
+	}
+	appendTransaction(tx)
+	markReversed(debit, tx.ID)
+	return tx
+}
+
+func GetExternalPayment(paymentID string) (ExternalPayment, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	p, ok := storage.externalPayments[paymentID]
+	return p, ok
+}
+
+// GetAccountExternalPayments возвращает поручения в другие банки со счёта, новые первыми
+func GetAccountExternalPayments(accountID string) []ExternalPayment {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	ids := storage.externalPaymentIndex[accountID]
+	payments := make([]ExternalPayment, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		payments = append(payments, storage.externalPayments[ids[i]])
+	}
+	return payments
+}
+
+// CancelExternalPayment отменяет поручение, ещё не включённое в клиринговый пакет, и возвращает средства
+func CancelExternalPayment(paymentID string, now time.Time) (ExternalPayment, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	p, ok := storage.externalPayments[paymentID]
+	if !ok {
+		return ExternalPayment{}, ErrExternalPaymentNotFound
+	}
+	if p.Status != ExternalPaymentQueued {
+		return ExternalPayment{}, ErrInvalidExternalPaymentState
+	}
+	returnExternalPaymentLocked(&p, ExternalPaymentCancelled, "cancelled by payer", now)
+	storage.externalPayments[p.ID] = p
+	return p, nil
+}
+
+// FormClearingBatch включает все поручения из очереди в новый клиринговый пакет
+// и присваивает им номера электронных документов
+func FormClearingBatch(now time.Time) (ClearingBatch, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	queued := make([]ExternalPayment, 0)
+	for _, p := range storage.externalPayments {
+		if p.Status == ExternalPaymentQueued {
+			queued = append(queued, p)
+		}
+	}
+	if len(queued) == 0 {
+		return ClearingBatch{}, ErrNothingToClear
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		if !queued[i].CreatedAt.Equal(queued[j].CreatedAt) {
+			return queued[i].CreatedAt.Before(queued[j].CreatedAt)
+		}
+		return queued[i].ID < queued[j].ID
+	})
+
+	batch := ClearingBatch{
+		ID:          GenerateID(),
+		EDNo:        nextEDNo(),
+		PaymentIDs:  make([]string, 0, len(queued)),
+		Count:       len(queued),
+		TotalAmount: decimal.Zero,
+		Status:      ClearingBatchFormed,
+		CreatedAt:   now,
+	}
+	for _, p := range queued {
+		p.Status, p.BatchID, p.EDNo, p.UpdatedAt = ExternalPaymentSent, batch.ID, nextEDNo(), now
+		storage.externalPayments[p.ID] = p
+		batch.PaymentIDs = append(batch.PaymentIDs, p.ID)
+		batch.TotalAmount = batch.TotalAmount.Add(p.Amount)
+	}
+	storage.clearingBatches[batch.ID] = batch
+	return batch, nil
+}
+
+func GetClearingBatch(batchID string) (ClearingBatch, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	batch, ok := storage.clearingBatches[batchID]
+	return batch, ok
+}
+
+// GetClearingBatches возвращает клиринговые пакеты, новые первыми
+func GetClearingBatches() []ClearingBatch {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	batches := make([]ClearingBatch, 0, len(storage.clearingBatches))
+	for _, batch := range storage.clearingBatches {
+		batches = append(batches, batch)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].EDNo > batches[j].EDNo
+	})
+	return batches
+}
+
+// GetBatchPayments возвращает поручения пакета в порядке номеров документов
+func GetBatchPayments(batch ClearingBatch) []ExternalPayment {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	payments := make([]ExternalPayment, 0, len(batch.PaymentIDs))
+	for _, id := range batch.PaymentIDs {
+		payments = append(payments, storage.externalPayments[id])
+	}
+	return payments
+}
+
+// RecordClearingError запоминает ошибку отправки пакета; пакет остаётся сформированным и может быть отправлен повторно
+func RecordClearingError(batchID string, err error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if batch, ok := storage.clearingBatches[batchID]; ok {
+		batch.LastError = err.Error()
+		storage.clearingBatches[batchID] = batch
+	}
+}
+
+// ApplyClearingStatuses проводит ответ клиринга по пакету: исполненные поручения закрываются,
+// по отвергнутым средства возвращаются плательщику. Пакет считается обработанным, когда
+// получены статусы всех его поручений.
+func ApplyClearingStatuses(batchID string, statuses []ClearingStatus, now time.Time) (ClearingBatch, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	batch, ok := storage.clearingBatches[batchID]
+	if !ok {
+		return ClearingBatch{}, ErrClearingBatchNotFound
+	}
+	if batch.Status == ClearingBatchProcessed {
+		return ClearingBatch{}, ErrBatchAlreadyProcessed
+	}
+	byEDNo := make(map[int]string, len(batch.PaymentIDs))
+	for _, id := range batch.PaymentIDs {
+		byEDNo[storage.externalPayments[id].EDNo] = id
+	}
+
+	for _, status := range statuses {
+		p, ok := storage.externalPayments[byEDNo[status.EDNo]]
+		if !ok || p.Status != ExternalPaymentSent {
+			continue
+		}
+		if status.Accepted {
+			p.Status, p.UpdatedAt = ExternalPaymentExecuted, now
+		} else {
+			returnExternalPaymentLocked(&p, ExternalPaymentRejected, status.Reason, now)
+		}
+		storage.externalPayments[p.ID] = p
+	}
+
+	batch.Executed, batch.Rejected = 0, 0
+	pending := 0
+	for _, id := range batch.PaymentIDs {
+		switch storage.externalPayments[id].Status {
+		case ExternalPaymentExecuted:
+			batch.Executed++
+		case ExternalPaymentRejected:
+			batch.Rejected++
+		default:
+			pending++
+		}
+	}
+	if pending == 0 {
+		batch.Status = ClearingBatchProcessed
+	}
+	batch.LastError = ""
+	batch.SubmittedAt = &now
+	storage.clearingBatches[batch.ID] = batch
+	return batch, nil
+}
+
+// ApplyIncomingPayment зачисляет поступление из другого банка на счёт по его номеру.
+// Если счёт не найден или закрыт, поступление помечается к возврату.
+func ApplyIncomingPayment(in IncomingPayment, now time.Time) IncomingPayment {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	in.ID, in.ReceivedAt, in.Status = GenerateID(), now, IncomingPaymentReturned
+	acc, ok := storage.accounts[storage.accountNumberIndex[in.PayeeAccount]]
+	if ok && checkCredit(acc) == nil {
+		creditAccount(&acc, in.Amount)
+		storage.accounts[acc.ID] = acc
+		tx := Transaction{
+			ID:              GenerateID(),
+			ToAccountID:     acc.ID,
+			Amount:          in.Amount,
+			Timestamp:       now,
+			TransactionType: "external_incoming",
+			Description:     fmt.Sprintf("%s: %s", in.PayerName, in.Purpose),
+			ExternalAccount: externalCounterparty(in.PayerBIK, in.PayerAccount),
+		}
+		appendTransaction(tx)
+		in.Status, in.AccountID, in.TransactionID = IncomingPaymentCredited, acc.ID, tx.ID
+	}
+	storage.incomingPayments = append(storage.incomingPayments, in)
+	return in
+}
+
+// GetIncomingPayments возвращает поступления из других банков, новые первыми
+func GetIncomingPayments() []IncomingPayment {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	payments := make([]IncomingPayment, 0, len(storage.incomingPayments))
+	for i := len(storage.incomingPayments) - 1; i >= 0; i-- {
+		payments = append(payments, storage.incomingPayments[i])
+	}
+	return payments
+}
+
+// sbpCounterparty возвращает идентификатор получателя перевода через СБП для выписки и лимитов
+func sbpCounterparty(memberID, phone string) string {
+	return "sbp:" + memberID + "/" + phone
+}
+
+// SetSBPAlias привязывает номер телефона к счёту; повторная привязка заменяет счёт и настройку банка по умолчанию
+func SetSBPAlias(alias SBPAlias, now time.Time) (SBPAlias, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	acc, ok := storage.accounts[alias.AccountID]
+	if !ok {
+		return SBPAlias{}, ErrAccountNotFound
+	}
+	if err := checkCredit(acc); err != nil {
+		return SBPAlias{}, err
+	}
+	alias.CreatedAt, alias.UpdatedAt = now, now
+	if prev, ok := storage.sbpAliases[alias.Phone]; ok {
+		alias.CreatedAt = prev.CreatedAt
+	}
+	storage.sbpAliases[alias.Phone] = alias
+	return alias, nil
+}
+
+func GetSBPAlias(phone string) (SBPAlias, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	alias, ok := storage.sbpAliases[phone]
+	return alias, ok
+}
+
+func RemoveSBPAlias(phone string) (SBPAlias, bool) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	alias, ok := storage.sbpAliases[phone]
+	delete(storage.sbpAliases, phone)
+	return alias, ok
+}
+
+// RemoveAccountSBPAliases отвязывает номера телефонов от счёта и возвращает отвязанные
+func RemoveAccountSBPAliases(accountID string) []SBPAlias {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	removed := make([]SBPAlias, 0)
+	for phone, alias := range storage.sbpAliases {
+		if alias.AccountID == accountID {
+			removed = append(removed, alias)
+			delete(storage.sbpAliases, phone)
+		}
+	}
+	return removed
+}
+
+// DebitSBPTransfer списывает со счёта перевод через СБП в другой банк до его отправки в операционный центр
+func DebitSBPTransfer(fromAccountID, memberID, phone string, amount decimal.Decimal, description string, now time.Time) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	from, ok := storage.accounts[fromAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("source %w: %s", ErrAccountNotFound, fromAccountID)
+	}
+	counterparty := sbpCounterparty(memberID, phone)
+	op := LimitedOperation{Type: LimitOpTransfer, Counterparty: counterparty, Amount: amount}
+	if err := checkLimits(from, []LimitedOperation{op}, now); err != nil {
+		return Transaction{}, err
+	}
+	if err := checkDebit(from, amount); err != nil {
+		return Transaction{}, err
+	}
+
+	debitAccount(&from, amount)
+	storage.accounts[from.ID] = from
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   from.ID,
+		Amount:          amount,
+		Timestamp:       now,
+		TransactionType: "sbp_transfer",
+		Description:     description,
+		ExternalAccount: counterparty,
+	}
+	appendTransaction(tx)
+	return tx, nil
+}
+
+// ReturnSBPTransfer возвращает на счёт перевод, который банк получателя не зачислил
+func ReturnSBPTransfer(transactionID, reason string, now time.Time) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	i, ok := storage.transactionIndex[transactionID]
+	if !ok {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	return returnDebitLocked(storage.transactions[i], "sbp_transfer_return", "Return of SBP transfer: "+reason, now), nil
+}
+
+// CreditSBPTransfer зачисляет входящий перевод через СБП на счёт, привязанный к номеру телефона
+func CreditSBPTransfer(t SwitchTransfer, now time.Time) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	alias, ok := storage.sbpAliases[t.Phone]
+	if !ok {
+		return Transaction{}, ErrSBPRecipientNotFound
+	}
+	acc, ok := storage.accounts[alias.AccountID]
+	if !ok {
+		return Transaction{}, ErrAccountNotFound
+	}
+	if err := checkCredit(acc); err != nil {
+		return Transaction{}, err
+	}
+
+	creditAccount(&acc, t.Amount)
+	storage.accounts[acc.ID] = acc
+	description := "SBP transfer from " + t.SenderName
+	if t.Comment != "" {
+		description += ": " + t.Comment
+	}
+	tx := Transaction{
+		ID:              GenerateID(),
+		ToAccountID:     acc.ID,
+		Amount:          t.Amount,
+		Timestamp:       now,
+		TransactionType: "sbp_incoming",
+		Description:     description,
+		ExternalAccount: "sbp:" + t.FromMemberID,
+	}
+	appendTransaction(tx)
+	return tx, nil
+}
+
+func AddSBPQRCode(qr SBPQRCode) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.sbpQRCodes[qr.ID] = qr
+}
+
+// GetSBPQRCode возвращает QR-код; динамический код с истёкшим сроком отдаётся со статусом expired
+func GetSBPQRCode(qrID string, now time.Time) (SBPQRCode, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	qr, ok := storage.sbpQRCodes[qrID]
+	if ok && qr.Status == SBPQRStatusActive && qr.ExpiresAt != nil && !now.Before(*qr.ExpiresAt) {
+		qr.Status = SBPQRStatusExpired
+	}
+	return qr, ok
+}
+
+// PaySBPQRCode оплачивает QR-код мерчанта переводом на его расчётный счёт.
+// Динамический код оплачивается один раз и только в пределах срока действия.
+func PaySBPQRCode(qrID, fromAccountID string, amount decimal.Decimal, now time.Time) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	qr, ok := storage.sbpQRCodes[qrID]
+	if !ok {
+		return Transaction{}, ErrSBPQRNotFound
+	}
+	if qr.Status == SBPQRStatusActive && qr.ExpiresAt != nil && !now.Before(*qr.ExpiresAt) {
+		qr.Status = SBPQRStatusExpired
+		storage.sbpQRCodes[qr.ID] = qr
+		return Transaction{}, ErrSBPQRExpired
+	}
+	if qr.Status != SBPQRStatusActive {
+		return Transaction{}, ErrSBPQRNotPayable
+	}
+	if qr.Amount.IsPositive() && !amount.Equal(qr.Amount) {
+		return Transaction{}, fmt.Errorf("%w: amount must equal %s", ErrInvalidSBPQR, qr.Amount.String())
+	}
+	merchant, ok := storage.merchants[qr.MerchantID]
+	if !ok {
+		return Transaction{}, ErrSBPQRNotFound
+	}
+	from, ok := storage.accounts[fromAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("source %w: %s", ErrAccountNotFound, fromAccountID)
+	}
+	to, ok := storage.accounts[merchant.SettlementAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("destination %w: %s", ErrAccountNotFound, merchant.SettlementAccountID)
+	}
+	if from.ID == to.ID {
+		return Transaction{}, ErrSameAccount
+	}
+	if err := checkCredit(to); err != nil {
+		return Transaction{}, err
+	}
+	op := LimitedOperation{Type: LimitOpTransfer, Counterparty: to.ID, Amount: amount}
+	if err := checkLimits(from, []LimitedOperation{op}, now); err != nil {
+		return Transaction{}, err
+	}
+	if err := checkDebit(from, amount); err != nil {
+		return Transaction{}, err
+	}
+
+	debitAccount(&from, amount)
+	creditAccount(&to, amount)
+	storage.accounts[from.ID] = from
+	storage.accounts[to.ID] = to
+	description := "SBP payment to " + merchant.Name
+	if qr.Purpose != "" {
+		description += ": " + qr.Purpose
+	}
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   from.ID,
+		ToAccountID:     to.ID,
+		Amount:          amount,
+		Timestamp:       now,
+		TransactionType: "sbp_payment",
+		Description:     description,
+		MerchantID:      merchant.ID,
+	}
+	appendTransaction(tx)
+
+	qr.Payments++
+	if qr.Type == SBPQRDynamic {
+		qr.Status, qr.TransactionID, qr.PaidAt = SBPQRStatusPaid, tx.ID, &now
+	}
+	storage.sbpQRCodes[qr.ID] = qr
+	return tx, nil
+}
+
+// SaveBiller добавляет поставщика в каталог или заменяет его описание
+func SaveBiller(biller Biller) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	acc, ok := storage.accounts[biller.SettlementAccountID]
+	if !ok {
+		return fmt.Errorf("settlement %w: %s", ErrAccountNotFound, biller.SettlementAccountID)
+	}
+	if err := checkCredit(acc); err != nil {
+		return err
+	}
+	storage.billers[biller.ID] = biller
+	return nil
+}
+
+func GetBiller(billerID string) (Biller, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	biller, ok := storage.billers[billerID]
+	return biller, ok
+}
+
+// GetBillers возвращает поставщиков каталога по наименованию; пустая category — все категории
+func GetBillers(category string, activeOnly bool) []Biller {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	billers := make([]Biller, 0)
+	for _, b := range storage.billers {
+		if (category == "" || b.Category == category) && (b.Active || !activeOnly) {
+			billers = append(billers, b)
+		}
+	}
+	sort.Slice(billers, func(i, j int) bool {
+		if billers[i].Name != billers[j].Name {
+			return billers[i].Name < billers[j].Name
+		}
+		return billers[i].ID < billers[j].ID
+	})
+	return billers
+}
+
+// PayBill переводит оплату услуг на расчётный счёт поставщика и присваивает платежу номер квитанции
+func PayBill(p BillPayment, now time.Time) (BillPayment, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	biller, ok := storage.billers[p.BillerID]
+	if !ok {
+		return BillPayment{}, ErrBillerNotFound
+	}
+	if !biller.Active {
+		return BillPayment{}, ErrBillerInactive
+	}
+	from, ok := storage.accounts[p.FromAccountID]
+	if !ok {
+		return BillPayment{}, fmt.Errorf("source %w: %s", ErrAccountNotFound, p.FromAccountID)
+	}
+	to, ok := storage.accounts[biller.SettlementAccountID]
+	if !ok {
+		return BillPayment{}, fmt.Errorf("destination %w: %s", ErrAccountNotFound, biller.SettlementAccountID)
+	}
+	if from.ID == to.ID {
+		return BillPayment{}, ErrSameAccount
+	}
+	if err := checkCredit(to); err != nil {
+		return BillPayment{}, err
+	}
+	op := LimitedOperation{Type: LimitOpTransfer, Counterparty: to.ID, Amount: p.Amount}
+	if err := checkLimits(from, []LimitedOperation{op}, now); err != nil {
+		return BillPayment{}, err
+	}
+	if err := checkDebit(from, p.Amount); err != nil {
+		return BillPayment{}, err
+	}
+
+	debitAccount(&from, p.Amount)
+	creditAccount(&to, p.Amount)
+	storage.accounts[from.ID] = from
+	storage.accounts[to.ID] = to
+
+	storage.lastReceiptNo++
+	p.ReceiptNumber = fmt.Sprintf("%s-%06d", now.Format("20060102"), storage.lastReceiptNo)
+	description := "Payment for services: " + biller.Name
+	if len(biller.Fields) > 0 && p.Fields[biller.Fields[0].Key] != "" {
+		description += ", " + biller.Fields[0].Label + " " + p.Fields[biller.Fields[0].Key]
+	}
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   from.ID,
+		ToAccountID:     to.ID,
+		Amount:          p.Amount,
+		Timestamp:       now,
+		TransactionType: "bill_payment",
+		Description:     description,
+	}
+	appendTransaction(tx)
+
+	p.TransactionID, p.CreatedAt = tx.ID, now
+	storage.billPayments[p.ID] = p
+	storage.billPaymentIndex[p.UserID] = append(storage.billPaymentIndex[p.UserID], p.ID)
+	return p, nil
+}
+
+func GetBillPayment(paymentID string) (BillPayment, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	p, ok := storage.billPayments[paymentID]
+	return p, ok
+}
+
+// GetUserBillPayments возвращает оплаты услуг пользователя, новые первыми
+func GetUserBillPayments(userID string) []BillPayment {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	ids := storage.billPaymentIndex[userID]
+	payments := make([]BillPayment, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		payments = append(payments, storage.billPayments[ids[i]])
+	}
+	return payments
+}
+
+func SaveBillTemplate(template BillTemplate) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.billTemplates[template.ID] = template
+}
+
+func GetBillTemplate(templateID string) (BillTemplate, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	template, ok := storage.billTemplates[templateID]
+	return template, ok
+}
+
+func GetUserBillTemplates(userID string) []BillTemplate {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	templates := make([]BillTemplate, 0)
+	for _, t := range storage.billTemplates {
+		if t.UserID == userID {
+			templates = append(templates, t)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].CreatedAt.Before(templates[j].CreatedAt) })
+	return templates
+}
+
+func DeleteBillTemplate(templateID string) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	delete(storage.billTemplates, templateID)
+}
+
+// checkDebit проверяет, можно ли списать сумму со счёта с учётом статуса счёта, холдов,
+// лимита овердрафта и арестованной суммы. Пока не погашена недостача после сторно, списания запрещены.
+func checkDebit(acc Account, amount decimal.Decimal) error {
+	switch acc.Status {
+	case AccountStatusFrozen:
+		return ErrAccountFrozen
+	case AccountStatusClosed:
+		return ErrAccountClosed
+	}
+	if acc.RecoveryHold.IsPositive() {
+		return fmt.Errorf("%w: unrecovered reversal shortfall %s", ErrInsufficientFunds, acc.RecoveryHold.String())
+	}
+	spendable := acc.AvailableBalance.Add(acc.OverdraftLimit)
+	if spendable.Sub(acc.ArrestedAmount).LessThan(amount) {
+		switch {
+		case !spendable.LessThan(amount):
+			return ErrFundsArrested
+		case acc.OverdraftLimit.IsPositive():
+			return ErrOverdraftLimitExceeded
+		default:
+			return ErrInsufficientFunds
+		}
+	}
+	return nil
+}
+
+// checkCredit проверяет, можно ли зачислить средства на счёт: на закрытый счёт зачисления запрещены
+func checkCredit(acc Account) error {
+	if acc.Status == AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// debitAccount уменьшает текущий и доступный остаток счёта
+func debitAccount(acc *Account, amount decimal.Decimal) {
+	acc.Balance = acc.Balance.Sub(amount)
+	acc.AvailableBalance = acc.AvailableBalance.Sub(amount)
+}
+
+// creditAccount увеличивает текущий и доступный остаток счёта;
+// поступления в первую очередь гасят недостачу после сторно
+func creditAccount(acc *Account, amount decimal.Decimal) {
+	acc.Balance = acc.Balance.Add(amount)
+	acc.AvailableBalance = acc.AvailableBalance.Add(amount)
+	if acc.RecoveryHold.IsPositive() && amount.IsPositive() {
+		acc.RecoveryHold = decimal.Max(decimal.Zero, acc.RecoveryHold.Sub(amount))
+	}
+}
+
+func UpdateAccountBalance(accountID string, amount decimal.Decimal) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	acc, ok := storage.accounts[accountID]
+	if !ok {
+		return fmt.Errorf("account %s not found", accountID)
+	}
+	if amount.IsNegative() {
+		if err := checkDebit(acc, amount.Neg()); err != nil {
+			return err
+		}
+	} else if err := checkCredit(acc); err != nil {
+		return err
+	}
+
+	creditAccount(&acc, amount)
+	storage.accounts[accountID] = acc
+	return nil
+}
+
+// CloseAccount закрывает счёт. Положительный остаток переводится на счёт sweepTo,
+// отрицательный остаток, недостача после сторно и открытые авторизации закрытию мешают.
+// Карты счёта закрываются, регулярные переводы с него и на него отменяются.
+// Возвращает закрытый счёт и транзакцию перевода остатка, если она была.
+func CloseAccount(accountID, sweepTo string, now time.Time) (Account, *Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	acc, ok := storage.accounts[accountID]
+	if !ok {
+		return Account{}, nil, fmt.Errorf("%w: %s", ErrAccountNotFound, accountID)
+	}
+	switch acc.Status {
+	case AccountStatusClosed:
+		return Account{}, nil, ErrAccountClosed
+	case AccountStatusFrozen, AccountStatusArrested:
+		return Account{}, nil, ErrInvalidAccountStatus
+	}
+	if acc.Balance.IsNegative() || acc.RecoveryHold.IsPositive() {
+		return Account{}, nil, ErrAccountNotEmpty
+	}
+	for _, auth := range storage.authorizations {
+		if auth.AccountID == acc.ID && auth.HeldAmount().IsPositive() {
+			return Account{}, nil, ErrAccountHasHolds
+		}
+	}
+	for _, id := range storage.externalPaymentIndex[acc.ID] {
+		if status := storage.externalPayments[id].Status; status == ExternalPaymentQueued || status == ExternalPaymentSent {
+			return Account{}, nil, ErrAccountHasPendingPayments
+		}
+	}
+
+	var sweep *Transaction
+	if acc.Balance.IsPositive() {
+		if sweepTo == "" {
+			return Account{}, nil, ErrAccountNotEmpty
+		}
+		tx, err := sweepLocked(acc, sweepTo, now)
+		if err != nil {
+			return Account{}, nil, err
+		}
+		sweep = &tx
+		acc = storage.accounts[acc.ID]
+	}
+
+	acc.Status = AccountStatusClosed
+	acc.StatusReason = ""
+	acc.ClosedAt = &now
+	storage.accounts[acc.ID] = acc
+
+	for _, cardID := range storage.cardIndex[acc.ID] {
+		if card, ok := storage.cards[cardID]; ok && card.Status != CardStatusClosed {
+			card.Status = CardStatusClosed
+			signCard(&card)
+			storage.cards[card.ID] = card
+		}
+	}
+	cancelStandingOrders(func(order StandingOrder) bool {
+		return order.FromAccountID == acc.ID || order.ToAccountID == acc.ID
+	}, "account closed", now)
+	return acc, sweep, nil
+}
+
+// sweepLocked переводит весь остаток закрываемого счёта acc на счёт sweepTo. Перевод
+// имеет тип "account_closing": лимиты на него не действуют и в их итогах он не учитывается.
+// Вызывается под storage.mu.
+func sweepLocked(acc Account, sweepTo string, now time.Time) (Transaction, error) {
+	if acc.ID == sweepTo {
+		return Transaction{}, ErrSameAccount
+	}
+	to, ok := storage.accounts[sweepTo]
+	if !ok {
+		return Transaction{}, fmt.Errorf("destination %w: %s", ErrAccountNotFound, sweepTo)
+	}
+	if err := checkCredit(to); err != nil {
+		return Transaction{}, err
+	}
+	amount := acc.Balance
+	if err := checkDebit(acc, amount); err != nil {
+		return Transaction{}, err
+	}
+
+	debitAccount(&acc, amount)
+	creditAccount(&to, amount)
+	storage.accounts[acc.ID] = acc
+	storage.accounts[to.ID] = to
+
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   acc.ID,
+		ToAccountID:     to.ID,
+		Amount:          amount,
+		Timestamp:       now,
+		TransactionType: "account_closing",
+		Description:     fmt.Sprintf("Balance transfer on closing account %s", acc.Number),
+	}
+	appendTransaction(tx)
+	return tx, nil
+}
+
+// cancelStandingOrders отменяет действующие поручения, подходящие под match. Вызывается под storage.mu.
+func cancelStandingOrders(match func(StandingOrder) bool, reason string, now time.Time) {
+	for id, order := range storage.standingOrders {
+		if (order.Status == StandingOrderStatusActive || order.Status == StandingOrderStatusPaused) && match(order) {
+			order.Status = StandingOrderStatusCancelled
+			order.LastError = reason
+			order.DueDate = nil
+			order.NextRunAt = nil
+			order.UpdatedAt = now
+			storage.standingOrders[id] = order
+		}
+	}
+}
+
+// SetAccountStatus замораживает, арестовывает часть средств или возвращает счёт в активное
+// состояние (в том числе переоткрывает закрытый счёт). Закрытие — только через CloseAccount.
+func SetAccountStatus(accountID, status string, arrestedAmount decimal.Decimal, reason string) (Account, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	acc, ok := storage.accounts[accountID]
+	if !ok {
+		return Account{}, fmt.Errorf("%w: %s", ErrAccountNotFound, accountID)
+	}
+	if acc.Status == AccountStatusClosed && status != AccountStatusActive {
+		return Account{}, ErrInvalidAccountStatus
+	}
+
+	acc.Status = status
+	acc.StatusReason = reason
+	acc.ArrestedAmount = decimal.Zero
+	switch status {
+	case AccountStatusArrested:
+		acc.ArrestedAmount = arrestedAmount
+	case AccountStatusActive:
+		acc.ClosedAt = nil
+	}
+	storage.accounts[acc.ID] = acc
+	return acc, nil
+}
+
+func SetOverdraft(accountID string, limit, rate decimal.Decimal) (Account, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	acc, ok := storage.accounts[accountID]
+	if !ok {
+		return Account{}, fmt.Errorf("%w: %s", ErrAccountNotFound, accountID)
+	}
+	acc.OverdraftLimit = limit
+	acc.OverdraftRate = rate
+	storage.accounts[accountID] = acc
+	return acc, nil
+}
+
+// daysInYear — база начисления процентов по овердрафту
+const daysInYear = 365
+
+// ChargeOverdraftInterest закрывает операционный день, закончившийся в dayEnd: фиксирует остатки
+// счетов на конец дня и списывает проценты за день на использованный овердрафт. Отрицательный
+// остаток в пределах непогашенной недостачи после сторно овердрафтом не считается. Повторный
+// вызов за тот же день проценты не начисляет. Проценты списываются даже сверх лимита.
+func ChargeOverdraftInterest(dayEnd time.Time) int {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	day := time.Date(dayEnd.Year(), dayEnd.Month(), dayEnd.Day()-1, 0, 0, 0, 0, dayEnd.Location())
+	charged := 0
+	for _, acc := range storage.accounts {
+		if !acc.EndOfDayDate.Before(day) {
+			continue
+		}
+		acc.EndOfDayDate = day
+		acc.EndOfDayBalance = acc.Balance
+		acc.EndOfDayRecoveryHold = acc.RecoveryHold
+		used := acc.Balance.Add(acc.RecoveryHold).Neg()
+		if used.IsPositive() && acc.OverdraftRate.IsPositive() && acc.OverdraftInterestDate.Before(day) {
+			acc.OverdraftInterestDate = day
+			interest := used.Mul(acc.OverdraftRate).Div(decimal.NewFromInt(100 * daysInYear)).Round(maxAmountScale)
+			if interest.IsPositive() {
+				debitAccount(&acc, interest)
+				appendTransaction(Transaction{
+					ID:              GenerateID(),
+					FromAccountID:   acc.ID,
+					Amount:          interest,
+					Timestamp:       dayEnd,
+					TransactionType: "overdraft_interest",
+					Description: fmt.Sprintf("Overdraft interest for %s on %s at %s%% p.a.",
+						day.Format("2006-01-02"), used.StringFixed(2), acc.OverdraftRate.String()),
+				})
+				charged++
+			}
+		}
+		storage.accounts[acc.ID] = acc
+	}
+	return charged
+}
+
+func AddTransaction(tx Transaction) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	appendTransaction(tx)
+}
+
+// appendTransaction добавляет транзакцию в журнал и индекс и учитывает её в итогах лимитов.
+// Вызывается под storage.mu.
+func appendTransaction(tx Transaction) {
+	storage.transactionIndex[tx.ID] = len(storage.transactions)
+	storage.transactions = append(storage.transactions, tx)
+	recordLimitUsage(tx, tx.Amount)
+}
+
+// markReversed отмечает транзакцию сторнированной и возвращает её сумму в лимиты.
+// Вызывается под storage.mu.
+func markReversed(orig Transaction, reversalID string) {
+	storage.reversals[orig.ID] = reversalID
+	recordLimitUsage(orig, orig.Amount.Neg())
+}
+
+func GetTransaction(txID string) (Transaction, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	i, ok := storage.transactionIndex[txID]
+	if !ok {
+		return Transaction{}, false
+	}
+	return storage.transactions[i], true
+}
+
+func GetAccountTransactions(accountID string) []Transaction {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	var accountTxs []Transaction
+	for _, tx := range storage.transactions {
+		if tx.FromAccountID == accountID || tx.ToAccountID == accountID {
+			accountTxs = append(accountTxs, tx)
+		}
+	}
+	return accountTxs
+}
+
+// computePANFingerprint вычисляет HMAC номера карты для поиска карты по PAN без его расшифровки
+func computePANFingerprint(pan string) string {
+    return GenerateHMAC("pan:"+pan, panFingerprintKey)
+}
+
+func GenerateHMAC(data string, key []byte) string {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+func AddCard(card Card) error {
+    storage.mu.Lock()
+    defer storage.mu.Unlock()
+
+    if _, exists := storage.accounts[card.AccountID]; !exists {
+        return fmt.Errorf("account %s not found", card.AccountID)
+    }
+
+    signCard(&card)
+    
+    storage.cards[card.ID] = card
+    storage.cardIndex[card.AccountID] = append(storage.cardIndex[card.AccountID], card.ID)
+    if card.PANFingerprint != "" {
+        storage.panIndex[card.PANFingerprint] = card.ID
+    }
+
+    return nil
+}
+
+// SetCardControls применяет к ограничениям карты заданные в запросе поля, не трогая остальные,
+// и возвращает итоговые ограничения
+func SetCardControls(cardID string, update UpdateCardControlsRequest) (CardControls, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	card, ok := storage.cards[cardID]
+	if !ok {
+		return CardControls{}, ErrCardNotFound
+	}
+	update.Apply(&card.Controls)
+	signCard(&card)
+	storage.cards[card.ID] = card
+	return card.Controls, nil
+}
+
+// CloseCard переводит карту в статус closed
+func CloseCard(cardID string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	card, ok := storage.cards[cardID]
+	if !ok {
+		return ErrCardNotFound
+	}
+	if card.Status == CardStatusClosed {
+		return ErrCardAlreadyClosed
+	}
+	card.Status = CardStatusClosed
+	signCard(&card)
+	storage.cards[card.ID] = card
+	return nil
+}
+
+// GetCardsNotEncryptedWith возвращает карты, номер которых зашифрован не ключом keyID
+func GetCardsNotEncryptedWith(keyID string) []Card {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	cards := make([]Card, 0)
+	for _, card := range storage.cards {
+		if card.KeyID != keyID {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// ReplaceCardNumber заменяет шифртекст номера карты, только если он не изменился с момента чтения
+func ReplaceCardNumber(cardID, oldNumber, newNumber, keyID string) bool {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	card, ok := storage.cards[cardID]
+	if !ok || card.Number != oldNumber {
+		return false
+	}
+	card.Number = newNumber
+	card.KeyID = keyID
+	signCard(&card)
+	storage.cards[card.ID] = card
+	return true
+}
+
+// CountCardsByKeyID считает карты по версиям ключа шифрования номера
+func CountCardsByKeyID() map[string]int {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, card := range storage.cards {
+		counts[card.KeyID]++
+	}
+	return counts
+}
+
+func GetAccountCards(accountID string) []Card {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	cardIDs := storage.cardIndex[accountID]
+	cards := make([]Card, 0, len(cardIDs))
+	for _, id := range cardIDs {
+		if card, ok := storage.cards[id]; ok {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// GetCardByPAN находит карту по открытому номеру через индекс отпечатков PAN
+func GetCardByPAN(pan string) (Card, bool) {
+	storage.mu.RLock()
+	cardID, ok := storage.panIndex[computePANFingerprint(pan)]
+	storage.mu.RUnlock()
+	if !ok {
+		return Card{}, false
+	}
+	return GetCardByID(cardID)
+}
+
+func GetCardByNumber(number string) (Card, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	for _, card := range storage.cards {
+		if card.Number == number {
+			return card, true
+		}
+	}
+	return Card{}, false
+}
+
+func AddLoan(loan Loan) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if _, exists := storage.users[loan.UserID]; !exists {
+		return fmt.Errorf("user %s not found", loan.UserID)
+	}
+	if _, exists := storage.accounts[loan.AccountID]; !exists {
+		return fmt.Errorf("account %s not found", loan.AccountID)
+	}
+	storage.loans[loan.ID] = loan
+	storage.loanIndex[loan.UserID] = append(storage.loanIndex[loan.UserID], loan.ID)
+	return nil
+}
+
+func GetUserLoans(userID string) []Loan {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	loanIDs := storage.loanIndex[userID]
+	loans := make([]Loan, 0, len(loanIDs))
+	for _, id := range loanIDs {
+		if loan, ok := storage.loans[id]; ok {
+			loans = append(loans, loan)
+		}
+	}
+	return loans
+}
+
+func GetLoan(loanID string) (Loan, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	loan, ok := storage.loans[loanID]
+	return loan, ok
+}
+
+func AddMerchant(merchant Merchant) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	acc, exists := storage.accounts[merchant.SettlementAccountID]
+	if !exists {
+		return fmt.Errorf("account %s not found", merchant.SettlementAccountID)
+	}
+	if acc.UserID != merchant.OwnerUserID {
+		return fmt.Errorf("account %s does not belong to user %s", merchant.SettlementAccountID, merchant.OwnerUserID)
+	}
+	storage.merchants[merchant.ID] = merchant
+	storage.merchantKeyIndex[merchant.APIKey] = merchant.ID
+	storage.acceptorIndex[merchant.AcceptorID] = merchant.ID
+	storage.merchantUserIndex[merchant.OwnerUserID] = append(storage.merchantUserIndex[merchant.OwnerUserID], merchant.ID)
+	return nil
+}
+
+func SetMerchantMCC(merchantID, mcc string) (Merchant, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	merchant, ok := storage.merchants[merchantID]
+	if !ok {
+		return Merchant{}, fmt.Errorf("merchant %s not found", merchantID)
+	}
+	merchant.MCC = mcc
+	storage.merchants[merchant.ID] = merchant
+	return merchant, nil
+}
+
+func GetMerchant(merchantID string) (Merchant, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	merchant, ok := storage.merchants[merchantID]
+	return merchant, ok
+}
+
+func GetMerchantByAPIKey(apiKey string) (Merchant, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	merchantID, ok := storage.merchantKeyIndex[apiKey]
+	if !ok {
+		return Merchant{}, false
+	}
+	merchant, ok := storage.merchants[merchantID]
+	return merchant, ok
+}
+
+func GetMerchantByAcceptorID(acceptorID string) (Merchant, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	merchantID, ok := storage.acceptorIndex[acceptorID]
+	if !ok {
+		return Merchant{}, false
+	}
+	merchant, ok := storage.merchants[merchantID]
+	return merchant, ok
+}
+
+func GetUserMerchants(userID string) []Merchant {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	merchantIDs := storage.merchantUserIndex[userID]
+	merchants := make([]Merchant, 0, len(merchantIDs))
+	for _, id := range merchantIDs {
+		if merchant, ok := storage.merchants[id]; ok {
+			merchants = append(merchants, merchant)
+		}
+	}
+	return merchants
+}
+
+// ChargeAccount списывает сумму со счёта плательщика и, если указан счёт получателя, зачисляет её туда в одной блокировке.
+// Необязательная проверка guard выполняется под той же блокировкой до движения средств.
+func ChargeAccount(tx Transaction, guard func() error) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if guard != nil {
+		if err := guard(); err != nil {
+			return err
+		}
+	}
+
+	from, ok := storage.accounts[tx.FromAccountID]
+	if !ok {
+		return fmt.Errorf("account %s not found", tx.FromAccountID)
+	}
+	if err := checkDebit(from, tx.Amount); err != nil {
+		return err
+	}
+
+	var to Account
+	if tx.ToAccountID != "" {
+		to, ok = storage.accounts[tx.ToAccountID]
+		if !ok {
+			return fmt.Errorf("account %s not found", tx.ToAccountID)
+		}
+		if err := checkCredit(to); err != nil {
+			return err
+		}
+	}
+
+	debitAccount(&from, tx.Amount)
+	storage.accounts[from.ID] = from
+	if tx.ToAccountID != "" {
+		creditAccount(&to, tx.Amount)
+		storage.accounts[to.ID] = to
+	}
+
+	appendTransaction(tx)
+	markCardUsed(tx.CardID)
+	if tx.CardTokenID != "" {
+		markCardTokenUsed(tx.CardTokenID, tx.Timestamp)
+	}
+	return nil
+}
+
+// markCardUsed закрывает одноразовую карту после первого списания. Вызывается под storage.mu.
+func markCardUsed(cardID string) {
+	card, ok := storage.cards[cardID]
+	if !ok || card.Type != CardTypeSingleUse {
+		return
+	}
+	card.Status = CardStatusClosed
+	signCard(&card)
+	storage.cards[card.ID] = card
+}
+
+// PlaceAuthorizationHold резервирует сумму авторизации, уменьшая доступный остаток счёта
+func PlaceAuthorizationHold(auth CardAuthorization, guard func() error) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if guard != nil {
+		if err := guard(); err != nil {
+			return err
+		}
+	}
+
+	acc, ok := storage.accounts[auth.AccountID]
+	if !ok {
+		return fmt.Errorf("account %s not found", auth.AccountID)
+	}
+	if err := checkDebit(acc, auth.Amount); err != nil {
+		return err
+	}
+
+	acc.AvailableBalance = acc.AvailableBalance.Sub(auth.Amount)
+	storage.accounts[acc.ID] = acc
+	storage.authorizations[auth.ID] = auth
+	recordHoldUsage(auth, auth.Amount)
+	if auth.TokenID != "" {
+		markCardTokenUsed(auth.TokenID, auth.CreatedAt)
+	}
+	return nil
+}
+
+// hasOpenAuthorization сообщает, есть ли по карте авторизация с неснятым холдом. Вызывается под storage.mu.
+func hasOpenAuthorization(cardID string) bool {
+	for _, auth := range storage.authorizations {
+		if auth.CardID == cardID && auth.HeldAmount().IsPositive() {
+			return true
+		}
+	}
+	return false
+}
+
+func GetAuthorization(authID string) (CardAuthorization, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	auth, ok := storage.authorizations[authID]
+	return auth, ok
+}
+
+// openAuthorization возвращает открытую авторизацию мерчанта; просроченная авторизация снимается.
+// Вызывается под storage.mu.
+func openAuthorization(authID, merchantID string, now time.Time) (CardAuthorization, error) {
+	auth, ok := storage.authorizations[authID]
+	if !ok || auth.MerchantID != merchantID {
+		return CardAuthorization{}, ErrAuthorizationNotFound
+	}
+	if auth.HeldAmount().IsZero() {
+		return CardAuthorization{}, ErrAuthorizationClosed
+	}
+	if now.After(auth.ExpiresAt) {
+		releaseAuthorization(&auth, AuthorizationStatusExpired)
+		return CardAuthorization{}, ErrAuthorizationClosed
+	}
+	return auth, nil
+}
+
+// releaseAuthorization снимает остаток холда и закрывает авторизацию с указанным статусом.
+// Вызывается под storage.mu.
+func releaseAuthorization(auth *CardAuthorization, status string) {
+	if held := auth.HeldAmount(); held.IsPositive() {
+		recordHoldUsage(*auth, held.Neg())
+		if acc, ok := storage.accounts[auth.AccountID]; ok {
+			acc.AvailableBalance = acc.AvailableBalance.Add(held)
+			storage.accounts[acc.ID] = acc
+		}
+	}
+	auth.Status = status
+	storage.authorizations[auth.ID] = *auth
+}
+
+// CaptureAuthorization списывает сумму по авторизации и зачисляет её мерчанту.
+// При final=true оставшаяся часть холда снимается, иначе авторизация остаётся открытой для следующих списаний.
+func CaptureAuthorization(authID, merchantID string, amount decimal.Decimal, final bool, tx Transaction) (CardAuthorization, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	auth, err := openAuthorization(authID, merchantID, tx.Timestamp)
+	if err != nil {
+		return CardAuthorization{}, err
+	}
+	if amount.GreaterThan(auth.HeldAmount()) {
+		return CardAuthorization{}, ErrCaptureExceedsAuthorization
+	}
+
+	from, ok := storage.accounts[auth.AccountID]
+	if !ok {
+		return CardAuthorization{}, fmt.Errorf("account %s not found", auth.AccountID)
+	}
+	merchant, ok := storage.merchants[auth.MerchantID]
+	if !ok {
+		return CardAuthorization{}, fmt.Errorf("merchant %s not found", auth.MerchantID)
+	}
+	to, ok := storage.accounts[merchant.SettlementAccountID]
+	if !ok {
+		return CardAuthorization{}, fmt.Errorf("account %s not found", merchant.SettlementAccountID)
+	}
+	if err := checkCredit(to); err != nil {
+		return CardAuthorization{}, err
+	}
+
+	// Доступный остаток уже уменьшен холдом, поэтому списывается только текущий остаток
+	from.Balance = from.Balance.Sub(amount)
+	storage.accounts[from.ID] = from
+	creditAccount(&to, amount)
+	storage.accounts[to.ID] = to
+
+	tx.FromAccountID = auth.AccountID
+	tx.ToAccountID = merchant.SettlementAccountID
+	tx.Amount = amount
+	tx.CardID = auth.CardID
+	tx.MerchantID = auth.MerchantID
+	tx.AuthorizationID = auth.ID
+	appendTransaction(tx)
+	markCardUsed(auth.CardID)
+
+	// Списанная часть холда учтена в лимитах транзакцией
+	recordHoldUsage(auth, amount.Neg())
+	auth.CapturedAmount = auth.CapturedAmount.Add(amount)
+	auth.TransactionIDs = append(auth.TransactionIDs, tx.ID)
+	if final || auth.CapturedAmount.Equal(auth.Amount) {
+		releaseAuthorization(&auth, AuthorizationStatusCaptured)
+	} else {
+		auth.Status = AuthorizationStatusPartiallyCaptured
+		storage.authorizations[auth.ID] = auth
+	}
+	return auth, nil
+}
+
+// VoidAuthorization отменяет авторизацию и снимает оставшийся холд
+func VoidAuthorization(authID, merchantID string) (CardAuthorization, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	auth, err := openAuthorization(authID, merchantID, time.Now())
+	if err != nil {
+		return CardAuthorization{}, err
+	}
+	status := AuthorizationStatusVoided
+	if auth.CapturedAmount.IsPositive() {
+		status = AuthorizationStatusCaptured
+	}
+	releaseAuthorization(&auth, status)
+	return auth, nil
+}
+
+// ExpireAuthorizations снимает холды по авторизациям с истёкшим сроком и возвращает их количество
+func ExpireAuthorizations(now time.Time) int {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	expired := 0
+	for _, auth := range storage.authorizations {
+		if auth.HeldAmount().IsPositive() && now.After(auth.ExpiresAt) {
+			releaseAuthorization(&auth, AuthorizationStatusExpired)
+			expired++
+		}
+	}
+	return expired
+}
+
+
+func isDisputeOpen(d Dispute) bool {
+	return d.Status == DisputeStatusOpened || d.Status == DisputeStatusProvisionalCredit
+}
+
+// refundableRemainder возвращает сумму оплаты, которую ещё можно вернуть: за вычетом возвратов,
+// выигранных клиентом и открытых споров. Вызывается под storage.mu.
+func refundableRemainder(orig Transaction) decimal.Decimal {
+	remainder := orig.Amount.Sub(storage.refundedAmounts[orig.ID])
+	for _, id := range storage.paymentDisputeIndex[orig.ID] {
+		if d := storage.disputes[id]; d.Status == DisputeStatusWon || isDisputeOpen(d) {
+			remainder = remainder.Sub(d.Amount)
+		}
+	}
+	return remainder
+}
+
+// paymentTransaction возвращает исходную карточную оплату по ID. Вызывается под storage.mu.
+func paymentTransaction(txID string) (Transaction, error) {
+	i, ok := storage.transactionIndex[txID]
+	if !ok || storage.transactions[i].TransactionType != "payment" {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	return storage.transactions[i], nil
+}
+
+// RefundTransaction возвращает клиенту полную или частичную сумму оплаты с расчётного счёта мерчанта
+func RefundTransaction(merchantID string, tx Transaction) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	orig, err := paymentTransaction(tx.OriginalTransactionID)
+	if err != nil || orig.MerchantID != merchantID {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	if tx.Amount.GreaterThan(refundableRemainder(orig)) {
+		return Transaction{}, ErrRefundExceedsOriginal
+	}
+
+	from, ok := storage.accounts[orig.ToAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("account %s not found", orig.ToAccountID)
+	}
+	to, ok := storage.accounts[orig.FromAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("account %s not found", orig.FromAccountID)
+	}
+	if err := checkCredit(to); err != nil {
+		return Transaction{}, err
+	}
+	if err := checkDebit(from, tx.Amount); err != nil {
+		return Transaction{}, err
+	}
+
+	debitAccount(&from, tx.Amount)
+	storage.accounts[from.ID] = from
+	creditAccount(&to, tx.Amount)
+	storage.accounts[to.ID] = to
+
+	tx.FromAccountID = from.ID
+	tx.ToAccountID = to.ID
+	tx.CardID = orig.CardID
+	tx.MerchantID = orig.MerchantID
+	tx.TransactionType = "refund"
+	appendTransaction(tx)
+	storage.refundedAmounts[orig.ID] = storage.refundedAmounts[orig.ID].Add(tx.Amount)
+	// Возврат освобождает лимиты карты за период исходной оплаты
+	addLimitUsage(cardLimitSubject(orig.CardID), LimitOpCardPayment, "", orig.Timestamp, tx.Amount.Neg())
+	return tx, nil
+}
+
+// OpenDispute регистрирует спор клиента по карточной оплате со своего счёта
+func OpenDispute(d Dispute) (Dispute, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	orig, err := paymentTransaction(d.TransactionID)
+	if err != nil || orig.FromAccountID != d.AccountID {
+		return Dispute{}, ErrTransactionNotFound
+	}
+	for _, id := range storage.paymentDisputeIndex[orig.ID] {
+		if isDisputeOpen(storage.disputes[id]) {
+			return Dispute{}, ErrDisputeAlreadyOpen
+		}
+	}
+
+	remainder := refundableRemainder(orig)
+	if d.Amount.IsZero() {
+		d.Amount = remainder
+	}
+	if !d.Amount.IsPositive() || d.Amount.GreaterThan(remainder) {
+		return Dispute{}, ErrRefundExceedsOriginal
+	}
+
+	d.MerchantID = orig.MerchantID
+	d.Status = DisputeStatusOpened
+	storage.disputes[d.ID] = d
+	storage.paymentDisputeIndex[orig.ID] = append(storage.paymentDisputeIndex[orig.ID], d.ID)
+	return d, nil
+}
+
+func GetDispute(disputeID string) (Dispute, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	d, ok := storage.disputes[disputeID]
+	return d, ok
+}
+
+// ListDisputes возвращает споры, отфильтрованные по пользователю и/или статусу (пустое значение — без фильтра)
+func ListDisputes(openedBy, status string) []Dispute {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	disputes := make([]Dispute, 0)
+	for _, d := range storage.disputes {
+		if (openedBy == "" || d.OpenedBy == openedBy) && (status == "" || d.Status == status) {
+			disputes = append(disputes, d)
+		}
+	}
+	return disputes
+}
+
+// GrantProvisionalCredit зачисляет клиенту временный кредит на сумму спора за счёт банка
+func GrantProvisionalCredit(disputeID string, now time.Time) (Dispute, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	d, ok := storage.disputes[disputeID]
+	if !ok {
+		return Dispute{}, ErrDisputeNotFound
+	}
+	if d.Status != DisputeStatusOpened {
+		return Dispute{}, ErrInvalidDisputeState
+	}
+	acc, ok := storage.accounts[d.AccountID]
+	if !ok {
+		return Dispute{}, fmt.Errorf("account %s not found", d.AccountID)
+	}
+	if err := checkCredit(acc); err != nil {
+		return Dispute{}, err
+	}
+
+	creditAccount(&acc, d.Amount)
+	storage.accounts[acc.ID] = acc
+
+	tx := Transaction{
+		ID:                    GenerateID(),
+		ToAccountID:           acc.ID,
+		Amount:                d.Amount,
+		Timestamp:             now,
+		TransactionType:       "chargeback_provisional_credit",
+		Description:           fmt.Sprintf("Provisional credit for dispute %s", d.ID),
+		MerchantID:            d.MerchantID,
+		OriginalTransactionID: d.TransactionID,
+	}
+	appendTransaction(tx)
+
+	d.Status = DisputeStatusProvisionalCredit
+	d.ProvisionalCreditTxID = tx.ID
+	d.UpdatedAt = now
+	storage.disputes[d.ID] = d
+	return d, nil
+}
+
+// ResolveDispute закрывает спор и проводит итоговые движения средств:
+// при выигрыше клиента сумма списывается с мерчанта (клиенту, либо банку в счёт временного кредита),
+// при проигрыше временный кредит списывается обратно с клиента
+func ResolveDispute(disputeID, outcome, operatorID, note string, now time.Time) (Dispute, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	d, ok := storage.disputes[disputeID]
+	if !ok {
+		return Dispute{}, ErrDisputeNotFound
+	}
+	if !isDisputeOpen(d) {
+		return Dispute{}, ErrInvalidDisputeState
+	}
+	customer, ok := storage.accounts[d.AccountID]
+	if !ok {
+		return Dispute{}, fmt.Errorf("account %s not found", d.AccountID)
+	}
+	orig := storage.transactions[storage.transactionIndex[d.TransactionID]]
+	provisional := d.Status == DisputeStatusProvisionalCredit
+
+	tx := Transaction{
+		ID:                    GenerateID(),
+		Amount:                d.Amount,
+		Timestamp:             now,
+		MerchantID:            d.MerchantID,
+		CardID:                orig.CardID,
+		OriginalTransactionID: d.TransactionID,
+	}
+
+	switch outcome {
+	case DisputeStatusWon:
+		tx.TransactionType = "chargeback"
+		tx.Description = fmt.Sprintf("Chargeback for dispute %s", d.ID)
+		if orig.ToAccountID != "" {
+			merchantAcc, ok := storage.accounts[orig.ToAccountID]
+			if !ok {
+				return Dispute{}, fmt.Errorf("account %s not found", orig.ToAccountID)
+			}
+			// Чарджбэк списывается с мерчанта безусловно, остаток может стать отрицательным
+			debitAccount(&merchantAcc, d.Amount)
+			storage.accounts[merchantAcc.ID] = merchantAcc
+			tx.FromAccountID = merchantAcc.ID
+		}
+		if !provisional {
+			creditAccount(&customer, d.Amount)
+			storage.accounts[customer.ID] = customer
+			tx.ToAccountID = customer.ID
+		}
+	case DisputeStatusLost:
+		if provisional {
+			tx.TransactionType = "chargeback_reversal"
+			tx.Description = fmt.Sprintf("Reversal of provisional credit for dispute %s", d.ID)
+			debitAccount(&customer, d.Amount)
+			storage.accounts[customer.ID] = customer
+			tx.FromAccountID = customer.ID
+		}
+	default:
+		return Dispute{}, fmt.Errorf("unknown dispute outcome %q", outcome)
+	}
+
+	if tx.TransactionType != "" && (tx.FromAccountID != "" || tx.ToAccountID != "") {
+		appendTransaction(tx)
+		d.ResolutionTxID = tx.ID
+	}
+
+	d.Status = outcome
+	d.ResolvedBy = operatorID
+	d.ResolutionNote = note
+	d.UpdatedAt = now
+	storage.disputes[d.ID] = d
+	return d, nil
+}
+
+
+// VerifyCardPIN сверяет хеш PIN-кода с сохранённым, считая неудачные попытки.
+// После maxPINAttempts неверных попыток PIN блокируется; успешная проверка сбрасывает счётчик.
+func VerifyCardPIN(cardID, pinHash string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	card, ok := storage.cards[cardID]
+	if !ok {
+		return fmt.Errorf("card %s not found", cardID)
+	}
+	if card.PINBlocked {
+		return ErrPINBlocked
+	}
+	if card.PINHash == "" {
+		return ErrPINNotSet
+	}
+
+	if hmac.Equal([]byte(card.PINHash), []byte(pinHash)) {
+		if card.PINAttempts > 0 {
+			card.PINAttempts = 0
+			signCard(&card)
+			storage.cards[card.ID] = card
+		}
+		return nil
+	}
+
+	card.PINAttempts++
+	if card.PINAttempts >= maxPINAttempts {
+		card.PINBlocked = true
+	}
+	signCard(&card)
+	storage.cards[card.ID] = card
+	if card.PINBlocked {
+		return ErrPINBlocked
+	}
+	return ErrIncorrectPIN
+}
+
+// SetCardPIN сохраняет новый хеш PIN-кода, сбрасывая счётчик попыток и блокировку
+func SetCardPIN(cardID, pinHash string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	card, ok := storage.cards[cardID]
+	if !ok {
+		return fmt.Errorf("card %s not found", cardID)
+	}
+	card.PINHash = pinHash
+	card.PINAttempts = 0
+	card.PINBlocked = false
+	signCard(&card)
+	storage.cards[card.ID] = card
+	return nil
+}
+
+// AddCardToken сохраняет токен в хранилище токенов
+func AddCardToken(t CardToken) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if _, exists := storage.tokenValueIndex[t.Token]; exists {
+		return fmt.Errorf("card token collision")
+	}
+	storage.cardTokens[t.ID] = t
+	storage.tokenValueIndex[t.Token] = t.ID
+	storage.cardTokenIndex[t.CardID] = append(storage.cardTokenIndex[t.CardID], t.ID)
+	return nil
+}
+
+func GetCardToken(tokenID string) (CardToken, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	t, ok := storage.cardTokens[tokenID]
+	return t, ok
+}
+
+// GetCardTokenByValue ищет токен по значению, которое хранит мерчант; удалённые токены не находятся
+func GetCardTokenByValue(token string) (CardToken, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	id, ok := storage.tokenValueIndex[token]
+	if !ok {
+		return CardToken{}, false
+	}
+	t, ok := storage.cardTokens[id]
+	return t, ok
+}
+
+func GetCardTokens(cardID string) []CardToken {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	tokens := make([]CardToken, 0)
+	for _, id := range storage.cardTokenIndex[cardID] {
+		if t, ok := storage.cardTokens[id]; ok {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// SetCardTokenStatus приостанавливает, возобновляет или удаляет токен.
+// Удаление необратимо: значение токена убирается из индекса и больше не принимается.
+func SetCardTokenStatus(tokenID, status string, now time.Time) (CardToken, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	t, ok := storage.cardTokens[tokenID]
+	if !ok {
+		return CardToken{}, ErrCardTokenNotFound
+	}
+	if t.Status == CardTokenStatusDeleted {
+		return CardToken{}, ErrCardTokenDeleted
+	}
+	t.Status = status
+	t.UpdatedAt = now
+	if status == CardTokenStatusDeleted {
+		delete(storage.tokenValueIndex, t.Token)
+	}
+	storage.cardTokens[t.ID] = t
+	return t, nil
+}
+
+// markCardTokenUsed отмечает время последней оплаты токеном. Вызывается под storage.mu.
+func markCardTokenUsed(tokenID string, now time.Time) {
+	t, ok := storage.cardTokens[tokenID]
+	if !ok {
+		return
+	}
+	t.LastUsedAt = &now
+	storage.cardTokens[t.ID] = t
+}
+
+// ExecuteTransfer атомарно переводит сумму между счетами банка
+func ExecuteTransfer(fromAccountID, toAccountID string, amount decimal.Decimal, description string) (Transaction, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	return transferLocked(fromAccountID, toAccountID, amount, description)
+}
+
+// transferLocked переводит средства между счетами и записывает транзакцию.
+// Вызывается под storage.mu.
+func transferLocked(fromAccountID, toAccountID string, amount decimal.Decimal, description string) (Transaction, error) {
+	if fromAccountID == toAccountID {
+		return Transaction{}, ErrSameAccount
+	}
+	from, ok := storage.accounts[fromAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("source %w: %s", ErrAccountNotFound, fromAccountID)
+	}
+	to, ok := storage.accounts[toAccountID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("destination %w: %s", ErrAccountNotFound, toAccountID)
+	}
+	if err := checkCredit(to); err != nil {
+		return Transaction{}, err
+	}
+	// Лимиты не действуют на переводы между своими счетами; счета организации своими для подписанта не считаются
+	if !limitExempt(from, to) {
+		op := LimitedOperation{Type: LimitOpTransfer, Counterparty: to.ID, Amount: amount}
+		if err := checkLimits(from, []LimitedOperation{op}, time.Now()); err != nil {
+			return Transaction{}, err
+		}
+	}
+	if err := checkDebit(from, amount); err != nil {
+		return Transaction{}, err
+	}
+
+	debitAccount(&from, amount)
+	creditAccount(&to, amount)
+	storage.accounts[from.ID] = from
+	storage.accounts[to.ID] = to
+
+	if description == "" {
+		description = fmt.Sprintf("Transfer from %s to %s", from.Number, to.Number)
+	}
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   from.ID,
+		ToAccountID:     to.ID,
+		Amount:          amount,
+		Timestamp:       time.Now(),
+		TransactionType: "transfer",
+		Description:     description,
+	}
+	appendTransaction(tx)
+	return tx, nil
+}
+
+// BatchTransfer — строка пакетного перевода с уже найденным счётом получателя
+type BatchTransfer struct {
+	ToAccountID string
+	Amount      decimal.Decimal
+	Description string
+}
+
+// BatchTransferResult — итог исполнения одной строки пакета
+type BatchTransferResult struct {
+	Transaction Transaction
+	Err         error
+}
+
+// ExecuteTransferBatch исполняет пакет переводов с одного счёта под одной блокировкой.
+// В атомарном режиме сначала проверяются все получатели и общая сумма: пакет
+// проводится целиком либо не проводится вовсе (тогда возвращается ошибка пакета).
+// В режиме best-effort каждая строка проводится независимо.
+func ExecuteTransferBatch(fromAccountID string, transfers []BatchTransfer, atomic bool) ([]BatchTransferResult, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	from, ok := storage.accounts[fromAccountID]
+	if !ok {
+		return nil, fmt.Errorf("source %w: %s", ErrAccountNotFound, fromAccountID)
+	}
+	if atomic {
+		total := decimal.Zero
+		ops := make([]LimitedOperation, 0, len(transfers))
+		for _, t := range transfers {
+			if t.ToAccountID == fromAccountID {
+				return nil, ErrSameAccount
+			}
+			to, ok := storage.accounts[t.ToAccountID]
+			if !ok {
+				return nil, fmt.Errorf("destination %w: %s", ErrAccountNotFound, t.ToAccountID)
+			}
+			if err := checkCredit(to); err != nil {
+				return nil, err
+			}
+			if !limitExempt(from, to) {
+				ops = append(ops, LimitedOperation{Type: LimitOpTransfer, Counterparty: to.ID, Amount: t.Amount})
+			}
+			total = total.Add(t.Amount)
+		}
+		if err := checkLimits(from, ops, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := checkDebit(from, total); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]BatchTransferResult, len(transfers))
+	for i, t := range transfers {
+		tx, err := transferLocked(fromAccountID, t.ToAccountID, t.Amount, t.Description)
+		results[i] = BatchTransferResult{Transaction: tx, Err: err}
+	}
+	return results, nil
+}
+
+// Типы транзакций, которые оператор может сторнировать. Карточные оплаты
+// возвращаются через возвраты и споры, сторно не сторнируется повторно.
+var reversibleTransactionTypes = map[string]bool{
+	"deposit":        true,
+	"transfer":       true,
+	"atm_withdrawal": true,
+}
+
+// ReverseTransaction проводит сторно: компенсирующую транзакцию на всю сумму исходной
+// со счёта получателя обратно на счёт отправителя. Если на счёте получателя не хватает
+// средств, сумма всё равно списывается, остаток уходит в минус, а недостача ставится
+// в RecoveryHold. Возвращает сторнирующую транзакцию и сумму недостачи.
+func ReverseTransaction(txID, operatorID, reason string, now time.Time) (Transaction, decimal.Decimal, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	i, ok := storage.transactionIndex[txID]
+	if !ok {
+		return Transaction{}, decimal.Zero, ErrTransactionNotFound
+	}
+	orig := storage.transactions[i]
+	if !reversibleTransactionTypes[orig.TransactionType] {
+		return Transaction{}, decimal.Zero, ErrNotReversible
+	}
+	if _, reversed := storage.reversals[orig.ID]; reversed {
+		return Transaction{}, decimal.Zero, ErrAlreadyReversed
+	}
+
+	tx := Transaction{
+		ID:              GenerateID(),
+		FromAccountID:   orig.ToAccountID,
+		ToAccountID:     orig.FromAccountID,
+		Amount:          orig.Amount,
+		Timestamp:       now,
+		TransactionType: "reversal",
+		Description:     fmt.Sprintf("Reversal of transaction %s: %s", orig.ID, reason),
+		CardID:          orig.CardID,
+		ReversalOf:      orig.ID,
+		OperatorID:      operatorID,
+		Reason:          reason,
+	}
+
+	var debited, credited Account
+	if tx.FromAccountID != "" {
+		if debited, ok = storage.accounts[tx.FromAccountID]; !ok {
+			return Transaction{}, decimal.Zero, fmt.Errorf("%w: %s", ErrAccountNotFound, tx.FromAccountID)
+		}
+	}
+	if tx.ToAccountID != "" {
+		if credited, ok = storage.accounts[tx.ToAccountID]; !ok {
+			return Transaction{}, decimal.Zero, fmt.Errorf("%w: %s", ErrAccountNotFound, tx.ToAccountID)
+		}
+		if err := checkCredit(credited); err != nil {
+			return Transaction{}, decimal.Zero, err
+		}
+	}
+
+	shortfall := decimal.Zero
+	if tx.FromAccountID != "" {
+		// Недостачей считается только та часть суммы, которой не было на счёте
+		available := decimal.Max(decimal.Zero, debited.AvailableBalance)
+		if available.LessThan(tx.Amount) {
+			shortfall = tx.Amount.Sub(available)
+			debited.RecoveryHold = debited.RecoveryHold.Add(shortfall)
+		}
+		debitAccount(&debited, tx.Amount)
+		storage.accounts[debited.ID] = debited
+	}
+	if tx.ToAccountID != "" {
+		creditAccount(&credited, tx.Amount)
+		storage.accounts[credited.ID] = credited
+	}
+
+	appendTransaction(tx)
+	markReversed(orig, tx.ID)
+	return tx, shortfall, nil
+}
+
+func SetUserTier(userID, tier string) (User, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	user, ok := storage.users[userID]
+	if !ok {
+		return User{}, fmt.Errorf("user %s not found", userID)
+	}
+	user.Tier = tier
+	storage.users[userID] = user
+	return user, nil
+}
+
+func SetUserRole(userID, role string) (User, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	user, ok := storage.users[userID]
+	if !ok {
+		return User{}, fmt.Errorf("user %s not found", userID)
+	}
+	user.Role = role
+	storage.users[userID] = user
+	return user, nil
+}
+
+func SaveStandingOrder(order StandingOrder) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.standingOrders[order.ID] = order
+}
+
+func GetStandingOrder(orderID string) (StandingOrder, bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	order, ok := storage.standingOrders[orderID]
+	return order, ok
+}
+
+func GetUserStandingOrders(userID string) []StandingOrder {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	orders := make([]StandingOrder, 0)
+	for _, order := range storage.standingOrders {
+		if order.UserID == userID {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.Before(orders[j].CreatedAt) })
+	return orders
+}
+
+// GetAccountStandingOrders возвращает поручения со счёта независимо от того, кто их создал
+func GetAccountStandingOrders(accountID string) []StandingOrder {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	orders := make([]StandingOrder, 0)
+	for _, order := range storage.standingOrders {
+		if order.FromAccountID == accountID {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.Before(orders[j].CreatedAt) })
+	return orders
+}
+
+// DueStandingOrders возвращает активные поручения, срок исполнения которых наступил
+func DueStandingOrders(now time.Time) []StandingOrder {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	orders := make([]StandingOrder, 0)
+	for _, order := range storage.standingOrders {
+		if order.Status == StandingOrderStatusActive && order.NextRunAt != nil && !order.NextRunAt.After(now) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].NextRunAt.Before(*orders[j].NextRunAt) })
+	return orders
+}
+
+func AddStandingOrderExecution(exec StandingOrderExecution) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.standingOrderExecutions[exec.OrderID] = append(storage.standingOrderExecutions[exec.OrderID], exec)
+}
+
+func GetStandingOrderExecutions(orderID string) []StandingOrderExecution {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	executions := make([]StandingOrderExecution, len(storage.standingOrderExecutions[orderID]))
+	copy(executions, storage.standingOrderExecutions[orderID])
+	return executions
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAddAccountUniqueNumbers(t *testing.T) {
+	InitStorage()
+	bankRequisites.BIK = defaultBankBIK
+	defer func() { bankRequisites.BIK = "" }()
+
+	user := User{ID: GenerateID(), Username: "owner"}
+	if err := AddUser(user); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	numbers := make(map[string]bool)
+	for i := 0; i < 20000; i++ {
+		account, err := AddAccount(Account{ID: GenerateID(), UserID: user.ID, Number: GenerateAccountNumber(BalanceAccountPersonal)})
+		if err != nil {
+			t.Fatalf("AddAccount #%d: %v", i, err)
+		}
+		if numbers[account.Number] {
+			t.Fatalf("AddAccount #%d: number %s is already taken", i, account.Number)
+		}
+		numbers[account.Number] = true
+	}
+
+	// Занятый номер заменяется новым на том же балансовом счёте
+	var taken string
+	for number := range numbers {
+		taken = number
+		break
+	}
+	account, err := AddAccount(Account{ID: GenerateID(), UserID: user.ID, Number: taken})
+	if err != nil {
+		t.Fatalf("AddAccount with taken number: %v", err)
+	}
+	if account.Number == taken || numbers[account.Number] {
+		t.Fatalf("AddAccount with taken number %s: got %s, want a new number", taken, account.Number)
+	}
+	if account.Number[:5] != BalanceAccountPersonal || !accountKeyValid(bankRequisites.BIK, account.Number) {
+		t.Fatalf("AddAccount with taken number: got %s, want a valid %s account number", account.Number, BalanceAccountPersonal)
+	}
+	if got, ok := GetAccountByNumber(account.Number); !ok || got.ID != account.ID {
+		t.Fatalf("GetAccountByNumber(%s) = %v, %v; want account %s", account.Number, got.ID, ok, account.ID)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Коды отказа при оплате токеном
+const (
+	DeclineTokenNotFound  = "token_not_found"
+	DeclineTokenSuspended = "token_suspended"
+)
+
+// Префикс значения токена, чтобы его нельзя было спутать с номером карты
+const cardTokenPrefix = "tok_"
+
+// checkCardToken проверяет, что токен действует и выпущен для этого мерчанта.
+// Токен чужого мерчанта неотличим от несуществующего.
+func checkCardToken(t CardToken, merchantID string) error {
+	if t.ID == "" || t.Status == CardTokenStatusDeleted || t.MerchantID != merchantID {
+		return declinePayment(http.StatusNotFound, DeclineTokenNotFound, "Card token not found")
+	}
+	if t.Status != CardTokenStatusActive {
+		return declinePayment(http.StatusForbidden, DeclineTokenSuspended, "Card token is suspended")
+	}
+	return nil
+}
+
+// resolveCardToken находит карту по токену мерчанта. CVV при оплате токеном не требуется,
+// но статус и срок действия карты проверяются как при обычной оплате.
+func resolveCardToken(token, merchantID string) (Card, CardToken, error) {
+	t, _ := GetCardTokenByValue(token)
+	if err := checkCardToken(t, merchantID); err != nil {
+		return Card{}, CardToken{}, err
+	}
+	card, ok := GetCardByID(t.CardID)
+	if !ok {
+		return Card{}, CardToken{}, declinePayment(http.StatusNotFound, DeclineCardNotFound, "Card not found")
+	}
+	return card, t, checkCardActive(card)
+}
+
+// CreateCardTokenHandler выпускает мерчанту токен вместо реквизитов карты для повторных оплат
+func CreateCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req CreateCardTokenRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	card, err := verifyCardForPayment(req.CardNumber, req.CVV)
+	if err != nil {
+		respondPaymentError(w, err)
+		return
+	}
+	if card.Type == CardTypeSingleUse {
+		respondError(w, http.StatusUnprocessableEntity, "Single-use cards cannot be tokenized")
+		return
+	}
+	if card.LockedMerchantID != "" && card.LockedMerchantID != merchant.ID {
+		respondPaymentError(w, declinePayment(http.StatusForbidden, DeclineMerchantNotAllowed, "Card is locked to a different merchant"))
+		return
+	}
+
+	now := time.Now()
+	t := CardToken{
+		ID:         GenerateID(),
+		Token:      cardTokenPrefix + GenerateSecureToken(24),
+		CardID:     card.ID,
+		MerchantID: merchant.ID,
+		Status:     CardTokenStatusActive,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := AddCardToken(t); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create card token: %v", err))
+		return
+	}
+
+	log.Printf("Card token %s issued to merchant %s", t.ID, merchant.ID)
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"token_id":     t.ID,
+		"token":        t.Token,
+		"status":       t.Status,
+		"expiry_month": card.ExpiryMonth,
+		"expiry_year":  card.ExpiryYear,
+		"created_at":   t.CreatedAt,
+	})
+}
+
+// DeleteMerchantCardTokenHandler удаляет токен по запросу мерчанта, которому он выпущен
+func DeleteMerchantCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	merchant, ok := r.Context().Value(merchantContextKey).(Merchant)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Merchant not found in context")
+		return
+	}
+
+	t, ok := GetCardToken(mux.Vars(r)["tokenId"])
+	if !ok || t.MerchantID != merchant.ID {
+		respondError(w, http.StatusNotFound, "Card token not found")
+		return
+	}
+	updateCardTokenStatus(w, t, CardTokenStatusDeleted)
+}
+
+func GetCardTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	card, ok := ownedCard(mux.Vars(r)["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, GetCardTokens(card.ID))
+}
+
+func SuspendCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ownedCardTokenStatusHandler(w, r, CardTokenStatusSuspended)
+}
+
+func ResumeCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ownedCardTokenStatusHandler(w, r, CardTokenStatusActive)
+}
+
+func DeleteCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ownedCardTokenStatusHandler(w, r, CardTokenStatusDeleted)
+}
+
+// ownedCardTokenStatusHandler меняет статус токена карты клиента; сама карта при этом не затрагивается
+func ownedCardTokenStatusHandler(w http.ResponseWriter, r *http.Request, status string) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	card, ok := ownedCard(vars["cardId"], userID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Card not found or access denied")
+		return
+	}
+
+	t, ok := GetCardToken(vars["tokenId"])
+	if !ok || t.CardID != card.ID {
+		respondError(w, http.StatusNotFound, "Card token not found")
+		return
+	}
+	updateCardTokenStatus(w, t, status)
+}
+
+func updateCardTokenStatus(w http.ResponseWriter, t CardToken, status string) {
+	updated, err := SetCardTokenStatus(t.ID, status, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrCardTokenNotFound):
+			respondError(w, http.StatusNotFound, "Card token not found")
+		case errors.Is(err, ErrCardTokenDeleted):
+			respondError(w, http.StatusConflict, "Card token is deleted")
+		default:
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update card token: %v", err))
+		}
+		return
+	}
+
+	log.Printf("Card token %s is now %s", updated.ID, updated.Status)
+	respondJSON(w, http.StatusOK, updated)
+}
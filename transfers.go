@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Типы идентификаторов получателя перевода
+const (
+	RecipientTypeAccountNumber = "account_number"
+	RecipientTypeCardNumber    = "card_number"
+	RecipientTypePhone         = "phone"
+)
+
+var ErrRecipientNotFound = errors.New("recipient not found")
+
+// TransferRecipient — получатель перевода, найденный по номеру счёта, карты или телефону.
+// Клиенту показываются только замаскированные данные.
+type TransferRecipient struct {
+	Type          string `json:"recipient_type"`
+	MaskedName    string `json:"masked_name"`
+	MaskedAccount string `json:"masked_account"`
+	AccountID     string `json:"-"`
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// detectRecipientType определяет тип идентификатора по формату
+func detectRecipientType(identifier string) string {
+	compact := strings.ReplaceAll(identifier, " ", "")
+	switch {
+	case isDigits(compact) && len(compact) == accountNumberLength:
+		return RecipientTypeAccountNumber
+	case isDigits(compact) && len(compact) >= 13 && len(compact) <= 19 && ValidateCardNumberLuhn(compact):
+		return RecipientTypeCardNumber
+	default:
+		if _, ok := NormalizePhone(identifier); ok {
+			return RecipientTypePhone
+		}
+	}
+	return ""
+}
+
+// ResolveRecipient находит счёт получателя по номеру счёта, номеру карты или телефону.
+// По телефону перевод зачисляется на основной (первый открытый) счёт клиента.
+func ResolveRecipient(identifier, recipientType string) (TransferRecipient, error) {
+	identifier = strings.TrimSpace(identifier)
+	if recipientType == "" {
+		recipientType = detectRecipientType(identifier)
+	}
+
+	var account Account
+	var ok bool
+	switch recipientType {
+	case RecipientTypeAccountNumber:
+		account, ok = GetAccountByNumber(strings.ReplaceAll(identifier, " ", ""))
+	case RecipientTypeCardNumber:
+		if card, found := GetCardByPAN(strings.ReplaceAll(identifier, " ", "")); found && card.Status == CardStatusActive {
+			account, ok = GetAccount(card.AccountID)
+		}
+	case RecipientTypePhone:
+		if phone, valid := NormalizePhone(identifier); valid {
+			// Номер, привязанный в СБП, зачисляется на выбранный клиентом счёт
+			if alias, linked := GetSBPAlias(phone); linked {
+				account, ok = GetAccount(alias.AccountID)
+			} else if user, found := GetUserByPhone(phone); found {
+				account, ok = GetPrimaryAccount(user.ID)
+			}
+		}
+	}
+	if !ok || account.Status == AccountStatusClosed {
+		return TransferRecipient{}, ErrRecipientNotFound
+	}
+
+	// Наименование организации не маскируется: оно публично
+	name := ""
+	if org, found := GetOrganization(account.OrganizationID); found {
+		name = org.Name
+	} else {
+		user, _ := GetUser(account.UserID)
+		name = maskRecipientName(user)
+	}
+	return TransferRecipient{
+		Type:          recipientType,
+		MaskedName:    name,
+		MaskedAccount: maskAccountNumber(account.Number),
+		AccountID:     account.ID,
+	}, nil
+}
+
+// transferDestination возвращает ID счёта получателя: внутренний ID счёта
+// либо счёт, найденный по номеру счёта, карты или телефону
+func transferDestination(toAccountID, to, toType string) (string, error) {
+	if to == "" {
+		return toAccountID, nil
+	}
+	recipient, err := ResolveRecipient(to, toType)
+	if err != nil {
+		return "", err
+	}
+	return recipient.AccountID, nil
+}
+
+// transferErrorResponse сопоставляет ошибку перевода HTTP-статусу и сообщению для клиента
+func transferErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrAccountFrozen):
+		return http.StatusForbidden, "Account is frozen"
+	case errors.Is(err, ErrAccountClosed):
+		return http.StatusConflict, "Account is closed"
+	case errors.Is(err, ErrFundsArrested):
+		return http.StatusPaymentRequired, "Amount exceeds funds not under arrest"
+	case errors.Is(err, ErrOverdraftLimitExceeded):
+		return http.StatusPaymentRequired, "Overdraft limit exceeded"
+	case errors.Is(err, ErrInsufficientFunds):
+		return http.StatusPaymentRequired, "Insufficient funds in source account"
+	case errors.Is(err, ErrRecipientNotFound):
+		return http.StatusNotFound, "Recipient not found"
+	case errors.Is(err, ErrAccountNotFound):
+		return http.StatusNotFound, "Destination account not found"
+	case errors.Is(err, ErrSameAccount):
+		return http.StatusBadRequest, "Source and destination accounts must differ"
+	case errors.Is(err, ErrLimitExceeded):
+		return http.StatusForbidden, "Amount exceeds " + err.Error()
+	default:
+		return http.StatusInternalServerError, "Failed to process transfer: " + err.Error()
+	}
+}
+
+func respondTransferError(w http.ResponseWriter, err error) {
+	status, message := transferErrorResponse(err)
+	respondError(w, status, message)
+}
+
+// maskRecipientName показывает имя и первую букву фамилии («Иван П.»);
+// если имя не указано — первые буквы логина
+func maskRecipientName(user User) string {
+	if user.FirstName != "" {
+		name := user.FirstName
+		if initial, _ := utf8.DecodeRuneInString(user.LastName); initial != utf8.RuneError {
+			name += " " + string(initial) + "."
+		}
+		return name
+	}
+	runes := []rune(user.Username)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:2]) + strings.Repeat("*", len(runes)-2)
+}
+
+func maskAccountNumber(number string) string {
+	if len(number) < 4 {
+		return "****"
+	}
+	return "**** " + number[len(number)-4:]
+}
+
+// ResolveRecipientHandler показывает клиенту получателя перевода для подтверждения перед отправкой
+func ResolveRecipientHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req ResolveRecipientRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	recipient, err := ResolveRecipient(req.Recipient, req.RecipientType)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Recipient not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, recipient)
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,9 +16,38 @@ func GenerateID() string {
 	return uuid.NewString()
 }
 
-func GenerateAccountNumber() string {
-	n, _ := rand.Int(rand.Reader, big.NewInt(9000000000))
-	return fmt.Sprintf("40817810%010d", n.Int64()+1000000000)
+// GenerateSecureToken возвращает криптографически случайную строку из n байт в hex
+func GenerateSecureToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// GenerateAcceptorID генерирует 15-символьный идентификатор ТСП для поля 42 ISO 8583
+func GenerateAcceptorID() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1000000000000))
+	return fmt.Sprintf("MRC%012d", n.Int64())
+}
+
+// Балансовые счета второго порядка, с которых начинается номер счёта
+const (
+	BalanceAccountPersonal   = "40817" // счета физических лиц
+	BalanceAccountSettlement = "40702" // расчётные счета коммерческих организаций
+)
+
+// Длина номера счёта по плану счетов ЦБ РФ: балансовый счёт (5), код валюты (3), контрольный ключ (1),
+// код подразделения банка (4) и номер лицевого счёта (7)
+const accountNumberLength = 20
+
+// GenerateAccountNumber генерирует 20-значный номер рублёвого счёта на указанном балансовом счёте
+// с контрольным ключом по БИК банка. Подразделений у банка нет, поэтому случайны все 11 цифр
+// после ключа; уникальность номера проверяет AddAccount.
+func GenerateAccountNumber(balanceAccount string) string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(100000000000))
+	number := fmt.Sprintf("%s8100%011d", balanceAccount, n.Int64())
+	return number[:accountKeyIndex] + string(accountKey(bankRequisites.BIK, number)) + number[accountKeyIndex+1:]
 }
 
 func GenerateCardNumber() string {
@@ -39,6 +70,41 @@ func GenerateExpiryDate() (int, int) {
 	return month, year
 }
 
+// GenerateExpiryDateIn возвращает месяц и год окончания срока действия через указанное число месяцев
+func GenerateExpiryDateIn(months int) (int, int) {
+	// Считаем от первого числа, иначе AddDate переносит 31 января на март
+	now := time.Now()
+	expiry := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, months, 0)
+	return int(expiry.Month()), expiry.Year()
+}
+
+// NormalizePhone приводит номер телефона к формату E.164. Российские номера допускаются
+// в виде 8XXXXXXXXXX, 7XXXXXXXXXX и XXXXXXXXXX; пробелы, скобки и дефисы игнорируются.
+func NormalizePhone(phone string) (string, bool) {
+	digits := make([]byte, 0, len(phone))
+	for i := 0; i < len(phone); i++ {
+		switch c := phone[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '(' || c == ')' || (c == '+' && i == 0):
+		default:
+			return "", false
+		}
+	}
+
+	d := string(digits)
+	switch {
+	case !strings.HasPrefix(phone, "+") && len(d) == 11 && (d[0] == '8' || d[0] == '7'):
+		d = "7" + d[1:]
+	case !strings.HasPrefix(phone, "+") && len(d) == 10 && d[0] == '9':
+		d = "7" + d
+	case strings.HasPrefix(phone, "+") && len(d) >= 10 && len(d) <= 15 && d[0] != '0':
+	default:
+		return "", false
+	}
+	return "+" + d, true
+}
+
 func CalculateMonthlyPayment(loanAmount decimal.Decimal, annualRate decimal.Decimal, termMonths int) decimal.Decimal {
 	if termMonths <= 0 {
 		return decimal.Zero
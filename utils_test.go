@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"+79161234567", "+79161234567", true},
+		{"89161234567", "+79161234567", true},
+		{"79161234567", "+79161234567", true},
+		{"9161234567", "+79161234567", true},
+		{"8 (916) 123-45-67", "+79161234567", true},
+		{"+7 916 123 45 67", "+79161234567", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"+123456789012345", "+123456789012345", true},
+		{"", "", false},
+		{"+7916", "", false},
+		{"1234567890", "", false},
+		{"59161234567", "", false},
+		{"+1234567890123456", "", false},
+		{"+0123456789", "", false},
+		{"7+9161234567", "", false},
+		{"8.916.123.45.67", "", false},
+		{"+7916123456a", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := NormalizePhone(tt.in)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("NormalizePhone(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateAccountNumber(t *testing.T) {
+	bankRequisites.BIK = defaultBankBIK
+	defer func() { bankRequisites.BIK = "" }()
+
+	for _, balanceAccount := range []string{BalanceAccountPersonal, BalanceAccountSettlement} {
+		for i := 0; i < 100; i++ {
+			number := GenerateAccountNumber(balanceAccount)
+			if len(number) != accountNumberLength || !isDigits(number) {
+				t.Fatalf("GenerateAccountNumber(%s) = %q, want %d digits", balanceAccount, number, accountNumberLength)
+			}
+			if number[:5] != balanceAccount || number[5:8] != "810" {
+				t.Fatalf("GenerateAccountNumber(%s) = %q, want balance account and currency 810", balanceAccount, number)
+			}
+			if !accountKeyValid(bankRequisites.BIK, number) {
+				t.Fatalf("GenerateAccountNumber(%s) = %q, control key does not match BIK %s", balanceAccount, number, bankRequisites.BIK)
+			}
+		}
+	}
+}
@@ -0,0 +1,1140 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// FieldError описывает ошибку валидации конкретного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors — набор ошибок валидации, возвращаемый всеми обработчиками в едином формате
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	parts := make([]string, 0, len(v))
+	for _, fe := range v {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// Validator реализуется структурами запросов из models.go
+type Validator interface {
+	Validate() ValidationErrors
+}
+
+func respondValidationError(w http.ResponseWriter, errs ValidationErrors) {
+	log.Printf("Validation failed: %s", errs.Error())
+	respondJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": errs,
+	})
+}
+
+// decodeAndValidate разбирает JSON-тело запроса и проверяет его; при ошибке сам отправляет ответ
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, req Validator) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request payload")
+		return false
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		respondValidationError(w, errs)
+		return false
+	}
+	return true
+}
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // ограничение bcrypt
+	maxAmountScale    = 2
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+	maxAmount       = decimal.NewFromInt(1_000_000_000)
+)
+
+//go:embed data/breached_passwords.txt
+var embeddedBreachedPasswords string
+
+var (
+	breachedPasswords     map[string]struct{}
+	breachedPasswordsOnce sync.Once
+)
+
+// loadBreachedPasswords загружает встроенный список и, если задан, файл из BREACHED_PASSWORDS_PATH
+func loadBreachedPasswords() {
+	breachedPasswords = make(map[string]struct{})
+	addBreachedPasswords(bufio.NewScanner(strings.NewReader(embeddedBreachedPasswords)))
+
+	path := os.Getenv("BREACHED_PASSWORDS_PATH")
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Не удалось открыть список скомпрометированных паролей %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	addBreachedPasswords(bufio.NewScanner(f))
+}
+
+func addBreachedPasswords(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breachedPasswords[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+func isBreachedPassword(password string) bool {
+	breachedPasswordsOnce.Do(loadBreachedPasswords)
+	_, found := breachedPasswords[strings.ToLower(password)]
+	return found
+}
+
+func validateRequired(errs *ValidationErrors, field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		errs.Add(field, "is required")
+		return false
+	}
+	return true
+}
+
+func validateEmail(errs *ValidationErrors, field, email string) {
+	if !validateRequired(errs, field, email) {
+		return
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		errs.Add(field, "must be a valid email address")
+	}
+}
+
+func validateUsername(errs *ValidationErrors, field, username string) {
+	if !validateRequired(errs, field, username) {
+		return
+	}
+	if !usernamePattern.MatchString(username) {
+		errs.Add(field, "must be 3-32 characters long and contain only letters, digits, '.', '_' or '-'")
+	}
+}
+
+// validatePassword проверяет политику паролей: длина, классы символов, отсутствие в списке утечек
+func validatePassword(errs *ValidationErrors, field, password, username string) {
+	if !validateRequired(errs, field, password) {
+		return
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		errs.Add(field, "must be between 8 and 72 characters long")
+		return
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		errs.Add(field, "must contain upper and lower case letters and a digit")
+		return
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		errs.Add(field, "must not contain the username")
+		return
+	}
+	if isBreachedPassword(password) {
+		errs.Add(field, "has appeared in a data breach, choose a different password")
+	}
+}
+
+// validateAmount проверяет, что сумма положительна, не превышает лимит и содержит не более двух знаков после запятой
+func validateAmount(errs *ValidationErrors, field string, amount decimal.Decimal) {
+	if !amount.IsPositive() {
+		errs.Add(field, "must be positive")
+		return
+	}
+	if amount.GreaterThan(maxAmount) {
+		errs.Add(field, "must not exceed "+maxAmount.String())
+		return
+	}
+	if !amount.Equal(amount.Truncate(maxAmountScale)) {
+		errs.Add(field, "must have at most 2 decimal places")
+	}
+}
+
+func validateDigits(errs *ValidationErrors, field, value string, minLen, maxLen int) {
+	if !validateRequired(errs, field, value) {
+		return
+	}
+	if len(value) < minLen || len(value) > maxLen {
+		if minLen == maxLen {
+			errs.Add(field, "must be exactly "+strconv.Itoa(minLen)+" digits")
+		} else {
+			errs.Add(field, "must be "+strconv.Itoa(minLen)+"-"+strconv.Itoa(maxLen)+" digits")
+		}
+		return
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			errs.Add(field, "must contain only digits")
+			return
+		}
+	}
+}
+
+func (req RegisterRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateUsername(&errs, "username", req.Username)
+	validateEmail(&errs, "email", req.Email)
+	validatePassword(&errs, "password", req.Password, req.Username)
+	if req.Phone != "" {
+		validatePhone(&errs, "phone", req.Phone)
+	}
+	return errs
+}
+
+func (req LoginRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "username", req.Username)
+	validateRequired(&errs, "password", req.Password)
+	return errs
+}
+
+func (req ForgotPasswordRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateEmail(&errs, "email", req.Email)
+	return errs
+}
+
+func (req ResetPasswordRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "token", req.Token)
+	validatePassword(&errs, "new_password", req.NewPassword, "")
+	return errs
+}
+
+func (req CreateAccountRequest) Validate() ValidationErrors {
+	return nil
+}
+
+func (req GenerateCardRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "account_id", req.AccountID)
+	switch req.Type {
+	case "", CardTypePhysical:
+		if req.ExpiryMonths != 0 {
+			errs.Add("expiry_months", "is only supported for virtual and single-use cards")
+		}
+		if req.MerchantID != "" {
+			errs.Add("merchant_id", "is only supported for virtual and single-use cards")
+		}
+	case CardTypeVirtual, CardTypeSingleUse:
+		if req.ExpiryMonths < 0 || req.ExpiryMonths > 12 {
+			errs.Add("expiry_months", "must be between 1 and 12")
+		}
+	default:
+		errs.Add("type", "must be one of physical, virtual, single_use")
+	}
+	return errs
+}
+
+// Оплата возможна либо реквизитами карты (card_number и cvv), либо токеном мерчанта merchant_id
+func (req PaymentRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Token != "" {
+		validateTokenOnly(&errs, req.CardNumber, req.CVV)
+		validateRequired(&errs, "merchant_id", req.MerchantID)
+	} else {
+		validateRequired(&errs, "card_number", req.CardNumber)
+		validateDigits(&errs, "cvv", req.CVV, 3, 3)
+		validateRequired(&errs, "merchant", req.Merchant)
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	if req.MCC != "" {
+		validateDigits(&errs, "mcc", req.MCC, 4, 4)
+	}
+	validateChannel(&errs, "channel", req.Channel)
+	validateCountry(&errs, "country", req.Country)
+	return errs
+}
+
+func (req CreateMerchantRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "name", req.Name)
+	validateDigits(&errs, "mcc", req.MCC, 4, 4)
+	validateRequired(&errs, "settlement_account_id", req.SettlementAccountID)
+	return errs
+}
+
+func (req MerchantPaymentRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Token != "" {
+		validateTokenOnly(&errs, req.CardNumber, req.CVV)
+	} else {
+		validateRequired(&errs, "card_number", req.CardNumber)
+		validateDigits(&errs, "cvv", req.CVV, 3, 3)
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	validateChannel(&errs, "channel", req.Channel)
+	validateCountry(&errs, "country", req.Country)
+	return errs
+}
+
+// Нулевая сумма допустима: при финальном списании она означает весь остаток холда
+func (req CaptureRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if !req.Amount.IsZero() {
+		validateAmount(&errs, "amount", req.Amount)
+	}
+	return errs
+}
+
+func (req RefundRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "transaction_id", req.TransactionID)
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+// Нулевая сумма означает оспаривание всей оставшейся суммы оплаты
+func (req OpenDisputeRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "transaction_id", req.TransactionID)
+	if !req.Amount.IsZero() {
+		validateAmount(&errs, "amount", req.Amount)
+	}
+	validateRequired(&errs, "reason", req.Reason)
+	return errs
+}
+
+func (req ResolveDisputeRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Outcome != DisputeStatusWon && req.Outcome != DisputeStatusLost {
+		errs.Add("outcome", "must be either 'won' or 'lost'")
+	}
+	return errs
+}
+
+func (req SetMerchantMCCRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateDigits(&errs, "mcc", req.MCC, 4, 4)
+	return errs
+}
+
+func (req SetUserTierRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "tier", req.Tier)
+	return errs
+}
+
+func (req ConfirmPhoneRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateDigits(&errs, "code", req.Code, phoneCodeLength, phoneCodeLength)
+	return errs
+}
+
+func (req SetUserRoleRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Role != RoleCustomer && req.Role != RoleOperator {
+		errs.Add("role", "must be customer or operator")
+	}
+	return errs
+}
+
+// Максимальная годовая ставка по овердрафту, %
+var maxOverdraftRate = decimal.NewFromInt(100)
+
+func (req SetOverdraftRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if !req.Limit.IsZero() {
+		validateAmount(&errs, "limit", req.Limit)
+	}
+	if req.Rate.IsNegative() || req.Rate.GreaterThan(maxOverdraftRate) {
+		errs.Add("rate", "must be between 0 and 100")
+	}
+	return errs
+}
+
+func (req InviteAccountMemberRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "user", req.User)
+	switch req.Permission {
+	case AccountPermissionView, AccountPermissionFull:
+		if !req.PaymentLimit.IsZero() {
+			errs.Add("payment_limit", "is allowed only for pay permission")
+		}
+	case AccountPermissionPay:
+		if !req.PaymentLimit.IsZero() {
+			validateAmount(&errs, "payment_limit", req.PaymentLimit)
+		}
+	default:
+		errs.Add("permission", "must be one of view, pay, full")
+	}
+	return errs
+}
+
+var kppPattern = regexp.MustCompile(`^[0-9]{4}[0-9A-Z]{2}[0-9]{3}$`)
+
+// checksumDigit — контрольная цифра ИНН: взвешенная сумма цифр по модулю 11, затем по модулю 10
+func checksumDigit(digits string, weights []int) byte {
+	sum := 0
+	for i, w := range weights {
+		sum += int(digits[i]-'0') * w
+	}
+	return byte(sum%11%10) + '0'
+}
+
+// validateINN проверяет ИНН юридического лица: 10 цифр и контрольная цифра
+func validateINN(errs *ValidationErrors, field, inn string) {
+	before := len(*errs)
+	validateDigits(errs, field, inn, 10, 10)
+	if len(*errs) > before {
+		return
+	}
+	if checksumDigit(inn, []int{2, 4, 10, 3, 5, 9, 4, 6, 8}) != inn[9] {
+		errs.Add(field, "invalid checksum")
+	}
+}
+
+// validateOGRN проверяет ОГРН: 13 цифр, последняя — остаток от деления первых 12 на 11 (по модулю 10)
+func validateOGRN(errs *ValidationErrors, field, ogrn string) {
+	before := len(*errs)
+	validateDigits(errs, field, ogrn, 13, 13)
+	if len(*errs) > before {
+		return
+	}
+	base, _ := strconv.ParseInt(ogrn[:12], 10, 64)
+	if byte(base%11%10)+'0' != ogrn[12] {
+		errs.Add(field, "invalid checksum")
+	}
+}
+
+func (req CreateOrganizationRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "name", req.Name)
+	validateINN(&errs, "inn", req.INN)
+	validateOGRN(&errs, "ogrn", req.OGRN)
+	if validateRequired(&errs, "kpp", req.KPP) && !kppPattern.MatchString(req.KPP) {
+		errs.Add("kpp", "must be 9 characters: 4 digits, 2 digits or capital letters, 3 digits")
+	}
+	validateAmount(&errs, "dual_approval_threshold", req.DualApprovalThreshold)
+	return errs
+}
+
+func (req AddOrganizationSignerRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "user", req.User)
+	return errs
+}
+
+func (req RejectPaymentApprovalRequest) Validate() ValidationErrors {
+	return nil
+}
+
+// validatePartyINN проверяет ИНН стороны платежа: 10 цифр у организации, 12 — у физического лица или ИП
+func validatePartyINN(errs *ValidationErrors, field, inn string) {
+	switch len(inn) {
+	case 10:
+		validateINN(errs, field, inn)
+	case 12:
+		before := len(*errs)
+		validateDigits(errs, field, inn, 12, 12)
+		if len(*errs) > before {
+			return
+		}
+		if checksumDigit(inn, []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) != inn[10] ||
+			checksumDigit(inn, []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) != inn[11] {
+			errs.Add(field, "invalid checksum")
+		}
+	default:
+		errs.Add(field, "must be 10 or 12 digits")
+	}
+}
+
+// Максимальная длина назначения платежа в платёжном поручении
+const maxPaymentPurposeLength = 210
+
+// validateBIK проверяет БИК российского банка: 9 цифр, начинается с 04
+func validateBIK(errs *ValidationErrors, field, bik string) bool {
+	before := len(*errs)
+	validateDigits(errs, field, bik, 9, 9)
+	if len(*errs) == before && !strings.HasPrefix(bik, "04") {
+		errs.Add(field, "must start with 04")
+	}
+	return len(*errs) == before
+}
+
+// Позиция контрольного ключа в номере счёта
+const accountKeyIndex = 8
+
+// accountKeySum считает контрольную сумму номера счёта по БИК банка. К номеру приписываются три
+// последние цифры БИК (для подразделений Банка России — «0» и 5-6 цифры БИК), цифры умножаются
+// на веса 7, 1, 3 и младшие разряды произведений суммируются.
+func accountKeySum(bik, account string) int {
+	prefix := bik[6:]
+	if prefix == "000" || prefix == "001" || prefix == "002" {
+		prefix = "0" + bik[4:6]
+	}
+	digits := prefix + account
+	weights := [3]int{7, 1, 3}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		sum += int(digits[i]-'0') * weights[i%3] % 10
+	}
+	return sum
+}
+
+// accountKeyValid проверяет контрольный ключ 20-значного номера счёта по БИК банка:
+// контрольная сумма должна делиться на 10
+func accountKeyValid(bik, account string) bool {
+	if len(bik) != 9 || !isDigits(bik) || len(account) != accountNumberLength || !isDigits(account) {
+		return false
+	}
+	return accountKeySum(bik, account)%10 == 0
+}
+
+// accountKey вычисляет контрольный ключ номера счёта по БИК банка. Вес разряда ключа — 3,
+// поэтому ключ k дополняет сумму s остальных разрядов до кратной 10 при 3k ≡ −s, то есть k ≡ 3s (mod 10).
+func accountKey(bik, account string) byte {
+	number := account[:accountKeyIndex] + "0" + account[accountKeyIndex+1:]
+	return byte('0' + accountKeySum(bik, number)%10*3%10)
+}
+
+// validateExternalAccount проверяет номер счёта в другом банке; БИК должен быть уже проверен
+func validateExternalAccount(errs *ValidationErrors, field, bik, account string) {
+	before := len(*errs)
+	validateDigits(errs, field, account, 20, 20)
+	if len(*errs) == before && !accountKeyValid(bik, account) {
+		errs.Add(field, "control key does not match BIK")
+	}
+}
+
+func (req CreateExternalPaymentRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	if validateBIK(&errs, "bik", req.BIK) {
+		validateExternalAccount(&errs, "account", req.BIK, req.Account)
+	}
+	validateRequired(&errs, "name", req.Name)
+	if req.INN != "" {
+		validatePartyINN(&errs, "inn", req.INN)
+	}
+	if req.KPP != "" && !kppPattern.MatchString(req.KPP) {
+		errs.Add("kpp", "must be 9 characters: 4 digits, 2 digits or capital letters, 3 digits")
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	if validateRequired(&errs, "purpose", req.Purpose) && len([]rune(req.Purpose)) > maxPaymentPurposeLength {
+		errs.Add("purpose", "must be at most "+strconv.Itoa(maxPaymentPurposeLength)+" characters")
+	}
+	return errs
+}
+
+func (req SimulateIncomingPaymentRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if validateBIK(&errs, "payer_bik", req.PayerBIK) {
+		validateExternalAccount(&errs, "payer_account", req.PayerBIK, req.PayerAccount)
+	}
+	validateRequired(&errs, "payer_name", req.PayerName)
+	validateRequired(&errs, "payee_account", req.PayeeAccount)
+	validateAmount(&errs, "amount", req.Amount)
+	validateRequired(&errs, "purpose", req.Purpose)
+	return errs
+}
+
+const (
+	maxSBPCommentLength = 140
+	maxSBPQRTTLMinutes  = 72 * 60 // срок действия динамического QR-кода — не более 72 часов
+)
+
+var sbpMemberIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+func validateSBPBankID(errs *ValidationErrors, field, bankID string) {
+	if !sbpMemberIDPattern.MatchString(bankID) {
+		errs.Add(field, "must be a 12-digit SBP participant ID")
+	}
+}
+
+func (req UpdateSBPSettingsRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "account_id", req.AccountID)
+	return errs
+}
+
+func (req SBPLookupRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validatePhone(&errs, "phone", req.Phone)
+	return errs
+}
+
+func (req SBPTransferRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	validatePhone(&errs, "phone", req.Phone)
+	if req.BankID != "" {
+		validateSBPBankID(&errs, "bank_id", req.BankID)
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	if len([]rune(req.Comment)) > maxSBPCommentLength {
+		errs.Add("comment", "must be at most "+strconv.Itoa(maxSBPCommentLength)+" characters")
+	}
+	return errs
+}
+
+func (req CreateSBPQRRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	switch req.Type {
+	case SBPQRStatic:
+		if !req.Amount.IsZero() {
+			validateAmount(&errs, "amount", req.Amount)
+		}
+		if req.TTLMinutes != 0 {
+			errs.Add("ttl_minutes", "is allowed only for dynamic QR codes")
+		}
+	case SBPQRDynamic:
+		validateAmount(&errs, "amount", req.Amount)
+		if req.TTLMinutes < 0 || req.TTLMinutes > maxSBPQRTTLMinutes {
+			errs.Add("ttl_minutes", "must be between 0 and "+strconv.Itoa(maxSBPQRTTLMinutes))
+		}
+	default:
+		errs.Add("type", "must be one of static, dynamic")
+	}
+	if len([]rune(req.Purpose)) > maxPaymentPurposeLength {
+		errs.Add("purpose", "must be at most "+strconv.Itoa(maxPaymentPurposeLength)+" characters")
+	}
+	return errs
+}
+
+func (req ParseSBPQRRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "payload", req.Payload)
+	return errs
+}
+
+func (req PaySBPQRRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	validateRequired(&errs, "payload", req.Payload)
+	if !req.Amount.IsZero() {
+		validateAmount(&errs, "amount", req.Amount)
+	}
+	return errs
+}
+
+func (req SimulateSBPAliasRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validatePhone(&errs, "phone", req.Phone)
+	validateSBPBankID(&errs, "bank_id", req.BankID)
+	validateRequired(&errs, "name", req.Name)
+	return errs
+}
+
+func (req SimulateSBPIncomingRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateSBPBankID(&errs, "from_bank_id", req.FromBankID)
+	validateRequired(&errs, "sender_name", req.SenderName)
+	validatePhone(&errs, "phone", req.Phone)
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+const (
+	maxBillerFields      = 10
+	maxBillFieldLength   = 100
+	maxBillTemplateName  = 64
+	maxBillTemplateCount = 50
+)
+
+var (
+	billerFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	billPeriodPattern     = regexp.MustCompile(`^(0[1-9]|1[0-2])\.20[0-9]{2}$`)
+)
+
+func (req BillerRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "name", req.Name)
+	switch req.Category {
+	case BillerCategoryUtilities, BillerCategoryMobile, BillerCategoryInternet:
+	default:
+		errs.Add("category", "must be one of utilities, mobile, internet")
+	}
+	validatePartyINN(&errs, "inn", req.INN)
+	validateRequired(&errs, "settlement_account_id", req.SettlementAccountID)
+
+	switch {
+	case len(req.Fields) == 0:
+		errs.Add("fields", "must contain at least one field")
+	case len(req.Fields) > maxBillerFields:
+		errs.Add("fields", "must contain at most "+strconv.Itoa(maxBillerFields)+" fields")
+	}
+	seen := make(map[string]bool, len(req.Fields))
+	for i, f := range req.Fields {
+		prefix := "fields[" + strconv.Itoa(i) + "]."
+		if !billerFieldKeyPattern.MatchString(f.Key) {
+			errs.Add(prefix+"key", "must be lowercase latin letters, digits and underscores, starting with a letter")
+		} else if seen[f.Key] {
+			errs.Add(prefix+"key", "must be unique")
+		}
+		seen[f.Key] = true
+		validateRequired(&errs, prefix+"label", f.Label)
+		switch f.Type {
+		case BillerFieldText, BillerFieldNumber, BillerFieldPhone, BillerFieldPeriod, BillerFieldReading:
+		default:
+			errs.Add(prefix+"type", "must be one of text, number, phone, period, reading")
+		}
+		if f.MinLength < 0 || f.MaxLength < 0 || f.MaxLength > maxBillFieldLength || (f.MaxLength > 0 && f.MinLength > f.MaxLength) {
+			errs.Add(prefix+"max_length", "must be between min_length and "+strconv.Itoa(maxBillFieldLength))
+		}
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				errs.Add(prefix+"pattern", "must be a valid regular expression")
+			}
+		}
+	}
+
+	if req.MinAmount.IsNegative() {
+		errs.Add("min_amount", "must not be negative")
+	}
+	if !req.MaxAmount.IsZero() {
+		validateAmount(&errs, "max_amount", req.MaxAmount)
+		if req.MaxAmount.LessThan(req.MinAmount) {
+			errs.Add("max_amount", "must not be less than min_amount")
+		}
+	}
+	return errs
+}
+
+// validateBillFields проверяет реквизиты платежа по схеме полей поставщика и возвращает их
+// в нормализованном виде. При partial обязательные поля могут отсутствовать — так сохраняется шаблон.
+func validateBillFields(errs *ValidationErrors, biller Biller, values map[string]string, partial bool) map[string]string {
+	schema := make(map[string]bool, len(biller.Fields))
+	for _, f := range biller.Fields {
+		schema[f.Key] = true
+	}
+	unknown := make([]string, 0)
+	for key := range values {
+		if !schema[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs.Add("fields."+key, "is not defined for this biller")
+	}
+
+	normalized := make(map[string]string, len(values))
+	for _, f := range biller.Fields {
+		field := "fields." + f.Key
+		value := strings.TrimSpace(values[f.Key])
+		if value == "" {
+			if f.Required && !partial {
+				errs.Add(field, "is required")
+			}
+			continue
+		}
+
+		switch f.Type {
+		case BillerFieldNumber:
+			if strings.Trim(value, "0123456789") != "" {
+				errs.Add(field, "must contain only digits")
+				continue
+			}
+		case BillerFieldPhone:
+			phone, ok := NormalizePhone(value)
+			if !ok {
+				errs.Add(field, "must be a valid phone number, e.g. +79161234567")
+				continue
+			}
+			value = phone
+		case BillerFieldPeriod:
+			if !billPeriodPattern.MatchString(value) {
+				errs.Add(field, "must be a period in MM.YYYY format")
+				continue
+			}
+		case BillerFieldReading:
+			reading, err := decimal.NewFromString(value)
+			if err != nil || reading.IsNegative() || !reading.Equal(reading.Truncate(3)) {
+				errs.Add(field, "must be a non-negative number with at most 3 decimal places")
+				continue
+			}
+			value = reading.String()
+		}
+
+		length := len([]rune(value))
+		maxLength := f.MaxLength
+		if maxLength == 0 {
+			maxLength = maxBillFieldLength
+		}
+		if length < f.MinLength || length > maxLength {
+			errs.Add(field, "must be "+strconv.Itoa(f.MinLength)+"-"+strconv.Itoa(maxLength)+" characters")
+			continue
+		}
+		if f.Pattern != "" {
+			if pattern, err := regexp.Compile(f.Pattern); err == nil && !pattern.MatchString(value) {
+				errs.Add(field, "has invalid format")
+				continue
+			}
+		}
+		normalized[f.Key] = value
+	}
+	return normalized
+}
+
+// validateBillAmount проверяет сумму платежа по ограничениям поставщика
+func validateBillAmount(errs *ValidationErrors, field string, biller Biller, amount decimal.Decimal) {
+	validateAmount(errs, field, amount)
+	if amount.LessThan(biller.MinAmount) {
+		errs.Add(field, "must be at least "+biller.MinAmount.String())
+	}
+	if biller.MaxAmount.IsPositive() && amount.GreaterThan(biller.MaxAmount) {
+		errs.Add(field, "must not exceed "+biller.MaxAmount.String())
+	}
+}
+
+func (req BillPaymentRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	validateRequired(&errs, "biller_id", req.BillerID)
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+func (req BillTemplateRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if validateRequired(&errs, "name", req.Name) && len([]rune(req.Name)) > maxBillTemplateName {
+		errs.Add("name", "must be at most "+strconv.Itoa(maxBillTemplateName)+" characters")
+	}
+	validateRequired(&errs, "biller_id", req.BillerID)
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	if !req.Amount.IsZero() {
+		validateAmount(&errs, "amount", req.Amount)
+	}
+	return errs
+}
+
+func (req PayBillTemplateRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if !req.Amount.IsZero() {
+		validateAmount(&errs, "amount", req.Amount)
+	}
+	return errs
+}
+
+func (req CloseAccountRequest) Validate() ValidationErrors {
+	return nil
+}
+
+func (req SetAccountStatusRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	switch req.Status {
+	case AccountStatusActive:
+	case AccountStatusFrozen:
+		validateRequired(&errs, "reason", req.Reason)
+	case AccountStatusArrested:
+		validateAmount(&errs, "arrested_amount", req.ArrestedAmount)
+		validateRequired(&errs, "reason", req.Reason)
+	default:
+		errs.Add("status", "must be one of active, frozen, arrested")
+	}
+	if req.Status != AccountStatusArrested && !req.ArrestedAmount.IsZero() {
+		errs.Add("arrested_amount", "is allowed only for arrested status")
+	}
+	return errs
+}
+
+func (req ReverseTransactionRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "reason", req.Reason)
+	return errs
+}
+
+func validateChannel(errs *ValidationErrors, field, channel string) {
+	switch channel {
+	case "", CardChannelOnline, CardChannelContactless, CardChannelChip:
+	default:
+		errs.Add(field, "must be one of online, contactless, chip")
+	}
+}
+
+func validateCountry(errs *ValidationErrors, field, country string) {
+	if country == "" {
+		return
+	}
+	if len(country) != 2 || strings.ToUpper(country) != country {
+		errs.Add(field, "must be an ISO 3166-1 alpha-2 country code")
+	}
+}
+
+func validateLimit(errs *ValidationErrors, field string, limit decimal.Decimal) {
+	if !limit.IsZero() {
+		validateAmount(errs, field, limit)
+	}
+}
+
+func validateMCCList(errs *ValidationErrors, field string, mccs []string) {
+	for _, mcc := range mccs {
+		var mccErrs ValidationErrors
+		validateDigits(&mccErrs, field, mcc, 4, 4)
+		if len(mccErrs) > 0 {
+			errs.Add(field, "must contain only 4-digit merchant category codes")
+			return
+		}
+	}
+}
+
+func (req UpdateCardControlsRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.DailyLimit != nil {
+		validateLimit(&errs, "daily_limit", *req.DailyLimit)
+	}
+	if req.MonthlyLimit != nil {
+		validateLimit(&errs, "monthly_limit", *req.MonthlyLimit)
+	}
+	if req.PerTransactionLimit != nil {
+		validateLimit(&errs, "per_transaction_limit", *req.PerTransactionLimit)
+	}
+	if req.AllowedMCCs != nil {
+		validateMCCList(&errs, "allowed_mccs", *req.AllowedMCCs)
+	}
+	if req.BlockedMCCs != nil {
+		validateMCCList(&errs, "blocked_mccs", *req.BlockedMCCs)
+	}
+	return errs
+}
+
+// validatePIN требует 4 цифры и запрещает тривиальные комбинации
+func validatePIN(errs *ValidationErrors, field, pin string) {
+	var pinErrs ValidationErrors
+	validateDigits(&pinErrs, field, pin, 4, 4)
+	if len(pinErrs) > 0 {
+		*errs = append(*errs, pinErrs...)
+		return
+	}
+	sameDigits, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		sameDigits = sameDigits && pin[i] == pin[0]
+		ascending = ascending && pin[i] == pin[i-1]+1
+		descending = descending && pin[i] == pin[i-1]-1
+	}
+	if sameDigits || ascending || descending {
+		errs.Add(field, "is too easy to guess")
+	}
+}
+
+func (req SetPINRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validatePIN(&errs, "pin", req.PIN)
+	return errs
+}
+
+func (req ChangePINRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateDigits(&errs, "old_pin", req.OldPIN, 4, 4)
+	validatePIN(&errs, "new_pin", req.NewPIN)
+	return errs
+}
+
+func (req VerifyPINRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "card_number", req.CardNumber)
+	validateDigits(&errs, "pin", req.PIN, 4, 4)
+	return errs
+}
+
+func (req ATMWithdrawalRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "card_number", req.CardNumber)
+	validateDigits(&errs, "pin", req.PIN, 4, 4)
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+func (req CreateCardTokenRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "card_number", req.CardNumber)
+	validateDigits(&errs, "cvv", req.CVV, 3, 3)
+	return errs
+}
+
+func (req CreateStandingOrderRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	switch {
+	case req.To != "" && req.ToAccountID != "":
+		errs.Add("to", "must not be combined with to_account_id")
+	case req.To != "":
+		validateRecipientType(&errs, "to_type", req.ToType)
+	default:
+		validateRequired(&errs, "to_account_id", req.ToAccountID)
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	validateStandingOrderSchedule(&errs, req.Frequency, req.DayOfMonth, req.StartDate, req.EndDate)
+	return errs
+}
+
+func (req UpdateStandingOrderRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateAmount(&errs, "amount", req.Amount)
+	validateStandingOrderSchedule(&errs, req.Frequency, req.DayOfMonth, req.StartDate, req.EndDate)
+	return errs
+}
+
+func validateStandingOrderSchedule(errs *ValidationErrors, frequency string, dayOfMonth int, startDate, endDate string) {
+	switch frequency {
+	case StandingOrderOnce, StandingOrderDaily, StandingOrderWeekly, StandingOrderLastBusinessDay:
+		if dayOfMonth != 0 {
+			errs.Add("day_of_month", "is only allowed for monthly frequency")
+		}
+	case StandingOrderMonthly:
+		if dayOfMonth < 1 || dayOfMonth > 31 {
+			errs.Add("day_of_month", "must be between 1 and 31")
+		}
+	default:
+		errs.Add("frequency", "must be one of once, daily, weekly, monthly, last_business_day")
+	}
+
+	start, err := parseStandingOrderDate(startDate)
+	if err != nil {
+		errs.Add("start_date", "must be a date in YYYY-MM-DD format")
+	}
+	if endDate != "" {
+		end, endErr := parseStandingOrderDate(endDate)
+		switch {
+		case endErr != nil:
+			errs.Add("end_date", "must be a date in YYYY-MM-DD format")
+		case frequency == StandingOrderOnce:
+			errs.Add("end_date", "is not allowed for a one-time transfer")
+		case err == nil && end.Before(start):
+			errs.Add("end_date", "must not be before start_date")
+		}
+	}
+}
+
+func (req TransferRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	switch {
+	case req.To != "" && req.ToAccountID != "":
+		errs.Add("to", "must not be combined with to_account_id")
+	case req.To != "":
+		validateRecipientType(&errs, "to_type", req.ToType)
+	case validateRequired(&errs, "to_account_id", req.ToAccountID) && req.ToAccountID == req.FromAccountID:
+		errs.Add("to_account_id", "must differ from from_account_id")
+	}
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+// Максимальное число строк в одном пакетном переводе
+const maxBatchTransferLines = 1000
+
+func (req BatchTransferRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "from_account_id", req.FromAccountID)
+	switch req.Mode {
+	case "", BatchModeAtomic, BatchModeBestEffort:
+	default:
+		errs.Add("mode", "must be one of atomic, best_effort")
+	}
+	switch {
+	case len(req.Transfers) == 0:
+		errs.Add("transfers", "must contain at least one transfer")
+	case len(req.Transfers) > maxBatchTransferLines:
+		errs.Add("transfers", "must contain at most "+strconv.Itoa(maxBatchTransferLines)+" transfers")
+	}
+	return errs
+}
+
+// Validate проверяет одну строку пакета; ошибки попадают в построчный отчёт, а не в общий ответ
+func (line BatchTransferLine) Validate() ValidationErrors {
+	var errs ValidationErrors
+	switch {
+	case line.To != "" && line.ToAccountID != "":
+		errs.Add("to", "must not be combined with to_account_id")
+	case line.To != "":
+		validateRecipientType(&errs, "to_type", line.ToType)
+	default:
+		validateRequired(&errs, "to_account_id", line.ToAccountID)
+	}
+	validateAmount(&errs, "amount", line.Amount)
+	return errs
+}
+
+func (req ResolveRecipientRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "recipient", req.Recipient)
+	validateRecipientType(&errs, "recipient_type", req.RecipientType)
+	return errs
+}
+
+func validateRecipientType(errs *ValidationErrors, field, recipientType string) {
+	switch recipientType {
+	case "", RecipientTypeAccountNumber, RecipientTypeCardNumber, RecipientTypePhone:
+	default:
+		errs.Add(field, "must be one of account_number, card_number, phone")
+	}
+}
+
+func validatePhone(errs *ValidationErrors, field, phone string) {
+	if _, ok := NormalizePhone(phone); !ok {
+		errs.Add(field, "must be a valid phone number, e.g. +79161234567")
+	}
+}
+
+func (req DepositRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "to_account_id", req.ToAccountID)
+	validateAmount(&errs, "amount", req.Amount)
+	return errs
+}
+
+func (req ApplyLoanRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateRequired(&errs, "account_id", req.AccountID)
+	validateAmount(&errs, "amount", req.Amount)
+	if req.TermMonths <= 0 || req.TermMonths > 360 {
+		errs.Add("term_months", "must be between 1 and 360")
+	}
+	return errs
+}
+
+// validateTokenOnly запрещает передавать реквизиты карты вместе с токеном
+func validateTokenOnly(errs *ValidationErrors, cardNumber, cvv string) {
+	if cardNumber != "" {
+		errs.Add("card_number", "must be omitted when paying with a token")
+	}
+	if cvv != "" {
+		errs.Add("cvv", "must be omitted when paying with a token")
+	}
+}
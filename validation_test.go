@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAccountKeyValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		bik     string
+		account string
+		want    bool
+	}{
+		{"commercial bank", "044525225", "40702810938000000001", true},
+		{"commercial bank personal", "044525974", "40817810000000000001", true},
+		{"bank of russia branch", "044525000", "40101810045250010041", true},
+		{"wrong key", "044525225", "40702810038000000001", false},
+		{"wrong digit", "044525225", "40702810938000000002", false},
+		{"other bank", "044525974", "40702810938000000001", false},
+		{"short account", "044525225", "4070281093800000000", false},
+		{"non-digit account", "044525225", "4070281093800000000a", false},
+		{"short bik", "04452522", "40702810938000000001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountKeyValid(tt.bik, tt.account); got != tt.want {
+				t.Errorf("accountKeyValid(%q, %q) = %v, want %v", tt.bik, tt.account, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountKey(t *testing.T) {
+	for _, account := range []string{"40702810938000000001", "40817810000000000001", "40101810045250010041"} {
+		for _, bik := range []string{"044525225", "044525974", "044525000", defaultBankBIK} {
+			number := account[:accountKeyIndex] + string(accountKey(bik, account)) + account[accountKeyIndex+1:]
+			if !accountKeyValid(bik, number) {
+				t.Errorf("accountKey(%q, %q) gives %q, which fails the key check", bik, account, number)
+			}
+		}
+	}
+}